@@ -0,0 +1,89 @@
+package args
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "configuration diagnostics",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "print the effective configuration with masked secrets",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		cfg, err := config.Read(cmd.Context())
+		if err != nil {
+			return err //nolint:wrapcheck // no need
+		}
+
+		value := config.Masked(cfg)
+
+		format, _ := cmd.Flags().GetString("format")
+
+		switch format {
+		case "yaml":
+			encoder := yaml.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent(2)
+
+			if err := encoder.Encode(value); err != nil {
+				return fmt.Errorf("failed to encode yaml; %w", err)
+			}
+
+			return encoder.Close() //nolint:wrapcheck // no need
+		case "json":
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+
+			return encoder.Encode(value) //nolint:wrapcheck // no need
+		}
+
+		return fmt.Errorf("unknown format [%s]", format)
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "validate the configuration and list all problems",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		cfg, err := config.Read(cmd.Context())
+		if err != nil {
+			return err //nolint:wrapcheck // no need
+		}
+
+		err = cfg.Validate()
+		if err == nil {
+			fmt.Fprintln(cmd.OutOrStdout(), "config is valid")
+
+			return nil
+		}
+
+		problems := []error{err}
+
+		var joined interface{ Unwrap() []error }
+		if errors.As(err, &joined) {
+			problems = joined.Unwrap()
+		}
+
+		for _, problem := range problems {
+			fmt.Fprintln(cmd.OutOrStdout(), "-", problem)
+		}
+
+		return fmt.Errorf("config has %d problems", len(problems))
+	},
+}
+
+func init() {
+	configPrintCmd.Flags().StringP("format", "f", "yaml", "output format yaml or json")
+
+	configCmd.AddCommand(configPrintCmd, configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,89 @@
+package args
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/worldline-go/tell"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/loadgen"
+)
+
+var loadgenCmd = &cobra.Command{
+	Use:   "loadgen",
+	Short: "generate load to the example services",
+	Long:  "run scenarios with target rate and concurrency, print latency percentiles and error rates",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		flags := cmd.Flags()
+
+		cfg := loadgen.Config{Headers: http.Header{}}
+		cfg.Target, _ = flags.GetString("target")
+		cfg.Scenarios, _ = flags.GetStringSlice("scenario")
+		cfg.RPS, _ = flags.GetFloat64("rps")
+		cfg.Concurrency, _ = flags.GetInt("concurrency")
+		cfg.RampUp, _ = flags.GetDuration("ramp-up")
+		cfg.Duration, _ = flags.GetDuration("duration")
+		cfg.Timeout, _ = flags.GetDuration("timeout")
+		cfg.Interval, _ = flags.GetDuration("interval")
+		cfg.Chain, _ = flags.GetStringSlice("chain")
+
+		headers, _ := flags.GetStringArray("header")
+		if err := addHeaders(cfg.Headers, headers); err != nil {
+			return err
+		}
+
+		// telemetry of the generator, traces start from the client spans
+		appCfg, err := config.Read(cmd.Context())
+		if err != nil {
+			return err //nolint:wrapcheck // no need
+		}
+
+		collector, err := tell.New(cmd.Context(), appCfg.Telemetry)
+		if err != nil {
+			return fmt.Errorf("failed to init telemetry; %w", err)
+		}
+		defer collector.Shutdown()
+
+		report, err := loadgen.Run(cmd.Context(), cfg)
+		if err != nil {
+			return err //nolint:wrapcheck // no need
+		}
+
+		return report.Print(cmd.OutOrStdout()) //nolint:wrapcheck // no need
+	},
+}
+
+func init() {
+	flags := loadgenCmd.Flags()
+	flags.String("target", "http://localhost:8080", "base url of the service")
+	flags.StringSlice("scenario", []string{loadgen.ScenarioCount},
+		"scenarios to run in turn, one of ["+strings.Join(loadgen.Scenarios(), " ")+"]")
+	flags.Float64("rps", 10, "scenario runs per second, 0 runs as fast as the workers can")
+	flags.Int("concurrency", 10, "number of workers")
+	flags.Duration("ramp-up", 0, "increase the rate from zero to rps in this duration")
+	flags.Duration("duration", 30*time.Second, "duration of the run")
+	flags.Duration("timeout", 10*time.Second, "timeout of each request")
+	flags.Duration("interval", 5*time.Second, "interval to log the progress, 0 disables it")
+	flags.StringSlice("chain", []string{"service-2"}, "services of the call scenario, first one is called by the generator")
+	flags.StringArrayP("header", "H", nil, "header added to all requests like \"X-API-Key: secret\"")
+
+	rootCmd.AddCommand(loadgenCmd)
+}
+
+// addHeaders adds the headers like "Key: value".
+func addHeaders(h http.Header, headers []string) error {
+	for _, header := range headers {
+		key, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return fmt.Errorf("header [%s] must be like \"Key: value\"", header)
+		}
+
+		h.Add(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package args
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/server/handler"
+)
+
+var productsCmd = &cobra.Command{
+	Use:   "products",
+	Short: "import and export products of the service",
+}
+
+var productsImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "import products from ndjson or csv file",
+	Long:  "stream the file to the import endpoint and print the report, files with the .csv extension are sent as csv",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		flags := cmd.Flags()
+
+		file, _ := flags.GetString("file")
+		onConflict, _ := flags.GetString("on-conflict")
+
+		contentType := handler.MIMEApplicationNDJSON
+		if strings.EqualFold(filepath.Ext(file), ".csv") {
+			contentType = handler.MIMETextCSV
+		}
+
+		var body io.Reader = os.Stdin
+		if file != "-" {
+			f, err := os.Open(file)
+			if err != nil {
+				return fmt.Errorf("failed to open file; %w", err)
+			}
+			defer f.Close()
+
+			body = f
+		}
+
+		query := url.Values{}
+		query.Set("on_conflict", onConflict)
+
+		req, err := productsRequest(cmd, http.MethodPost, "/api/v1/products:import?"+query.Encode(), body)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Content-Type", contentType)
+
+		resp, err := productsDo(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		var message struct {
+			Data model.ImportReport `json:"data"`
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
+			return fmt.Errorf("failed to decode report; %w", err)
+		}
+
+		report := message.Data
+
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "inserted: %d updated: %d skipped: %d failed: %d\n",
+			report.Inserted, report.Updated, report.Skipped, report.Failed)
+
+		for _, e := range report.Errors {
+			fmt.Fprintf(out, "- line %d %s: %s\n", e.Line, e.Name, e.Error)
+		}
+
+		if report.Failed > 0 {
+			return fmt.Errorf("%d products failed", report.Failed)
+		}
+
+		return nil
+	},
+}
+
+var productsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export products as ndjson or csv",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		flags := cmd.Flags()
+
+		format, _ := flags.GetString("format")
+		output, _ := flags.GetString("output")
+
+		query := url.Values{}
+		query.Set("format", format)
+
+		req, err := productsRequest(cmd, http.MethodGet, "/api/v1/products:export?"+query.Encode(), nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := productsDo(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if output == "-" {
+			if _, err := io.Copy(cmd.OutOrStdout(), resp.Body); err != nil {
+				return fmt.Errorf("failed to export products; %w", err)
+			}
+
+			return nil
+		}
+
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create file; %w", err)
+		}
+
+		// the server aborts the transfer when the export fails, the partial file is removed
+		_, err = io.Copy(f, resp.Body)
+		if errClose := f.Close(); err == nil {
+			err = errClose
+		}
+
+		if err != nil {
+			_ = os.Remove(output)
+
+			return fmt.Errorf("failed to export products; %w", err)
+		}
+
+		return nil
+	},
+}
+
+func productsRequest(cmd *cobra.Command, method, path string, body io.Reader) (*http.Request, error) {
+	target, _ := cmd.Flags().GetString("target")
+
+	req, err := http.NewRequestWithContext(cmd.Context(), method, strings.TrimRight(target, "/")+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request; %w", err)
+	}
+
+	headers, _ := cmd.Flags().GetStringArray("header")
+	if err := addHeaders(req.Header, headers); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// productsDo sends the request and returns the problem of the failed response as error.
+func productsDo(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request; %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+
+		var problem model.Problem
+		if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil || problem.Detail == "" {
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+
+		return nil, fmt.Errorf("unexpected status %s; %s", resp.Status, problem.Detail)
+	}
+
+	return resp, nil
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{productsImportCmd, productsExportCmd} {
+		flags := cmd.Flags()
+		flags.String("target", "http://localhost:8080", "base url of the service")
+		flags.StringArrayP("header", "H", nil, "header added to the request like \"X-API-Key: secret\"")
+	}
+
+	productsImportCmd.Flags().StringP("file", "f", "-", "ndjson or csv file, - reads the stdin")
+	productsImportCmd.Flags().String("on-conflict", model.ConflictFail, "existing products are upsert, skip or fail")
+
+	productsExportCmd.Flags().String("format", model.FormatNDJSON, "format of the export ndjson or csv")
+	productsExportCmd.Flags().StringP("output", "o", "-", "file to write, - writes the stdout")
+
+	productsCmd.AddCommand(productsImportCmd, productsExportCmd)
+	rootCmd.AddCommand(productsCmd)
+}
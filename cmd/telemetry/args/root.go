@@ -7,25 +7,44 @@ import (
 	"net/http"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/twmb/franz-go/pkg/kgo"
 	"github.com/twmb/franz-go/plugin/kotel"
 	"github.com/worldline-go/initializer"
 	"github.com/worldline-go/klient"
-	"github.com/worldline-go/logz"
 	"github.com/worldline-go/tell"
 	"github.com/worldline-go/wkafka"
+	"go.opentelemetry.io/otel"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/worldline-go/telemetry_example/internal/auth"
+	"github.com/worldline-go/telemetry_example/internal/cache"
+	"github.com/worldline-go/telemetry_example/internal/chaos"
 	"github.com/worldline-go/telemetry_example/internal/config"
 	"github.com/worldline-go/telemetry_example/internal/database"
 	"github.com/worldline-go/telemetry_example/internal/database/dbhandler"
 	"github.com/worldline-go/telemetry_example/internal/hold"
+	"github.com/worldline-go/telemetry_example/internal/idempotency"
 	"github.com/worldline-go/telemetry_example/internal/kafka"
+	"github.com/worldline-go/telemetry_example/internal/logging"
 	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/ratelimit"
+	"github.com/worldline-go/telemetry_example/internal/reload"
+	"github.com/worldline-go/telemetry_example/internal/resilience"
+	"github.com/worldline-go/telemetry_example/internal/search"
+	"github.com/worldline-go/telemetry_example/internal/secret"
 	"github.com/worldline-go/telemetry_example/internal/server"
+	"github.com/worldline-go/telemetry_example/internal/server/admin"
+	"github.com/worldline-go/telemetry_example/internal/server/gql"
 	"github.com/worldline-go/telemetry_example/internal/server/handler"
+	"github.com/worldline-go/telemetry_example/internal/server/rpc"
+	"github.com/worldline-go/telemetry_example/internal/stream"
 	"github.com/worldline-go/telemetry_example/internal/telemetry"
+	"github.com/worldline-go/telemetry_example/internal/tenant"
+	"github.com/worldline-go/telemetry_example/internal/webhook"
 )
 
 var rootCmd = &cobra.Command{
@@ -33,7 +52,7 @@ var rootCmd = &cobra.Command{
 	Short: "telemetry example project",
 	Long:  "example of trace, metrics, logs",
 	PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
-		if err := logz.SetLogLevel(config.Application.LogLevel); err != nil {
+		if err := logging.Init(config.Application.LogLevel); err != nil {
 			return err
 		}
 
@@ -63,6 +82,9 @@ func runRoot(ctx context.Context) error {
 	}
 	defer collector.Shutdown()
 
+	// redact secrets in all spans
+	otel.SetTracerProvider(secret.TracerProvider(otel.GetTracerProvider()))
+
 	if err := telemetry.SetGlobalMeter(); err != nil {
 		return fmt.Errorf("failed to set metric; %w", err)
 	}
@@ -70,36 +92,49 @@ func runRoot(ctx context.Context) error {
 	// //////////////////////////////////////////
 	// database connection
 	var db *sqlx.DB
+	var pool *database.Pool
 	if config.Application.EnableDatabase {
 		if err := database.MigrateDB(ctx, config.Application.Database.Migrate); err != nil {
 			return fmt.Errorf("failed to migrate database; %w", err)
 		}
 
-		var err error
-		db, err = database.Connect(ctx, config.Application.Database.DBDatasource, config.Application.Database.DBType)
+		pool, err = database.Connect(ctx, config.Application.Database.DBDatasource, config.Application.Database.DBType)
 		if err != nil {
 			return fmt.Errorf("failed to connect to database; %w", err)
 		}
-		defer db.Close()
+		defer pool.Close()
+
+		db = pool.DB
 	}
 
-	// db handler
+	// tenant schemas, existing ones are migrated on start
+	var tenants *tenant.Registry
+	if config.Application.EnableTenancy {
+		tenants = tenant.NewRegistry(config.Application.Tenancy, config.Application.Database, db)
+		defer tenants.Close()
+
+		if err := tenants.Load(ctx); err != nil {
+			return fmt.Errorf("failed to load tenants; %w", err)
+		}
+	}
+
+	// db handler, the tenant registry routes the queries to the schema of the request's tenant
 	dbHandler := dbhandler.New(db)
+	if tenants != nil {
+		dbHandler = dbhandler.New(tenants)
+	}
 
 	// //////////////////////////////////////////
 	// http clients
-	clients := make(map[string]*klient.Client)
-	for name := range config.Application.API {
-		client, err := config.Application.API[name].New(
-			klient.WithHeaderAdd(http.Header{
-				"Content-Type": []string{"application/json"},
-			}),
-		)
-		if err != nil {
-			return fmt.Errorf("failed to create client [%s]; %w", name, err)
-		}
-
-		clients[name] = client
+	clients, err := hold.NewClients(config.Application.API,
+		klient.WithHeaderAdd(http.Header{
+			"Content-Type": []string{"application/json"},
+		}),
+		// retries are done with the call policies
+		klient.WithDisableRetry(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create clients; %w", err)
 	}
 
 	// //////////////////////////////////////////
@@ -128,7 +163,8 @@ func runRoot(ctx context.Context) error {
 		kafkaClient, err = wkafka.New(ctx,
 			config.Application.KafkaConfig,
 			wkafka.WithClientInfo(config.ServiceName, config.ServiceVersion),
-			wkafka.WithKGOOptions(kgo.WithHooks(kafkaOtel.Hooks()...)),
+			// tenant header is added to the records produced in a request of a tenant
+			wkafka.WithKGOOptions(kgo.WithHooks(append(kafkaOtel.Hooks(), tenant.KafkaHook{})...)),
 		)
 		if err != nil {
 			return fmt.Errorf("failed to create kafka client; %w", err)
@@ -143,52 +179,349 @@ func runRoot(ctx context.Context) error {
 		defer kafkaClient.Close()
 	}
 
+	// //////////////////////////////////////////
+	// redis for the shared rate limits and cache
+	var redisClient *redis.Client
+	if (config.Application.EnableRateLimit && config.Application.RateLimit.Shared) ||
+		(config.Application.EnableCache && (config.Application.Cache.Backend == cache.BackendRedis || config.Application.Cache.Broadcast)) {
+		redisClient = config.Application.Redis.NewClient()
+		defer redisClient.Close()
+
+		if err := redisClient.Ping(ctx).Err(); err != nil {
+			log.Warn().Err(err).Msg("redis not reachable, rate limits are local and the cache is skipped until it is")
+		}
+	}
+
+	// retries, timeouts and circuit breakers of the clients
+	caller := resilience.NewCaller(&config.Application)
+
+	// stream of the changes, closed with the http server to end the open streams
+	streams := stream.New(config.Application.Stream)
+
+	// product search, memory index without the database
+	searcher := search.New(config.Application.Search, config.Application.EnableDatabase, dbHandler)
+
+	// product cache, invalidated with the local writes, the broadcast of the other replicas and the consumed products
+	var productCache *cache.Products
+	if config.Application.EnableCache {
+		productCache = cache.New(config.Application.Cache, redisClient)
+	}
+
 	// //////////////////////////////////////////
 	// set handlers
 	handlerServer := &handler.Handler{
 		Counter:       &hold.Counter{},
 		Clients:       clients,
+		Caller:        caller,
 		KafkaProducer: kafkaProducer,
 		KafkaTracer:   kafkaTracer,
 		DB:            dbHandler,
+		Streams:       streams,
+		Search:        searcher,
+		Cache:         productCache,
+		APIKeyHeader:  config.Application.Auth.APIKeyHeader,
+	}
+
+	// webhooks of the product events
+	var webhooks *webhook.Dispatcher
+	if config.Application.EnableWebhooks {
+		webhooks, err = webhook.New(config.Application.Webhook, dbHandler, tenants)
+		if err != nil {
+			return fmt.Errorf("failed to create webhooks; %w", err)
+		}
+
+		handlerServer.Webhooks = webhooks
+	}
+
+	// idempotency keys of the POST endpoints
+	var idempotent *idempotency.Idempotency
+	if config.Application.EnableIdempotency {
+		idempotent = idempotency.New(config.Application.Idempotency, dbHandler, tenants)
+		handlerServer.Idempotency = idempotent.Middleware()
 	}
 
 	handlerKafka := kafka.Kafka{
-		DB:     dbHandler,
-		Tracer: kafkaTracer,
+		DB:      dbHandler,
+		Tracer:  kafkaTracer,
+		Streams: streams,
+		Search:  searcher,
+		Cache:   productCache,
+		Tenants: tenants,
 	}
 
+	// //////////////////////////////////////////
+	// rate limiting
+	var limiter *ratelimit.Limiter
+	if config.Application.EnableRateLimit {
+		var sharedClient *redis.Client
+		if config.Application.RateLimit.Shared {
+			sharedClient = redisClient
+		}
+
+		limiter, err = ratelimit.New(config.Application.RateLimit, sharedClient)
+		if err != nil {
+			return fmt.Errorf("failed to init rate limit; %w", err)
+		}
+	}
+
+	// //////////////////////////////////////////
+	// authentication, failed attempts are limited per client IP before it
+	var middlewares []echo.MiddlewareFunc
+	var authenticator *auth.Auth
+	if config.Application.EnableAuth {
+		authenticator, err = auth.New(ctx, config.Application.Auth)
+		if err != nil {
+			return fmt.Errorf("failed to init authentication; %w", err)
+		}
+
+		if limiter != nil {
+			middlewares = append(middlewares, limiter.AuthMiddleware())
+		}
+
+		middlewares = append(middlewares, authenticator.Middleware())
+	}
+
+	// rate limits after authentication to limit the authenticated subjects
+	if limiter != nil {
+		middlewares = append(middlewares, limiter.Middleware())
+	}
+
+	// //////////////////////////////////////////
+	// tenant, after authentication to check the tenant of the credentials
+	if tenants != nil {
+		middlewares = append(middlewares, tenants.Middleware())
+	}
+
+	// //////////////////////////////////////////
+	// chaos, after authentication to inject faults only to known clients
+	var chaosInjector *chaos.Chaos
+	if config.Application.EnableChaos {
+		chaosInjector = chaos.New(config.Application.Chaos)
+		handlerServer.Chaos = chaosInjector
+
+		middlewares = append(middlewares, chaosInjector.Middleware())
+	}
+
+	// //////////////////////////////////////////
+	// graphql, resolvers check the scopes and rate limits of the http routes
+	graphQL, err := gql.New(handlerServer, gql.Settings{
+		Auth:    authenticator,
+		Limiter: limiter,
+	})
+	if err != nil {
+		return err
+	}
+
+	handlerServer.GraphQL = graphQL.Handle
+
 	// //////////////////////////////////////////
 	// set router
 	router := server.NewRouter(
 		server.RouterSettings{
-			Addr: net.JoinHostPort(config.Application.Host, config.Application.Port),
+			Addr:           net.JoinHostPort(config.Application.Host, config.Application.Port),
+			Middlewares:    middlewares,
+			TrustedProxies: config.Application.TrustedProxies,
 		},
 		handlerServer,
 	)
 
 	// //////////////////////////////////////////
-	// run listeners
+	// set admin router
+	adminHandler := &admin.Handler{Tenants: tenants}
+	if authenticator != nil {
+		if limiter != nil {
+			adminHandler.Middlewares = append(adminHandler.Middlewares, limiter.AuthMiddleware())
+		}
 
-	if !config.Application.EnableKafkaConsumer {
-		// just run server
-		router.StopWithContext(ctx, initializer.WaitGroup(ctx))
-		return router.Start()
+		adminHandler.Middlewares = append(adminHandler.Middlewares, authenticator.Middleware())
+		adminHandler.Authenticated = true
 	}
 
-	// run multiple listeners
+	if db != nil {
+		adminHandler.Checks = append(adminHandler.Checks,
+			admin.Check{Name: "database", Func: db.PingContext},
+			admin.Check{Name: "migration", Func: func(ctx context.Context) error {
+				current, latest, err := database.MigrationVersion(ctx, db, config.Application.Database.Migrate)
+				if err != nil {
+					return err
+				}
+
+				if current < latest {
+					return fmt.Errorf("migration version %d is behind %d", current, latest)
+				}
+
+				return nil
+			}},
+		)
+	}
+
+	if kafkaClient != nil {
+		adminHandler.Checks = append(adminHandler.Checks,
+			admin.Check{Name: "kafka", Func: kafkaClient.Kafka.Ping},
+		)
+	}
+
+	adminRouter := server.NewAdminRouter(
+		server.RouterSettings{
+			Addr:           net.JoinHostPort(config.Application.Host, config.Application.AdminPort),
+			TrustedProxies: config.Application.TrustedProxies,
+		},
+		adminHandler,
+	)
+
+	// //////////////////////////////////////////
+	// set grpc server
+	var grpcServer *rpc.Server
+	if config.Application.EnableGRPC {
+		grpcServer = rpc.NewServer(
+			rpc.Settings{
+				Addr:    net.JoinHostPort(config.Application.Host, config.Application.GRPCPort),
+				Auth:    authenticator,
+				Tenants: tenants,
+				Limiter: limiter,
+				Chaos:   chaosInjector,
+			},
+			handlerServer,
+		)
+	}
+
+	// //////////////////////////////////////////
+	// config reload, only safe settings are changed
+	reloader := &reload.Reloader{
+		Applies: []reload.Apply{
+			{Name: "log", Func: func(_ context.Context, cfg *config.Config) error {
+				return logging.Apply(cfg.LogSettings())
+			}},
+			{Name: "api", Func: func(_ context.Context, cfg *config.Config) error {
+				return clients.Set(cfg.API)
+			}},
+			{Name: "call_policy", Func: func(_ context.Context, cfg *config.Config) error {
+				caller.Update(cfg)
+
+				return nil
+			}},
+		},
+	}
+
+	if pool != nil {
+		reloader.Applies = append(reloader.Applies, reload.Apply{
+			Name: "database", Func: func(ctx context.Context, cfg *config.Config) error {
+				if pool.SetDatasource(cfg.Database.DBDatasource) {
+					log.Ctx(ctx).Info().Msg("database datasource changed, new connections use it")
+				}
+
+				if tenants != nil && tenants.SetDatasource(cfg.Database.DBDatasource) {
+					log.Ctx(ctx).Info().Msg("tenant datasources changed, new connections use them")
+				}
+
+				return nil
+			},
+		})
+	}
+
+	if limiter != nil {
+		reloader.Applies = append(reloader.Applies, reload.Apply{
+			Name: "rate_limit", Func: func(_ context.Context, cfg *config.Config) error {
+				return limiter.Update(cfg.RateLimit)
+			},
+		})
+	}
+
+	if chaosInjector != nil {
+		reloader.Applies = append(reloader.Applies, reload.Apply{
+			Name: "chaos", Func: func(_ context.Context, cfg *config.Config) error {
+				chaosInjector.Update(cfg.Chaos)
+
+				return nil
+			},
+		})
+	}
+
+	// //////////////////////////////////////////
+	// run listeners
 	g, ctx := errgroup.WithContext(ctx)
 
 	// run kafka consumer
+	if config.Application.EnableKafkaConsumer {
+		g.Go(func() error {
+			return kafkaClient.Consume(ctx, wkafka.WithCallback(handlerKafka.Consume))
+		})
+	}
+
+	// deliver webhooks
+	if webhooks != nil {
+		g.Go(func() error {
+			webhooks.Run(ctx)
+
+			return nil
+		})
+	}
+
+	// invalidations of the other replicas
+	if productCache != nil {
+		g.Go(func() error {
+			productCache.Run(ctx)
+
+			return nil
+		})
+	}
+
+	// remove expired idempotency keys
+	if idempotent != nil {
+		g.Go(func() error {
+			idempotent.Run(ctx)
+
+			return nil
+		})
+	}
+
+	// reload config
+	g.Go(func() error {
+		reloader.WatchSignal(ctx)
+
+		return nil
+	})
+
+	g.Go(func() error {
+		reloader.WatchFile(ctx, reload.SourceFile, config.FilePath(), config.Application.ConfigWatchInterval)
+
+		return nil
+	})
+
+	for _, file := range config.Application.SecretFiles() {
+		g.Go(func() error {
+			reloader.WatchFile(ctx, reload.SourceSecret, file, config.Application.ConfigWatchInterval)
+
+			return nil
+		})
+	}
+
+	g.Go(func() error {
+		reloader.WatchConsul(ctx)
+
+		return nil
+	})
+
+	// run admin server
 	g.Go(func() error {
-		return kafkaClient.Consume(ctx, wkafka.WithCallback(handlerKafka.Consume))
+		adminRouter.StopWithContext(ctx, initializer.WaitGroup(ctx))
+		return adminRouter.Start()
 	})
 
 	// run http server
 	g.Go(func() error {
+		context.AfterFunc(ctx, streams.Close)
 		router.StopWithContext(ctx, initializer.WaitGroup(ctx))
 		return router.Start()
 	})
 
+	// run grpc server
+	if grpcServer != nil {
+		g.Go(func() error {
+			grpcServer.StopWithContext(ctx, initializer.WaitGroup(ctx))
+			return grpcServer.Start()
+		})
+	}
+
 	return g.Wait()
 }
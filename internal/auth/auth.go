@@ -0,0 +1,300 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+var signatureAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+// Principal is the authenticated caller.
+type Principal struct {
+	Subject string
+	Scopes  []string
+	Method  string
+	// Tenant of the credentials, empty when they are not bound to a tenant.
+	Tenant string
+}
+
+// HasScopes reports whether all scopes granted to the principal.
+func (p *Principal) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		found := false
+		for _, s := range p.Scopes {
+			if s == scope {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+type ctxPrincipalKey struct{}
+
+// WithPrincipal adds principal to the context.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxPrincipalKey{}, p)
+}
+
+// PrincipalFromContext returns principal, nil if request not authenticated.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(ctxPrincipalKey{}).(*Principal)
+
+	return p
+}
+
+// Subject returns the authenticated subject or the fallback value.
+func Subject(ctx context.Context, fallback string) string {
+	if p := PrincipalFromContext(ctx); p != nil && p.Subject != "" {
+		return p.Subject
+	}
+
+	return fallback
+}
+
+type Auth struct {
+	cfg     config.Auth
+	keySet  *KeySet
+	apiKeys []config.APIKey
+}
+
+func New(ctx context.Context, cfg config.Auth) (*Auth, error) {
+	a := &Auth{
+		cfg:     cfg,
+		apiKeys: cfg.APIKeys,
+	}
+
+	if cfg.JWT.JWKSFile != "" || cfg.JWT.JWKSURL != "" {
+		keySet, err := NewKeySet(ctx, cfg.JWT.JWKSFile, cfg.JWT.JWKSURL, cfg.JWT.JWKSRefresh)
+		if err != nil {
+			return nil, err
+		}
+
+		a.keySet = keySet
+	}
+
+	if a.keySet == nil && len(a.apiKeys) == 0 {
+		return nil, errors.New("auth enabled without api keys or jwks")
+	}
+
+	return a, nil
+}
+
+// Middleware authenticates the request and checks the route's scopes.
+func (a *Auth) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+
+			principal, err := a.Check(ctx, c.Request().Method+" "+c.Path(), c.Request().Header.Get)
+			if err != nil {
+				if errors.Is(err, model.ErrUnauthorized) {
+					c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="`+config.ServiceName+`"`)
+				}
+
+				return err
+			}
+
+			c.SetRequest(c.Request().WithContext(WithPrincipal(ctx, principal)))
+
+			return next(c)
+		}
+	}
+}
+
+// Check authenticates with the header function and checks the scopes of the route like "POST /api/v1/count".
+func (a *Auth) Check(ctx context.Context, route string, header func(key string) string) (*Principal, error) {
+	principal, err := a.authenticate(ctx, header)
+	if err != nil {
+		return nil, &model.Error{
+			Kind:   model.ErrUnauthorized,
+			Detail: "authentication required",
+			Cause:  err,
+		}
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		attribute.String("enduser.id", principal.Subject),
+		attribute.String("enduser.scope", strings.Join(principal.Scopes, " ")),
+		attribute.String("auth.method", principal.Method),
+	)
+
+	if err := a.checkScopes(principal, route); err != nil {
+		return nil, err
+	}
+
+	return principal, nil
+}
+
+// Authorize checks the scopes of the route like "POST /api/v1/count" for the principal of the context.
+// Endpoints serving other routes like graphql use it, nil Auth allows all.
+func (a *Auth) Authorize(ctx context.Context, route string) error {
+	if a == nil {
+		return nil
+	}
+
+	principal := PrincipalFromContext(ctx)
+	if principal == nil {
+		return &model.Error{
+			Kind:   model.ErrUnauthorized,
+			Detail: "authentication required",
+		}
+	}
+
+	return a.checkScopes(principal, route)
+}
+
+func (a *Auth) checkScopes(principal *Principal, route string) error {
+	if scopes := a.cfg.RouteScopes[route]; !principal.HasScopes(scopes...) {
+		return &model.Error{
+			Kind:   model.ErrForbidden,
+			Detail: fmt.Sprintf("scopes [%s] required", strings.Join(scopes, " ")),
+		}
+	}
+
+	return nil
+}
+
+func (a *Auth) authenticate(ctx context.Context, header func(key string) string) (*Principal, error) {
+	if key := header(a.cfg.APIKeyHeader); key != "" {
+		return a.authenticateAPIKey(key)
+	}
+
+	if token, ok := strings.CutPrefix(header(echo.HeaderAuthorization), "Bearer "); ok {
+		return a.authenticateJWT(ctx, strings.TrimSpace(token))
+	}
+
+	return nil, errors.New("credentials not found")
+}
+
+func (a *Auth) authenticateAPIKey(key string) (*Principal, error) {
+	for _, apiKey := range a.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(apiKey.Key), []byte(key)) == 1 {
+			return &Principal{
+				Subject: apiKey.Subject,
+				Scopes:  apiKey.Scopes,
+				Method:  MethodAPIKey,
+				Tenant:  apiKey.Tenant,
+			}, nil
+		}
+	}
+
+	return nil, errors.New("invalid api key")
+}
+
+func (a *Auth) authenticateJWT(ctx context.Context, token string) (*Principal, error) {
+	if a.keySet == nil {
+		return nil, errors.New("jwt authentication not configured")
+	}
+
+	tok, err := jwt.ParseSigned(token, signatureAlgorithms)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse token; %w", err)
+	}
+
+	if len(tok.Headers) == 0 {
+		return nil, errors.New("token without header")
+	}
+
+	key, err := a.keySet.Key(ctx, tok.Headers[0].KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		claims jwt.Claims
+		extra  map[string]interface{}
+	)
+
+	if err := tok.Claims(key.Key, &claims, &extra); err != nil {
+		return nil, fmt.Errorf("failed to verify token; %w", err)
+	}
+
+	now := time.Now()
+
+	// Validate checks exp only when the token has it
+	if claims.Expiry == nil {
+		return nil, errors.New("token without expiry")
+	}
+
+	if claims.Expiry.Time().After(now.Add(a.cfg.JWT.MaxLifetime)) {
+		return nil, fmt.Errorf("token expiry exceeds max lifetime %s", a.cfg.JWT.MaxLifetime)
+	}
+
+	expected := jwt.Expected{
+		Issuer: a.cfg.JWT.Issuer,
+		Time:   now,
+	}
+
+	if a.cfg.JWT.Audience != "" {
+		expected.AnyAudience = jwt.Audience{a.cfg.JWT.Audience}
+	}
+
+	if err := claims.Validate(expected); err != nil {
+		return nil, fmt.Errorf("invalid token claims; %w", err)
+	}
+
+	return &Principal{
+		Subject: claims.Subject,
+		Scopes:  scopesFromClaim(extra[a.cfg.JWT.ScopeClaim]),
+		Method:  MethodJWT,
+		Tenant:  tenantFromClaim(extra[a.cfg.JWT.TenantClaim]),
+	}, nil
+}
+
+// scopesFromClaim accepts space separated string or list of strings.
+func scopesFromClaim(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		scopes := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				scopes = append(scopes, s)
+			}
+		}
+
+		return scopes
+	}
+
+	return nil
+}
+
+// tenantFromClaim accepts only a string.
+func tenantFromClaim(v interface{}) string {
+	tenant, _ := v.(string)
+
+	return tenant
+}
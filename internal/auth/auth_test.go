@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+)
+
+func TestAuthenticateJWT(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: key.Public(), KeyID: "test", Algorithm: string(jose.ES256), Use: "sig"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(file, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := New(context.Background(), config.Auth{JWT: config.JWT{
+		JWKSFile:    file,
+		Issuer:      "https://issuer.example",
+		MaxLifetime: time.Hour,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.ES256, Key: key},
+		(&jose.SignerOptions{}).WithHeader(jose.HeaderKey("kid"), "test"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		claims jwt.Claims
+		valid  bool
+	}{
+		{
+			name:   "valid",
+			claims: jwt.Claims{Issuer: "https://issuer.example", Subject: "svc", Expiry: jwt.NewNumericDate(now.Add(time.Minute))},
+			valid:  true,
+		},
+		{
+			name:   "without expiry",
+			claims: jwt.Claims{Issuer: "https://issuer.example", Subject: "svc"},
+		},
+		{
+			name:   "expiry over max lifetime",
+			claims: jwt.Claims{Issuer: "https://issuer.example", Subject: "svc", Expiry: jwt.NewNumericDate(now.Add(2 * time.Hour))},
+		},
+		{
+			name:   "expired",
+			claims: jwt.Claims{Issuer: "https://issuer.example", Subject: "svc", Expiry: jwt.NewNumericDate(now.Add(-time.Hour))},
+		},
+		{
+			name:   "other issuer",
+			claims: jwt.Claims{Issuer: "https://other.example", Subject: "svc", Expiry: jwt.NewNumericDate(now.Add(time.Minute))},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := jwt.Signed(signer).Claims(tt.claims).Serialize()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			principal, err := a.authenticateJWT(context.Background(), token)
+			if tt.valid && (err != nil || principal.Subject != "svc") {
+				t.Fatalf("want principal svc, got %v, %v", principal, err)
+			}
+
+			if !tt.valid && err == nil {
+				t.Fatal("want error, got principal")
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	// minRefetch limits fetching keys again when an unknown key id received.
+	minRefetch = 30 * time.Second
+	// maxBackoff is the longest wait after failed reloads.
+	maxBackoff = 5 * time.Minute
+)
+
+// KeySet holds the JWKS loaded from a file or URL and reloads it periodically.
+type KeySet struct {
+	file    string
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	// reload runs one load for the concurrent requests.
+	reload singleflight.Group
+
+	mutex     sync.RWMutex
+	keys      jose.JSONWebKeySet
+	fetchedAt time.Time
+	// failedAt and failures delay the next reload after errors.
+	failedAt time.Time
+	failures int
+}
+
+func NewKeySet(ctx context.Context, file, url string, refresh time.Duration) (*KeySet, error) {
+	k := &KeySet{
+		file:    file,
+		url:     url,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if err := k.load(ctx); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// Key returns the public key with the key id, keys reloaded if it is not found.
+// Cached keys are used when the reload fails, reloads wait with backoff after failures.
+func (k *KeySet) Key(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	k.mutex.RLock()
+	keys := k.keys.Key(kid)
+	fetchedAt := k.fetchedAt
+	retryAt := k.failedAt.Add(k.backoff())
+	k.mutex.RUnlock()
+
+	expired := k.refresh > 0 && time.Since(fetchedAt) > k.refresh
+	if (expired || (len(keys) == 0 && time.Since(fetchedAt) > minRefetch)) && time.Now().After(retryAt) {
+		_, err, _ := k.reload.Do("jwks", func() (interface{}, error) {
+			return nil, k.load(context.WithoutCancel(ctx))
+		})
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Msg("failed to reload jwks, using cached keys")
+		}
+
+		k.mutex.RLock()
+		keys = k.keys.Key(kid)
+		k.mutex.RUnlock()
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("key [%s] not found in jwks", kid)
+	}
+
+	return &keys[0], nil
+}
+
+// backoff doubles the wait for every failed reload, it is called with the lock.
+func (k *KeySet) backoff() time.Duration {
+	if k.failures == 0 {
+		return 0
+	}
+
+	wait := minRefetch << (k.failures - 1)
+	if wait <= 0 || wait > maxBackoff {
+		wait = maxBackoff
+	}
+
+	return wait
+}
+
+func (k *KeySet) load(ctx context.Context) error {
+	var (
+		raw []byte
+		err error
+	)
+
+	if k.file != "" {
+		raw, err = os.ReadFile(k.file)
+	} else {
+		raw, err = k.fetch(ctx)
+	}
+
+	if err != nil {
+		k.failed()
+
+		return fmt.Errorf("failed to load jwks; %w", err)
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		k.failed()
+
+		return fmt.Errorf("failed to parse jwks; %w", err)
+	}
+
+	k.mutex.Lock()
+	k.keys = keys
+	k.fetchedAt = time.Now()
+	k.failures = 0
+	k.mutex.Unlock()
+
+	return nil
+}
+
+func (k *KeySet) failed() {
+	k.mutex.Lock()
+	k.failedAt = time.Now()
+	k.failures++
+	k.mutex.Unlock()
+}
+
+func (k *KeySet) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // no need
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // no need
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, k.url)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20)) //nolint:wrapcheck // no need
+}
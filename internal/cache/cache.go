@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/telemetry"
+	"github.com/worldline-go/telemetry_example/internal/tenant"
+)
+
+const (
+	resultHit      = "hit"
+	resultMiss     = "miss"
+	resultNotFound = "not_found"
+)
+
+// Products caches the product reads, keys are scoped to the tenant.
+// A nil Products reads the database on every call.
+type Products struct {
+	store   Store
+	backend string
+	cfg     config.Cache
+	// redisClient publishes the invalidations to the other replicas with the broadcast.
+	redisClient *redis.Client
+}
+
+// invalidation is the message of the broadcast.
+type invalidation struct {
+	Tenant string `json:"tenant,omitempty"`
+	Name   string `json:"name"`
+}
+
+// entry is the cached value, a missing product is cached without the product.
+type entry struct {
+	Product *model.Product `json:"product,omitempty"`
+}
+
+// New creates the cache with the configured backend, redisClient is required for the redis backend and the broadcast.
+func New(cfg config.Cache, redisClient *redis.Client) *Products {
+	var store Store = newMemory(cfg.Size)
+	if cfg.Backend == BackendRedis {
+		store = redisStore{client: redisClient, prefix: config.ServiceName + ":product:", generationTTL: cfg.TTL}
+	}
+
+	p := &Products{
+		store:   store,
+		backend: cfg.Backend,
+		cfg:     cfg,
+	}
+
+	if cfg.Backend == BackendMemory && cfg.Broadcast {
+		p.redisClient = redisClient
+	}
+
+	return p
+}
+
+// channel of the invalidations between the replicas.
+func channel() string {
+	return config.ServiceName + ":product:invalidate"
+}
+
+// Get returns the cached product or reads it with load, missing products are cached with the not found TTL.
+// Store failures are logged and the product is read with load.
+func (p *Products) Get(ctx context.Context, name string, load func(ctx context.Context) (*model.Product, error)) (*model.Product, error) {
+	if p == nil {
+		return load(ctx)
+	}
+
+	span := trace.SpanFromContext(ctx)
+	key := p.key(ctx, name)
+
+	value, found, err := p.store.Get(ctx, key)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("product", name).Msg("failed to read product cache")
+	}
+
+	if found {
+		var e entry
+		if err := json.Unmarshal(value, &e); err == nil {
+			span.SetAttributes(attribute.Bool("cache.hit", true), attribute.String("cache.backend", p.backend))
+
+			if e.Product == nil {
+				p.count(ctx, resultNotFound)
+
+				return nil, notFound(name)
+			}
+
+			p.count(ctx, resultHit)
+
+			return e.Product, nil
+		}
+	}
+
+	span.SetAttributes(attribute.Bool("cache.hit", false), attribute.String("cache.backend", p.backend))
+	p.count(ctx, resultMiss)
+
+	// a change invalidated while loading changes the generation, the loaded value is not stored then
+	gen, errGen := p.store.Generation(ctx, key)
+	if errGen != nil {
+		log.Ctx(ctx).Warn().Err(errGen).Str("product", name).Msg("failed to read product cache generation")
+	}
+
+	product, err := load(ctx)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) && p.cfg.NotFoundTTL > 0 && errGen == nil {
+			p.set(ctx, key, entry{}, p.cfg.NotFoundTTL, gen)
+		}
+
+		return nil, err
+	}
+
+	if errGen == nil {
+		p.set(ctx, key, entry{Product: product}, p.cfg.TTL, gen)
+	}
+
+	return product, nil
+}
+
+// Invalidate removes the product of the context's tenant, next read gets it from the database.
+// With the broadcast, memory caches of the other replicas remove it too.
+func (p *Products) Invalidate(ctx context.Context, name string) {
+	if p == nil {
+		return
+	}
+
+	if err := p.store.Delete(ctx, p.key(ctx, name)); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("product", name).Msg("failed to invalidate product cache")
+	}
+
+	if p.redisClient == nil {
+		return
+	}
+
+	message, err := json.Marshal(invalidation{Tenant: tenant.FromContext(ctx), Name: name})
+	if err != nil {
+		return
+	}
+
+	if err := p.redisClient.Publish(ctx, channel(), message).Err(); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("product", name).Msg("failed to broadcast product cache invalidation")
+	}
+}
+
+// Run removes the products invalidated by the other replicas until the context is done, it is only needed with the broadcast.
+// Invalidations published while redis is not reachable are lost, the products are seen after the ttl then.
+func (p *Products) Run(ctx context.Context) {
+	if p == nil || p.redisClient == nil {
+		return
+	}
+
+	subscriber := p.redisClient.Subscribe(ctx, channel())
+	defer subscriber.Close()
+
+	messages := subscriber.Channel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			var inv invalidation
+			if err := json.Unmarshal([]byte(message.Payload), &inv); err != nil {
+				log.Warn().Err(err).Msg("invalid product cache invalidation")
+
+				continue
+			}
+
+			ctxTenant := tenant.WithID(ctx, inv.Tenant)
+			if err := p.store.Delete(ctxTenant, p.key(ctxTenant, inv.Name)); err != nil {
+				log.Warn().Err(err).Str("product", inv.Name).Msg("failed to invalidate product cache")
+			}
+		}
+	}
+}
+
+func (p *Products) set(ctx context.Context, key string, e entry, ttl time.Duration, gen uint64) {
+	value, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	if err := p.store.SetIfGeneration(ctx, key, value, ttl, gen); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Msg("failed to write product cache")
+	}
+}
+
+// key separates the tenants, tenant ids have no slash.
+func (p *Products) key(ctx context.Context, name string) string {
+	return tenant.FromContext(ctx) + "/" + name
+}
+
+func (p *Products) count(ctx context.Context, result string) {
+	telemetry.GlobalMeter.CacheCounter.Add(ctx, 1, metric.WithAttributes(
+		tenant.Attributes(ctx, attribute.String("cache", "product"), attribute.String("result", result))...,
+	))
+}
+
+func notFound(name string) error {
+	return &model.Error{
+		Kind:   model.ErrNotFound,
+		Detail: fmt.Sprintf("product [%s] not found", name),
+	}
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/telemetry"
+	"github.com/worldline-go/telemetry_example/internal/tenant"
+)
+
+func TestMain(m *testing.M) {
+	if err := telemetry.SetGlobalMeter(); err != nil {
+		panic(err)
+	}
+
+	m.Run()
+}
+
+func TestProductsGet(t *testing.T) {
+	type read struct {
+		ctx  context.Context
+		name string
+		// during runs in the load like a change of the product by another request.
+		during func(p *Products)
+		// found is false when the load returns not found.
+		found bool
+
+		wantLoaded bool
+		wantErr    error
+	}
+
+	ctx := context.Background()
+	acme := tenant.WithID(ctx, "acme")
+
+	tests := []struct {
+		name  string
+		size  int
+		reads []read
+	}{
+		{
+			name: "hit",
+			reads: []read{
+				{ctx: ctx, name: "apple", found: true, wantLoaded: true},
+				{ctx: ctx, name: "apple", found: true},
+			},
+		},
+		{
+			name: "invalidated",
+			reads: []read{
+				{ctx: ctx, name: "apple", found: true, wantLoaded: true},
+				{ctx: ctx, name: "pear", found: true, wantLoaded: true, during: func(p *Products) { p.Invalidate(ctx, "apple") }},
+				{ctx: ctx, name: "apple", found: true, wantLoaded: true},
+			},
+		},
+		{
+			name: "stale write after invalidation",
+			reads: []read{
+				{ctx: ctx, name: "apple", found: true, wantLoaded: true, during: func(p *Products) { p.Invalidate(ctx, "apple") }},
+				{ctx: ctx, name: "apple", found: true, wantLoaded: true},
+				{ctx: ctx, name: "apple", found: true},
+			},
+		},
+		{
+			name: "stale write after evicted invalidation",
+			size: 1,
+			reads: []read{
+				{ctx: ctx, name: "apple", found: true, wantLoaded: true, during: func(p *Products) {
+					p.Invalidate(ctx, "apple")
+					p.Invalidate(ctx, "pear")
+				}},
+				{ctx: ctx, name: "apple", found: true, wantLoaded: true},
+			},
+		},
+		{
+			name: "not found",
+			reads: []read{
+				{ctx: ctx, name: "apple", wantLoaded: true, wantErr: model.ErrNotFound},
+				{ctx: ctx, name: "apple", wantErr: model.ErrNotFound},
+			},
+		},
+		{
+			name: "tenants",
+			reads: []read{
+				{ctx: ctx, name: "apple", found: true, wantLoaded: true},
+				{ctx: acme, name: "apple", found: true, wantLoaded: true},
+				{ctx: acme, name: "apple", found: true, during: func(p *Products) { p.Invalidate(ctx, "apple") }},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := tt.size
+			if size == 0 {
+				size = 100
+			}
+
+			p := New(config.Cache{Backend: BackendMemory, Size: size, TTL: time.Minute, NotFoundTTL: time.Minute}, nil)
+
+			for i, r := range tt.reads {
+				loaded := false
+
+				product, err := p.Get(r.ctx, r.name, func(context.Context) (*model.Product, error) {
+					loaded = true
+
+					if r.during != nil {
+						r.during(p)
+					}
+
+					if !r.found {
+						return nil, notFound(r.name)
+					}
+
+					return &model.Product{Name: r.name}, nil
+				})
+
+				if loaded != r.wantLoaded {
+					t.Fatalf("read %d: want loaded %t, got %t", i, r.wantLoaded, loaded)
+				}
+
+				if r.wantErr != nil {
+					if !errors.Is(err, r.wantErr) {
+						t.Fatalf("read %d: want error %v, got %v", i, r.wantErr, err)
+					}
+
+					continue
+				}
+
+				if err != nil || product.Name != r.name {
+					t.Fatalf("read %d: want product %s, got %v, %v", i, r.name, product, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryExpiry(t *testing.T) {
+	ctx := context.Background()
+	m := newMemory(10)
+
+	gen, _ := m.Generation(ctx, "apple")
+	_ = m.SetIfGeneration(ctx, "apple", []byte("v"), time.Millisecond, gen)
+
+	if _, found, _ := m.Get(ctx, "apple"); !found {
+		t.Fatal("value is not stored")
+	}
+
+	time.Sleep(2 * time.Millisecond)
+
+	if _, found, _ := m.Get(ctx, "apple"); found {
+		t.Fatal("expired value is returned")
+	}
+}
@@ -0,0 +1,209 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// Store keeps the values until they expire.
+type Store interface {
+	// Get returns false when the key is missing or expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Generation of the key changes with every Delete of it.
+	Generation(ctx context.Context, key string) (uint64, error)
+	// SetIfGeneration stores the value only when the generation of the key is still gen.
+	// A value read before a Delete is not stored after it.
+	SetIfGeneration(ctx context.Context, key string, value []byte, ttl time.Duration, gen uint64) error
+	Delete(ctx context.Context, key string) error
+}
+
+// memory is the store of a single replica, the least recently used key is evicted when it is full.
+// Deleted keys are kept as tombstones with their generation, keys without one use the generation of the last Delete.
+type memory struct {
+	mutex sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+	// generation is increased with every Delete.
+	generation uint64
+}
+
+type memoryItem struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+	// deleted items are tombstones, they are not returned.
+	deleted    bool
+	generation uint64
+}
+
+func newMemory(size int) *memory {
+	return &memory{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+func (m *memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	element, ok := m.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	item := element.Value.(*memoryItem) //nolint:forcetypeassert // only items are stored
+	if item.deleted {
+		return nil, false, nil
+	}
+
+	if time.Now().After(item.expiresAt) {
+		m.remove(element)
+
+		return nil, false, nil
+	}
+
+	m.order.MoveToFront(element)
+
+	return item.value, true, nil
+}
+
+func (m *memory) Generation(_ context.Context, key string) (uint64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.keyGeneration(key), nil
+}
+
+func (m *memory) SetIfGeneration(_ context.Context, key string, value []byte, ttl time.Duration, gen uint64) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.keyGeneration(key) != gen {
+		return nil
+	}
+
+	m.set(&memoryItem{key: key, value: value, expiresAt: time.Now().Add(ttl), generation: gen})
+
+	return nil
+}
+
+// Delete replaces the value with a tombstone, it is evicted like the values.
+func (m *memory) Delete(_ context.Context, key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.generation++
+	m.set(&memoryItem{key: key, deleted: true, generation: m.generation})
+
+	return nil
+}
+
+// keyGeneration is the generation of the tombstone or the value of the key.
+// An evicted key uses the last generation, so a Delete after the read always changes it.
+func (m *memory) keyGeneration(key string) uint64 {
+	if element, ok := m.items[key]; ok {
+		return element.Value.(*memoryItem).generation //nolint:forcetypeassert // only items are stored
+	}
+
+	return m.generation
+}
+
+func (m *memory) set(item *memoryItem) {
+	if element, ok := m.items[item.key]; ok {
+		element.Value = item
+		m.order.MoveToFront(element)
+
+		return
+	}
+
+	m.items[item.key] = m.order.PushFront(item)
+
+	for m.order.Len() > m.size {
+		m.remove(m.order.Back())
+	}
+}
+
+func (m *memory) remove(element *list.Element) {
+	m.order.Remove(element)
+	delete(m.items, element.Value.(*memoryItem).key) //nolint:forcetypeassert // only items are stored
+}
+
+// redisStore shares the values between replicas, keys expire with the redis TTL.
+// Generations are counters in the "<key>#gen" keys, they expire after generationTTL.
+type redisStore struct {
+	client        *redis.Client
+	prefix        string
+	generationTTL time.Duration
+}
+
+// setIfGeneration sets the value when the generation key has the expected value, a missing one is 0.
+var setIfGeneration = redis.NewScript(`
+local gen = redis.call('GET', KEYS[2]) or '0'
+if gen == ARGV[3] then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+end
+return 0
+`)
+
+func (r redisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, false, nil
+		}
+
+		return nil, false, err //nolint:wrapcheck // no need
+	}
+
+	return value, true, nil
+}
+
+func (r redisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, r.prefix+key, value, ttl).Err() //nolint:wrapcheck // no need
+}
+
+func (r redisStore) Generation(ctx context.Context, key string) (uint64, error) {
+	gen, err := r.client.Get(ctx, r.prefix+key+"#gen").Uint64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+
+		return 0, err //nolint:wrapcheck // no need
+	}
+
+	return gen, nil
+}
+
+func (r redisStore) SetIfGeneration(ctx context.Context, key string, value []byte, ttl time.Duration, gen uint64) error {
+	return setIfGeneration.Run(ctx, r.client,
+		[]string{r.prefix + key, r.prefix + key + "#gen"},
+		value, ttl.Milliseconds(), strconv.FormatUint(gen, 10),
+	).Err() //nolint:wrapcheck // no need
+}
+
+func (r redisStore) Delete(ctx context.Context, key string) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, r.prefix+key)
+		pipe.Incr(ctx, r.prefix+key+"#gen")
+		pipe.PExpire(ctx, r.prefix+key+"#gen", r.generationTTL)
+
+		return nil
+	})
+
+	return err //nolint:wrapcheck // no need
+}
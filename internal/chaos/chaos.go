@@ -0,0 +1,265 @@
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/auth"
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/telemetry"
+	"github.com/worldline-go/telemetry_example/internal/tenant"
+)
+
+// HeaderFault is the JSON fault of the request, it replaces the fault of the rules when it is allowed.
+const HeaderFault = "X-Chaos-Fault"
+
+const (
+	KindLatency = "latency"
+	KindError   = "error"
+	KindPanic   = "panic"
+	KindDB      = "db"
+	KindKafka   = "kafka"
+)
+
+// ErrInjected is the cause of the injected failures.
+var ErrInjected = errors.New("injected fault")
+
+type faultKey struct{}
+
+// WithFault returns the context with the fault used by Fail.
+func WithFault(ctx context.Context, fault *model.Fault) context.Context {
+	return context.WithValue(ctx, faultKey{}, fault)
+}
+
+// FromContext returns the fault of the request, nil if there is none.
+func FromContext(ctx context.Context) *model.Fault {
+	fault, _ := ctx.Value(faultKey{}).(*model.Fault)
+
+	return fault
+}
+
+// Chaos injects the fault of the first matching rule to the requests.
+type Chaos struct {
+	cfg atomic.Pointer[config.Chaos]
+}
+
+func New(cfg config.Chaos) *Chaos {
+	c := &Chaos{}
+	c.Update(cfg)
+
+	return c
+}
+
+// Update replaces the rules and the request settings.
+func (c *Chaos) Update(cfg config.Chaos) {
+	c.cfg.Store(&cfg)
+}
+
+func (c *Chaos) match(path string) *model.Fault {
+	for _, r := range c.cfg.Load().Rules {
+		if strings.HasPrefix(path, r.Route) {
+			fault := r.Fault
+
+			return &fault
+		}
+	}
+
+	return nil
+}
+
+// Middleware adds latency, errors and panics before the handler and keeps the fault in the context
+// for the database and kafka failures.
+func (c *Chaos) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ec echo.Context) error {
+			fault := c.match(ec.Path())
+
+			if value := ec.Request().Header.Get(HeaderFault); value != "" {
+				fault = &model.Fault{}
+				if err := json.Unmarshal([]byte(value), fault); err != nil {
+					return echo.NewHTTPError(http.StatusBadRequest, HeaderFault+" is invalid").SetInternal(err)
+				}
+
+				if err := ec.Validate(fault); err != nil {
+					return err //nolint:wrapcheck // model.ValidationError
+				}
+
+				if err := c.allowed(ec.Request().Context()); err != nil {
+					return err
+				}
+			}
+
+			if !fault.Enabled() {
+				return next(ec)
+			}
+
+			ctx, err := c.Inject(ec.Request().Context(), fault)
+			if err != nil {
+				return err
+			}
+
+			ec.SetRequest(ec.Request().WithContext(ctx))
+
+			return next(ec)
+		}
+	}
+}
+
+// InjectRoute injects the fault of the first rule matching the route like "/api/v1/products".
+func (c *Chaos) InjectRoute(ctx context.Context, route string) (context.Context, error) {
+	return c.Inject(ctx, c.match(route))
+}
+
+// InjectRequest injects the fault of the request body, it needs allow_request and the request scope.
+func (c *Chaos) InjectRequest(ctx context.Context, fault *model.Fault) (context.Context, error) {
+	if !fault.Enabled() {
+		return ctx, nil
+	}
+
+	if c != nil {
+		if err := c.allowed(ctx); err != nil {
+			return ctx, err
+		}
+	}
+
+	return c.Inject(ctx, fault)
+}
+
+// allowed returns forbidden error when the principal can't send faults.
+func (c *Chaos) allowed(ctx context.Context) error {
+	cfg := c.cfg.Load()
+	if !cfg.AllowRequest {
+		return &model.Error{
+			Kind:   model.ErrForbidden,
+			Detail: "faults of the requests are not allowed",
+		}
+	}
+
+	if p := auth.PrincipalFromContext(ctx); p == nil || !p.HasScopes(cfg.RequestScope) {
+		return &model.Error{
+			Kind:   model.ErrForbidden,
+			Detail: "scope [" + cfg.RequestScope + "] is required for the faults of the requests",
+		}
+	}
+
+	return nil
+}
+
+// Inject adds the latency, returns the error or panics with the rates of the fault.
+// Returned context has the fault for Fail, chaos should be enabled to inject faults.
+func (c *Chaos) Inject(ctx context.Context, fault *model.Fault) (context.Context, error) {
+	if !fault.Enabled() {
+		return ctx, nil
+	}
+
+	if c == nil {
+		return ctx, echo.NewHTTPError(http.StatusBadRequest, "chaos is not enabled")
+	}
+
+	ctx = WithFault(ctx, fault)
+
+	if wait := latency(fault.Latency); wait > 0 {
+		record(ctx, KindLatency, attribute.String("chaos.latency", wait.String()))
+
+		select {
+		case <-ctx.Done():
+			return ctx, ctx.Err() //nolint:wrapcheck // no need
+		case <-time.After(wait):
+		}
+	}
+
+	if hit(fault.PanicRate) {
+		record(ctx, KindPanic)
+
+		panic(ErrInjected)
+	}
+
+	if hit(fault.ErrorRate) {
+		status := fault.Status
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+
+		record(ctx, KindError, attribute.Int("chaos.status", status))
+
+		return ctx, echo.NewHTTPError(status, ErrInjected.Error()).SetInternal(ErrInjected)
+	}
+
+	return ctx, nil
+}
+
+// Fail returns ErrInjected with the rate of the kind in the fault of the context.
+func Fail(ctx context.Context, kind string) error {
+	fault := FromContext(ctx)
+	if fault == nil {
+		return nil
+	}
+
+	var rate float64
+	switch kind {
+	case KindDB:
+		rate = fault.DBErrorRate
+	case KindKafka:
+		rate = fault.KafkaErrorRate
+	}
+
+	if !hit(rate) {
+		return nil
+	}
+
+	record(ctx, kind)
+
+	return fmt.Errorf("%s failure: %w", kind, ErrInjected)
+}
+
+func hit(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate //nolint:gosec // no need
+}
+
+func latency(l model.Latency) time.Duration {
+	if l.Rate > 0 && !hit(l.Rate) {
+		return 0
+	}
+
+	var wait time.Duration
+
+	switch l.Distribution {
+	case model.LatencyUniform:
+		wait = l.Duration
+		if l.Spread > 0 {
+			wait += rand.N(l.Spread) //nolint:gosec // no need
+		}
+	case model.LatencyNormal:
+		wait = l.Duration + time.Duration(rand.NormFloat64()*float64(l.Spread)) //nolint:gosec // no need
+	case model.LatencyExponential:
+		wait = time.Duration(rand.ExpFloat64() * float64(l.Duration)) //nolint:gosec // no need
+	default:
+		wait = l.Duration
+	}
+
+	return max(wait, 0)
+}
+
+func record(ctx context.Context, kind string, attrs ...attribute.KeyValue) {
+	attrs = append(attrs, attribute.String("chaos.kind", kind))
+
+	trace.SpanFromContext(ctx).AddEvent("chaos.fault", trace.WithAttributes(attrs...))
+
+	telemetry.GlobalMeter.ChaosFaultCounter.Add(ctx, 1,
+		metric.WithAttributes(tenant.Attributes(ctx)...),
+		metric.WithAttributes(attribute.String("kind", kind)),
+	)
+}
@@ -3,13 +3,19 @@ package config
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog/log"
 	"github.com/worldline-go/igconfig"
 	"github.com/worldline-go/igconfig/loader"
 	"github.com/worldline-go/klient"
-	"github.com/worldline-go/logz"
 	"github.com/worldline-go/telemetry_example/internal/database/dbutil"
+	"github.com/worldline-go/telemetry_example/internal/logging"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/secret"
 	"github.com/worldline-go/tell"
 	"github.com/worldline-go/wkafka"
 )
@@ -29,15 +35,42 @@ type OverrideHold struct {
 	Value  string
 }
 
-var Application = struct {
+var Application = Config{}
+
+type Config struct {
 	LogLevel string `cfg:"log_level" default:"info"`
+	// LogRoutes level per route prefix like {"/api/v1/products": "debug"}
+	LogRoutes map[string]string `cfg:"log_routes"`
+	// LogPackages level per package like {"kafka": "debug"}
+	LogPackages map[string]string `cfg:"log_packages"`
+	// LogSampleRate logs 1 of every N events below warn level, 0 logs all.
+	LogSampleRate uint32 `cfg:"log_sample_rate"`
+
 	Host     string `cfg:"host"      default:"0.0.0.0"`
 	Port     string `cfg:"port"      default:"8080"`
 	BasePath string `cfg:"base_path"`
+	// TrustedProxies ranges like "10.0.0.0/8" to read the client IP from X-Forwarded-For,
+	// empty uses the address of the connection.
+	TrustedProxies []string `cfg:"trusted_proxies"`
+	// AdminPort for health, readiness and debug endpoints
+	AdminPort string `cfg:"admin_port" default:"8081"`
+	// GRPCPort for the gRPC api, enabled with EnableGRPC
+	GRPCPort string `cfg:"grpc_port" default:"9090"`
+
+	// ConfigWatchInterval to check the config file for changes, zero disables it.
+	ConfigWatchInterval time.Duration `cfg:"config_watch_interval" default:"10s"`
 
 	EnableKafkaConsumer bool `cfg:"enable_kafka_consumer"`
 	EnableKafkaProducer bool `cfg:"enable_kafka_producer"`
 	EnableDatabase      bool `cfg:"enable_database"`
+	EnableAuth          bool `cfg:"enable_auth"`
+	EnableRateLimit     bool `cfg:"enable_rate_limit"`
+	EnableChaos         bool `cfg:"enable_chaos"`
+	EnableGRPC          bool `cfg:"enable_grpc"`
+	EnableWebhooks      bool `cfg:"enable_webhooks"`
+	EnableIdempotency   bool `cfg:"enable_idempotency"`
+	EnableTenancy       bool `cfg:"enable_tenancy"`
+	EnableCache         bool `cfg:"enable_cache"`
 
 	KafkaConfig wkafka.Config `cfg:"kafka_config"`
 	// KafkaConsumer for consuming example
@@ -47,11 +80,64 @@ var Application = struct {
 
 	// API for talk with http calls
 	API map[string]klient.Config `cfg:"api"`
+	// CallPolicy default of the outbound calls to API services.
+	CallPolicy CallPolicy `cfg:"call_policy"`
+	// CallPolicies per API service, zero fields use CallPolicy values.
+	// Set retry.max or breaker.failures to -1 to disable them for a service.
+	CallPolicies map[string]CallPolicy `cfg:"call_policies"`
 
 	Telemetry tell.Config
 
 	Database Database `cfg:"database"`
-}{}
+
+	// Auth for api endpoints, enabled with EnableAuth
+	Auth Auth `cfg:"auth"`
+
+	// RateLimit for api endpoints, enabled with EnableRateLimit
+	RateLimit RateLimit `cfg:"rate_limit"`
+
+	// Chaos faults for api endpoints, enabled with EnableChaos
+	Chaos Chaos `cfg:"chaos"`
+
+	// Stream of the counter and product changes
+	Stream Stream `cfg:"stream"`
+
+	// Webhook deliveries of the product events, enabled with EnableWebhooks
+	Webhook Webhook `cfg:"webhook"`
+
+	// Idempotency keys of the POST endpoints, enabled with EnableIdempotency
+	Idempotency Idempotency `cfg:"idempotency"`
+
+	// Search of the products
+	Search Search `cfg:"search"`
+
+	// Tenancy with a database schema per tenant, enabled with EnableTenancy
+	Tenancy Tenancy `cfg:"tenancy"`
+
+	// Cache of the product reads, enabled with EnableCache
+	Cache Cache `cfg:"cache"`
+
+	// Redis for shared state between replicas
+	Redis Redis `cfg:"redis"`
+
+	// secretFiles are the files of secret references to watch for rotation
+	secretFiles []string
+}
+
+// SecretFiles returns the files of the resolved file:// secret references.
+func (c *Config) SecretFiles() []string {
+	return c.secretFiles
+}
+
+// LogSettings returns the runtime changeable log settings.
+func (c *Config) LogSettings() logging.Settings {
+	return logging.Settings{
+		Level:      c.LogLevel,
+		Routes:     c.LogRoutes,
+		Packages:   c.LogPackages,
+		SampleRate: c.LogSampleRate,
+	}
+}
 
 type Database struct {
 	DBDatasource string `cfg:"db_datasource" log:"false"`
@@ -68,37 +154,336 @@ type Migrate struct {
 	DBTable      string `cfg:"db_table"      default:"migration"`
 }
 
+type Auth struct {
+	// APIKeyHeader to read static api keys from.
+	APIKeyHeader string   `cfg:"api_key_header" default:"X-API-Key"`
+	APIKeys      []APIKey `cfg:"api_keys"`
+
+	JWT JWT `cfg:"jwt"`
+
+	// RouteScopes required scopes for routes, key is method and echo path like "POST /api/v1/count".
+	// Routes not in the map just need an authenticated subject.
+	RouteScopes map[string][]string `cfg:"route_scopes"`
+}
+
+type APIKey struct {
+	Key     string   `cfg:"key"     log:"false"`
+	Subject string   `cfg:"subject"`
+	Scopes  []string `cfg:"scopes"`
+	// Tenant of the key, requests with it can only use this tenant.
+	Tenant string `cfg:"tenant"`
+}
+
+type JWT struct {
+	// JWKSFile or JWKSURL to get public keys, one of them is required to enable JWT.
+	JWKSFile string `cfg:"jwks_file"`
+	JWKSURL  string `cfg:"jwks_url"`
+	// JWKSRefresh interval to reload keys.
+	JWKSRefresh time.Duration `cfg:"jwks_refresh" default:"10m"`
+
+	// Issuer of the tokens, required with the jwks.
+	Issuer   string `cfg:"issuer"`
+	Audience string `cfg:"audience"`
+	// MaxLifetime of the tokens, tokens without exp or expiring later are rejected.
+	MaxLifetime time.Duration `cfg:"max_lifetime" default:"24h"`
+	// ScopeClaim to read scopes, space separated string or list.
+	ScopeClaim string `cfg:"scope_claim" default:"scope"`
+	// TenantClaim to read the tenant, requests with it can only use this tenant.
+	TenantClaim string `cfg:"tenant_claim" default:"tenant"`
+}
+
+type RateLimit struct {
+	// Shared keeps the token buckets in redis, local buckets used when redis is not reachable.
+	Shared bool            `cfg:"shared"`
+	Rules  []RateLimitRule `cfg:"rules"`
+	// AuthFailures limits the failed authentications per client IP,
+	// requests of the IP are rejected before the authentication when its bucket is empty.
+	AuthFailures AuthFailures `cfg:"auth_failures"`
+}
+
+type AuthFailures struct {
+	// Rate of the allowed failures per second, zero disables the limit.
+	Rate  float64 `cfg:"rate"  default:"0.1"`
+	Burst int     `cfg:"burst" default:"10"`
+}
+
+type RateLimitRule struct {
+	// Route prefix of the echo path like "/api/v1/products", empty matches all routes.
+	// First matching rule is used.
+	Route string `cfg:"route"`
+	// KeyBy is one of ip, api_key, header; default is ip.
+	// api_key uses the authenticated subject of the api key or JWT, ip without authentication.
+	KeyBy string `cfg:"key_by"`
+	// Header name when KeyBy is header.
+	Header string `cfg:"header"`
+
+	// Rate is requests per second refilled to the bucket, zero disables rate limiting.
+	Rate float64 `cfg:"rate"`
+	// Burst is the bucket size, default is 1.
+	Burst int `cfg:"burst"`
+	// Concurrency is the max in-flight requests per key, zero disables it.
+	Concurrency int `cfg:"concurrency"`
+}
+
+type Chaos struct {
+	// AllowRequest accepts the faults of the X-Chaos-Fault header and the request bodies
+	// from the principals with the RequestScope.
+	AllowRequest bool   `cfg:"allow_request"`
+	RequestScope string `cfg:"request_scope" default:"chaos"`
+
+	Rules []ChaosRule `cfg:"rules"`
+}
+
+type ChaosRule struct {
+	// Route prefix of the echo path like "/api/v1/products", empty matches all routes.
+	// First matching rule is used, allowed X-Chaos-Fault header replaces it.
+	Route string      `cfg:"route"`
+	Fault model.Fault `cfg:"fault"`
+}
+
+type Stream struct {
+	// Buffer of events per subscriber, a subscriber with a full buffer is disconnected.
+	Buffer int `cfg:"buffer" default:"64"`
+	// Heartbeat interval to keep the idle streams open behind proxies.
+	Heartbeat time.Duration `cfg:"heartbeat" default:"15s"`
+	// MaxSubscribers limits the open streams, zero is unlimited.
+	MaxSubscribers int `cfg:"max_subscribers" default:"1000"`
+}
+
+type Webhook struct {
+	// Timeout of each delivery attempt.
+	Timeout time.Duration `cfg:"timeout" default:"5s"`
+	// MaxAttempts of a delivery, failed attempts are retried with exponential backoff.
+	MaxAttempts int `cfg:"max_attempts" default:"5"`
+	// Backoff before the second attempt, doubled for the next attempts until MaxBackoff.
+	Backoff    time.Duration `cfg:"backoff"     default:"1s"`
+	MaxBackoff time.Duration `cfg:"max_backoff" default:"1m"`
+	// Concurrency of the deliveries, events are queued when all are busy.
+	Concurrency int `cfg:"concurrency" default:"8"`
+	// Queue of the events waiting for delivery, events are dropped when it is full.
+	Queue int `cfg:"queue" default:"1000"`
+	// SecretKey encrypts the webhook secrets in the database, changing it makes the stored secrets unreadable.
+	SecretKey string `cfg:"secret_key" log:"false"`
+	// Retention of the recorded delivery attempts.
+	Retention time.Duration `cfg:"retention" default:"168h"`
+	// AllowPrivate lets the webhooks use loopback, private and link-local addresses, only for local setups.
+	AllowPrivate bool `cfg:"allow_private"`
+}
+
+type Idempotency struct {
+	// Store is memory or postgres, postgres shares the keys between replicas.
+	Store string `cfg:"store" default:"memory"`
+	// TTL of the stored responses.
+	TTL time.Duration `cfg:"ttl" default:"24h"`
+	// MaxBody of the requests with the key in bytes, larger requests are rejected.
+	MaxBody int64 `cfg:"max_body" default:"1048576"`
+}
+
+type Search struct {
+	// Backend is postgres or memory, empty uses postgres with enable_database and memory without it.
+	// Memory only finds the products changed or received by this replica.
+	Backend string `cfg:"backend"`
+}
+
+type Tenancy struct {
+	// Header to read the tenant, the tenant of the credentials must be the same when both are set.
+	// Credentials without a tenant need the AdminScope to use it.
+	Header string `cfg:"header" default:"X-Tenant-ID"`
+	// SchemaPrefix of the tenant schemas, tenant "acme" uses the "tenant_acme" schema.
+	SchemaPrefix string `cfg:"schema_prefix" default:"tenant_"`
+	// Required rejects the requests without a tenant, otherwise they use the main schema.
+	Required bool `cfg:"required"`
+	// AdminScope lets the credentials without a tenant choose the tenant with the header and provision tenants.
+	AdminScope string `cfg:"admin_scope" default:"tenant:admin"`
+	// MaxTenants is the number of the open tenant pools, more tenants are not opened.
+	MaxTenants int `cfg:"max_tenants" default:"100"`
+	// MaxOpenConns of each tenant pool.
+	MaxOpenConns int `cfg:"max_open_conns" default:"2"`
+	// NotFoundTTL caches the missing tenants to not look them up on every request.
+	NotFoundTTL time.Duration `cfg:"not_found_ttl" default:"30s"`
+}
+
+type Cache struct {
+	// Backend is memory or redis, redis shares the cache between replicas.
+	Backend string `cfg:"backend" default:"memory"`
+	// Size of the memory cache, least recently used products are evicted.
+	Size int `cfg:"size" default:"10000"`
+	// TTL of the cached products.
+	TTL time.Duration `cfg:"ttl" default:"5m"`
+	// NotFoundTTL of the missing products, zero disables caching them.
+	NotFoundTTL time.Duration `cfg:"not_found_ttl" default:"30s"`
+	// Broadcast publishes the invalidations on redis to the memory caches of the other replicas.
+	// Memory backend without it is only for a single replica.
+	Broadcast bool `cfg:"broadcast"`
+}
+
+type CallPolicy struct {
+	// Timeout of each attempt.
+	Timeout time.Duration `cfg:"timeout" default:"5s"`
+
+	Retry   Retry   `cfg:"retry"`
+	Breaker Breaker `cfg:"breaker"`
+}
+
+type Retry struct {
+	// Max retries after the first attempt, zero or negative disables retry.
+	Max int `cfg:"max" default:"2"`
+	// WaitMin and WaitMax bound the exponential backoff with full jitter.
+	WaitMin time.Duration `cfg:"wait_min" default:"100ms"`
+	WaitMax time.Duration `cfg:"wait_max" default:"2s"`
+}
+
+type Breaker struct {
+	// Failures in a row to open the circuit, zero or negative disables the breaker.
+	Failures int `cfg:"failures" default:"5"`
+	// OpenTimeout to wait before probing with half-open requests.
+	OpenTimeout time.Duration `cfg:"open_timeout" default:"10s"`
+	// HalfOpenRequests allowed at the same time to probe, all should succeed to close the circuit.
+	HalfOpenRequests int `cfg:"half_open_requests" default:"1"`
+}
+
+// Policy returns the call policy of the service merged with the default one.
+func (c *Config) Policy(service string) CallPolicy {
+	policy := c.CallPolicy
+
+	override, ok := c.CallPolicies[service]
+	if !ok {
+		return policy
+	}
+
+	if override.Timeout != 0 {
+		policy.Timeout = override.Timeout
+	}
+
+	if override.Retry.Max != 0 {
+		policy.Retry.Max = override.Retry.Max
+	}
+
+	if override.Retry.WaitMin != 0 {
+		policy.Retry.WaitMin = override.Retry.WaitMin
+	}
+
+	if override.Retry.WaitMax != 0 {
+		policy.Retry.WaitMax = override.Retry.WaitMax
+	}
+
+	if override.Breaker.Failures != 0 {
+		policy.Breaker.Failures = override.Breaker.Failures
+	}
+
+	if override.Breaker.OpenTimeout != 0 {
+		policy.Breaker.OpenTimeout = override.Breaker.OpenTimeout
+	}
+
+	if override.Breaker.HalfOpenRequests != 0 {
+		policy.Breaker.HalfOpenRequests = override.Breaker.HalfOpenRequests
+	}
+
+	return policy
+}
+
+type Redis struct {
+	Address  string `cfg:"address"`
+	Username string `cfg:"username"`
+	Password string `cfg:"password" log:"false"`
+	DB       int    `cfg:"db"`
+}
+
+// NewClient returns redis client, connection is not checked.
+func (r Redis) NewClient() *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     r.Address,
+		Username: r.Username,
+		Password: r.Password,
+		DB:       r.DB,
+	})
+}
+
 func Load(ctx context.Context) error {
+	cfg, err := LoadConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	Application = *cfg
+
+	// set log again to get changes
+	if err := logging.Apply(Application.LogSettings()); err != nil {
+		return err //nolint:wrapcheck // no need
+	}
+
+	// print loaded object
+	log.Info().Interface("config", Masked(Application)).Msg("loaded config")
+
+	return nil
+}
+
+// LoadConfig reads and validates the configuration without changing the Application.
+func LoadConfig(ctx context.Context) (*Config, error) {
+	cfg, err := Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+
+	return cfg, nil
+}
+
+// Read loads the configuration from all loaders without validation.
+func Read(ctx context.Context) (*Config, error) {
 	loaders := []loader.Loader{
 		&loader.Default{},
+		&loader.Consul{},
+		&loader.Vault{},
 		&loader.File{},
 		&loader.Env{},
 	}
 
-	if err := igconfig.LoadWithLoadersWithContext(ctx, ServiceName, &Application, loaders...); err != nil {
-		return fmt.Errorf("unable to load prefix settings: %w", err)
+	cfg := &Config{}
+	if err := igconfig.LoadWithLoadersWithContext(ctx, ServiceName, cfg, loaders...); err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
 	}
 
-	// set log again to get changes
-	if err := logz.SetLogLevel(Application.LogLevel); err != nil {
-		return err //nolint:wrapcheck // no need
+	resolver := &secret.Resolver{}
+	if err := resolver.ResolveAll(ctx, cfg); err != nil {
+		return nil, err //nolint:wrapcheck // no need
 	}
 
-	if Application.EnableDatabase {
-		dbDatasource, err := dbutil.SetDBSchema(Application.Database.DBDatasource, Application.Database.DBSchema)
+	cfg.secretFiles = resolver.Files
+
+	if cfg.EnableDatabase {
+		dbDatasource, err := dbutil.SetDBSchema(cfg.Database.DBDatasource, cfg.Database.DBSchema)
 		if err != nil {
-			return fmt.Errorf("failed to set db schema: %w", err)
+			return nil, fmt.Errorf("failed to set db schema: %w", err)
 		}
 
-		Application.Database.DBDatasource = dbDatasource
+		cfg.Database.DBDatasource = dbDatasource
 
-		if Application.Database.Migrate.DBDatasource == "" {
-			Application.Database.Migrate.DBDatasource = Application.Database.DBDatasource
+		if cfg.Database.Migrate.DBDatasource == "" {
+			cfg.Database.Migrate.DBDatasource = cfg.Database.DBDatasource
 		}
 	}
 
-	// print loaded object
-	log.Info().Object("config", igconfig.Printer{Value: Application}).Msg("loaded config")
+	return cfg, nil
+}
 
-	return nil
+// FilePath returns the configuration file read by the file loader, empty if there is none.
+func FilePath() string {
+	if file := os.Getenv(loader.EnvConfigFile); file != "" {
+		return file
+	}
+
+	for _, dir := range []string{".", "/etc"} {
+		for _, suffix := range loader.ConfFileSuffixes {
+			file := filepath.Join(dir, ServiceName+suffix)
+			if _, err := os.Stat(file); err == nil {
+				return file
+			}
+		}
+	}
+
+	return ""
 }
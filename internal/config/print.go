@@ -0,0 +1,169 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/worldline-go/klient"
+
+	"github.com/worldline-go/telemetry_example/internal/secret"
+)
+
+// Mask replaces the values of the fields tagged with log:"false".
+const Mask = secret.Mask
+
+// Masked returns the value as maps and lists with the config names to print it.
+// Fields tagged with log:"false" are masked when they are set, resolved secrets are redacted.
+// Values of the credential headers of the api clients like Authorization are masked.
+func Masked(v any) any {
+	value, _ := masked(reflect.ValueOf(v))
+
+	return value
+}
+
+func masked(v reflect.Value) (any, bool) {
+	if !v.IsValid() {
+		return nil, true
+	}
+
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		return time.Duration(v.Int()).String(), true
+	}
+
+	if v.CanInterface() {
+		if m, ok := v.Interface().(encoding.TextMarshaler); ok && v.Kind() != reflect.Pointer {
+			text, err := m.MarshalText()
+			if err == nil {
+				return string(text), true
+			}
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, true
+		}
+
+		return masked(v.Elem())
+	case reflect.Struct:
+		fields := make(map[string]any, v.NumField())
+
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+
+			name := fieldName(f)
+			if name == "-" {
+				continue
+			}
+
+			if hidden(f) {
+				if v.Field(i).IsZero() {
+					fields[name] = nil
+				} else {
+					fields[name] = Mask
+				}
+
+				continue
+			}
+
+			if value, ok := masked(v.Field(i)); ok {
+				fields[name] = value
+			}
+		}
+
+		if v.CanInterface() {
+			if client, ok := v.Interface().(klient.Config); ok && client.Header != nil {
+				fields["header"] = maskedHeader(client.Header)
+			}
+		}
+
+		return fields, true
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, true
+		}
+
+		values := make(map[string]any, v.Len())
+
+		iter := v.MapRange()
+		for iter.Next() {
+			if value, ok := masked(iter.Value()); ok {
+				values[fmt.Sprint(iter.Key().Interface())] = value
+			}
+		}
+
+		return values, true
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, true
+		}
+
+		values := make([]any, 0, v.Len())
+
+		for i := 0; i < v.Len(); i++ {
+			if value, ok := masked(v.Index(i)); ok {
+				values = append(values, value)
+			}
+		}
+
+		return values, true
+	case reflect.String:
+		return secret.Redact(v.String()), true
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return nil, false
+	}
+
+	if !v.CanInterface() {
+		return nil, false
+	}
+
+	return v.Interface(), true
+}
+
+// maskedHeader masks the values of the sensitive headers of the api clients, tokens are written inline in them.
+func maskedHeader(header map[string][]string) map[string]any {
+	values := make(map[string]any, len(header))
+	for name, value := range header {
+		if secret.SensitiveHeader(name) {
+			values[name] = []any{Mask}
+
+			continue
+		}
+
+		values[name], _ = masked(reflect.ValueOf(value))
+	}
+
+	return values
+}
+
+// fieldName is the cfg tag or lower-cased field name like the loaders use.
+func fieldName(f reflect.StructField) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("cfg"), ","); name != "" {
+		return name
+	}
+
+	return strings.ToLower(f.Name)
+}
+
+func hidden(f reflect.StructField) bool {
+	for _, tag := range []string{"log", "loggable"} {
+		if value, ok := f.Tag.Lookup(tag); ok {
+			loggable, _ := strconv.ParseBool(value)
+
+			return !loggable
+		}
+	}
+
+	// secrets are not printed without explicit log tag
+	_, secret := f.Tag.Lookup("secret")
+
+	return secret
+}
@@ -0,0 +1,435 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+// Validate checks the cross-field rules and returns all problems joined.
+func (c *Config) Validate() error {
+	var errs []error
+
+	add := func(format string, a ...any) {
+		errs = append(errs, fmt.Errorf(format, a...))
+	}
+
+	if _, err := zerolog.ParseLevel(c.LogLevel); err != nil {
+		add("log_level [%s] is invalid", c.LogLevel)
+	}
+
+	for route, level := range c.LogRoutes {
+		if _, err := zerolog.ParseLevel(level); err != nil {
+			add("log_routes [%s] level [%s] is invalid", route, level)
+		}
+	}
+
+	for name, level := range c.LogPackages {
+		if _, err := zerolog.ParseLevel(level); err != nil {
+			add("log_packages [%s] level [%s] is invalid", name, level)
+		}
+	}
+
+	if c.Port == "" {
+		add("port is required")
+	}
+
+	if c.AdminPort == "" {
+		add("admin_port is required")
+	}
+
+	if c.Port != "" && c.Port == c.AdminPort {
+		add("admin_port must be different than port [%s]", c.Port)
+	}
+
+	if c.EnableGRPC {
+		if c.GRPCPort == "" {
+			add("grpc_port is required when enable_grpc is set")
+		}
+
+		if c.GRPCPort != "" && (c.GRPCPort == c.Port || c.GRPCPort == c.AdminPort) {
+			add("grpc_port must be different than port and admin_port [%s]", c.GRPCPort)
+		}
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if _, err := netip.ParsePrefix(proxy); err != nil {
+			add("trusted_proxies [%s] must be a range like \"10.0.0.0/8\"", proxy)
+		}
+	}
+
+	if c.ConfigWatchInterval < 0 {
+		add("config_watch_interval must not be negative")
+	}
+
+	for _, service := range append([]string{""}, mapKeys(c.CallPolicies)...) {
+		errs = append(errs, c.Policy(service).validate(service)...)
+	}
+
+	if c.EnableKafkaConsumer || c.EnableKafkaProducer {
+		if len(c.KafkaConfig.Brokers) == 0 {
+			add("kafka_config.brokers is required when kafka is enabled")
+		}
+	}
+
+	if c.EnableKafkaConsumer {
+		if len(c.KafkaConsumer.Topics) == 0 {
+			add("kafka_consumer.topics is required when enable_kafka_consumer is set")
+		}
+
+		if c.KafkaConsumer.GroupID == "" {
+			add("kafka_consumer.group_id is required when enable_kafka_consumer is set")
+		}
+	}
+
+	if c.EnableKafkaProducer && c.KafkaTopic == "" {
+		add("kafka_topic is required when enable_kafka_producer is set")
+	}
+
+	if c.EnableDatabase && c.Database.DBDatasource == "" {
+		add("database.db_datasource is required when enable_database is set")
+	}
+
+	if c.EnableAuth {
+		errs = append(errs, c.Auth.validate()...)
+	}
+
+	if c.EnableRateLimit {
+		errs = append(errs, c.RateLimit.validate()...)
+
+		if c.RateLimit.Shared && c.Redis.Address == "" {
+			add("redis.address is required when rate_limit.shared is set")
+		}
+	}
+
+	if c.EnableChaos {
+		errs = append(errs, c.Chaos.validate()...)
+
+		if c.Chaos.AllowRequest && !c.EnableAuth {
+			add("enable_auth is required when chaos.allow_request is set")
+		}
+	}
+
+	if c.EnableWebhooks {
+		if !c.EnableDatabase {
+			add("enable_database is required when enable_webhooks is set")
+		}
+
+		errs = append(errs, c.Webhook.validate()...)
+	}
+
+	if c.EnableIdempotency {
+		switch c.Idempotency.Store {
+		case "memory":
+		case "postgres":
+			if !c.EnableDatabase {
+				add("enable_database is required for the postgres idempotency.store")
+			}
+		default:
+			add("idempotency.store [%s] must be one of memory, postgres", c.Idempotency.Store)
+		}
+
+		if c.Idempotency.MaxBody < 1 {
+			add("idempotency.max_body must be positive")
+		}
+
+		if c.Idempotency.TTL <= 0 {
+			add("idempotency.ttl must be positive")
+		}
+	}
+
+	switch c.Search.Backend {
+	case "", "memory":
+	case "postgres":
+		if !c.EnableDatabase {
+			add("enable_database is required for the postgres search.backend")
+		}
+	default:
+		add("search.backend [%s] must be one of memory, postgres", c.Search.Backend)
+	}
+
+	if c.EnableTenancy {
+		if !c.EnableDatabase {
+			add("enable_database is required when enable_tenancy is set")
+		}
+
+		if !c.EnableAuth {
+			add("enable_auth is required when enable_tenancy is set")
+		}
+
+		errs = append(errs, c.Tenancy.validate()...)
+	}
+
+	if c.EnableCache {
+		if !c.EnableDatabase {
+			add("enable_database is required when enable_cache is set")
+		}
+
+		switch c.Cache.Backend {
+		case "memory":
+			if c.Cache.Size < 1 {
+				add("cache.size must be at least 1")
+			}
+
+			if c.Cache.Broadcast && c.Redis.Address == "" {
+				add("redis.address is required for the cache.broadcast")
+			}
+		case "redis":
+			if c.Redis.Address == "" {
+				add("redis.address is required for the redis cache.backend")
+			}
+		default:
+			add("cache.backend [%s] must be one of memory, redis", c.Cache.Backend)
+		}
+
+		if c.Cache.TTL <= 0 {
+			add("cache.ttl must be positive")
+		}
+
+		if c.Cache.NotFoundTTL < 0 {
+			add("cache.not_found_ttl must not be negative")
+		}
+	}
+
+	if c.Stream.Buffer < 1 {
+		add("stream.buffer must be at least 1")
+	}
+
+	if c.Stream.Heartbeat <= 0 {
+		add("stream.heartbeat must be positive")
+	}
+
+	if c.Stream.MaxSubscribers < 0 {
+		add("stream.max_subscribers must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
+
+func (a *Auth) validate() []error {
+	var errs []error
+
+	if len(a.APIKeys) == 0 && a.JWT.JWKSFile == "" && a.JWT.JWKSURL == "" {
+		errs = append(errs, errors.New("auth needs api_keys or jwt.jwks_file/jwks_url when enable_auth is set"))
+	}
+
+	if len(a.APIKeys) > 0 && a.APIKeyHeader == "" {
+		errs = append(errs, errors.New("auth.api_key_header is required with api_keys"))
+	}
+
+	for i, key := range a.APIKeys {
+		if key.Key == "" {
+			errs = append(errs, fmt.Errorf("auth.api_keys[%d].key is required", i))
+		}
+
+		if key.Subject == "" {
+			errs = append(errs, fmt.Errorf("auth.api_keys[%d].subject is required", i))
+		}
+	}
+
+	if a.JWT.JWKSFile != "" && a.JWT.JWKSURL != "" {
+		errs = append(errs, errors.New("auth.jwt needs only one of jwks_file and jwks_url"))
+	}
+
+	if a.JWT.JWKSFile != "" || a.JWT.JWKSURL != "" {
+		if a.JWT.Issuer == "" {
+			errs = append(errs, errors.New("auth.jwt.issuer is required with jwks_file/jwks_url"))
+		}
+
+		if a.JWT.MaxLifetime <= 0 {
+			errs = append(errs, errors.New("auth.jwt.max_lifetime must be positive"))
+		}
+	}
+
+	for route := range a.RouteScopes {
+		if method, path, ok := strings.Cut(route, " "); !ok || method == "" || path == "" {
+			errs = append(errs, fmt.Errorf("auth.route_scopes key [%s] must be like \"METHOD /path\"", route))
+		}
+	}
+
+	return errs
+}
+
+func (r *RateLimit) validate() []error {
+	var errs []error
+
+	for i, rule := range r.Rules {
+		switch rule.KeyBy {
+		case "", "ip", "api_key":
+		case "header":
+			if rule.Header == "" {
+				errs = append(errs, fmt.Errorf("rate_limit.rules[%d].header is required with key_by header", i))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("rate_limit.rules[%d].key_by [%s] must be one of [ip api_key header]", i, rule.KeyBy))
+		}
+
+		if rule.Rate < 0 || rule.Burst < 0 || rule.Concurrency < 0 {
+			errs = append(errs, fmt.Errorf("rate_limit.rules[%d] rate, burst and concurrency must not be negative", i))
+		}
+	}
+
+	if r.AuthFailures.Rate < 0 || r.AuthFailures.Burst < 0 {
+		errs = append(errs, errors.New("rate_limit.auth_failures rate and burst must not be negative"))
+	}
+
+	return errs
+}
+
+func (ch *Chaos) validate() []error {
+	var errs []error
+
+	rate := func(i int, name string, v float64) {
+		if v < 0 || v > 1 {
+			errs = append(errs, fmt.Errorf("chaos.rules[%d].fault.%s must be between 0 and 1", i, name))
+		}
+	}
+
+	if ch.AllowRequest && ch.RequestScope == "" {
+		errs = append(errs, errors.New("chaos.request_scope is required when chaos.allow_request is set"))
+	}
+
+	for i, rule := range ch.Rules {
+		f := rule.Fault
+
+		switch f.Latency.Distribution {
+		case "", model.LatencyFixed, model.LatencyUniform, model.LatencyNormal, model.LatencyExponential:
+		default:
+			errs = append(errs, fmt.Errorf("chaos.rules[%d].fault.latency.distribution [%s] must be one of [fixed uniform normal exponential]", i, f.Latency.Distribution))
+		}
+
+		if f.Latency.Duration < 0 || f.Latency.Spread < 0 {
+			errs = append(errs, fmt.Errorf("chaos.rules[%d].fault.latency duration and spread must not be negative", i))
+		}
+
+		if f.Status != 0 && (f.Status < 400 || f.Status > 599) {
+			errs = append(errs, fmt.Errorf("chaos.rules[%d].fault.status [%d] must be between 400 and 599", i, f.Status))
+		}
+
+		rate(i, "latency.rate", f.Latency.Rate)
+		rate(i, "error_rate", f.ErrorRate)
+		rate(i, "panic_rate", f.PanicRate)
+		rate(i, "db_error_rate", f.DBErrorRate)
+		rate(i, "kafka_error_rate", f.KafkaErrorRate)
+	}
+
+	return errs
+}
+
+func (p CallPolicy) validate(service string) []error {
+	name := "call_policy"
+	if service != "" {
+		name = "call_policies[" + service + "]"
+	}
+
+	var errs []error
+
+	if p.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("%s.timeout must not be negative", name))
+	}
+
+	if p.Retry.Max > 0 && (p.Retry.WaitMin < 0 || p.Retry.WaitMax < p.Retry.WaitMin) {
+		errs = append(errs, fmt.Errorf("%s.retry wait_min must not be negative and not bigger than wait_max", name))
+	}
+
+	if p.Breaker.Failures > 0 {
+		if p.Breaker.OpenTimeout <= 0 {
+			errs = append(errs, fmt.Errorf("%s.breaker.open_timeout must be positive", name))
+		}
+
+		if p.Breaker.HalfOpenRequests <= 0 {
+			errs = append(errs, fmt.Errorf("%s.breaker.half_open_requests must be positive", name))
+		}
+	}
+
+	return errs
+}
+
+func mapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// schemaPrefixPattern keeps the tenant schemas as plain identifiers.
+var schemaPrefixPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,14}$`)
+
+func (t *Tenancy) validate() []error {
+	var errs []error
+
+	if t.Header == "" {
+		errs = append(errs, errors.New("tenancy.header is required"))
+	}
+
+	if !schemaPrefixPattern.MatchString(t.SchemaPrefix) {
+		errs = append(errs, fmt.Errorf("tenancy.schema_prefix [%s] must be up to 15 lower case letters, digits and underscores", t.SchemaPrefix))
+	}
+
+	if strings.HasPrefix(t.SchemaPrefix, "pg_") {
+		errs = append(errs, errors.New("tenancy.schema_prefix must not start with the reserved pg_"))
+	}
+
+	if t.AdminScope == "" {
+		errs = append(errs, errors.New("tenancy.admin_scope is required"))
+	}
+
+	if t.MaxTenants < 1 {
+		errs = append(errs, errors.New("tenancy.max_tenants must be at least 1"))
+	}
+
+	if t.MaxOpenConns < 1 {
+		errs = append(errs, errors.New("tenancy.max_open_conns must be at least 1"))
+	}
+
+	if t.NotFoundTTL < 0 {
+		errs = append(errs, errors.New("tenancy.not_found_ttl must not be negative"))
+	}
+
+	return errs
+}
+
+func (w *Webhook) validate() []error {
+	var errs []error
+
+	if w.Timeout <= 0 {
+		errs = append(errs, errors.New("webhook.timeout must be positive"))
+	}
+
+	if w.MaxAttempts < 1 {
+		errs = append(errs, errors.New("webhook.max_attempts must be at least 1"))
+	}
+
+	if w.Backoff < 0 || w.MaxBackoff < w.Backoff {
+		errs = append(errs, errors.New("webhook.backoff must not be negative or bigger than webhook.max_backoff"))
+	}
+
+	if w.Concurrency < 1 {
+		errs = append(errs, errors.New("webhook.concurrency must be at least 1"))
+	}
+
+	if w.Queue < 1 {
+		errs = append(errs, errors.New("webhook.queue must be at least 1"))
+	}
+
+	if len(w.SecretKey) < 16 {
+		errs = append(errs, errors.New("webhook.secret_key must be at least 16 characters"))
+	}
+
+	if w.Retention <= 0 {
+		errs = append(errs, errors.New("webhook.retention must be positive"))
+	}
+
+	return errs
+}
@@ -2,10 +2,13 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -15,10 +18,41 @@ var (
 	MaxOpenConns    = 3
 )
 
+// Pool is a connection pool which can change the datasource of the new connections.
+type Pool struct {
+	*sqlx.DB
+
+	datasource atomic.Pointer[string]
+}
+
 // Connect attempts to connect to database server.
-func Connect(ctx context.Context, dbDatasource, dbType string) (*sqlx.DB, error) {
-	db, err := sqlx.ConnectContext(ctx, dbType, dbDatasource)
-	if err != nil {
+//
+// For pgx type every new connection reads the current datasource, so rotated credentials
+// are used without restarting the pool. Other types keep the first datasource.
+func Connect(ctx context.Context, dbDatasource, dbType string) (*Pool, error) {
+	p := &Pool{}
+	p.datasource.Store(&dbDatasource)
+
+	var db *sqlx.DB
+	if dbType == "pgx" {
+		connConfig, err := pgx.ParseConfig(dbDatasource)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse datasource: %w", err)
+		}
+
+		db = sqlx.NewDb(stdlib.OpenDB(*connConfig, stdlib.OptionBeforeConnect(p.beforeConnect)), dbType)
+	} else {
+		sqlDB, err := sql.Open(dbType, dbDatasource)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+
+		db = sqlx.NewDb(sqlDB, dbType)
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
@@ -26,5 +60,33 @@ func Connect(ctx context.Context, dbDatasource, dbType string) (*sqlx.DB, error)
 	db.SetMaxIdleConns(MaxIdleConns)
 	db.SetMaxOpenConns(MaxOpenConns)
 
-	return db, nil
+	p.DB = db
+
+	return p, nil
+}
+
+func (p *Pool) beforeConnect(_ context.Context, connConfig *pgx.ConnConfig) error {
+	current, err := pgx.ParseConfig(*p.datasource.Load())
+	if err != nil {
+		return fmt.Errorf("failed to parse datasource: %w", err)
+	}
+
+	connConfig.Config = current.Config
+
+	return nil
+}
+
+// SetDatasource changes the datasource of the new connections and closes the idle ones.
+// Connections in use are replaced after ConnMaxLifetime.
+func (p *Pool) SetDatasource(dbDatasource string) bool {
+	if *p.datasource.Load() == dbDatasource {
+		return false
+	}
+
+	p.datasource.Store(&dbDatasource)
+
+	p.SetMaxIdleConns(0)
+	p.SetMaxIdleConns(MaxIdleConns)
+
+	return true
 }
@@ -0,0 +1,217 @@
+package dbhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/worldline-go/telemetry_example/internal/chaos"
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+// categoriesWithPath has the path of all categories from their root category.
+const categoriesWithPath = `(WITH RECURSIVE tree AS (
+	SELECT id, name, parent_id, last_user, updated_at, created_at, name::TEXT AS path
+	FROM categories WHERE parent_id IS NULL
+	UNION ALL
+	SELECT c.id, c.name, c.parent_id, c.last_user, c.updated_at, c.created_at, tree.path || ' / ' || c.name
+	FROM categories c JOIN tree ON c.parent_id = tree.id
+) SELECT * FROM tree)`
+
+// categoryTree returns the ids of the category and its subcategories.
+func categoryTree(id int64) exp.LiteralExpression {
+	return goqu.L(`WITH RECURSIVE tree AS (
+	SELECT id FROM categories WHERE id = ?
+	UNION ALL
+	SELECT c.id FROM categories c JOIN tree ON c.parent_id = tree.id
+) SELECT id FROM tree`, id)
+}
+
+// categoryError converts the constraint violations of the category to domain errors.
+func categoryError(err error, category model.Category) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Code {
+	case "23505":
+		return &model.Error{
+			Kind:   model.ErrDuplicate,
+			Detail: fmt.Sprintf("category [%s] already exists in the parent", category.Name),
+			Cause:  err,
+		}
+	case "23503":
+		return &model.ValidationError{Fields: []model.FieldError{{
+			Field:   "parent_id",
+			Rule:    "exists",
+			Message: "parent_id is not found",
+		}}}
+	}
+
+	return err
+}
+
+// ExistingCategories returns the ones of the ids having a category.
+func (h *Handler) ExistingCategories(ctx context.Context, ids []int64) (map[int64]struct{}, error) {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return nil, err
+	}
+
+	var found []int64
+	if err := h.db.From("categories").Select("id").Where(goqu.C("id").In(ids)).
+		Executor().ScanValsContext(ctx, &found); err != nil {
+		return nil, err
+	}
+
+	existing := make(map[int64]struct{}, len(found))
+	for _, id := range found {
+		existing[id] = struct{}{}
+	}
+
+	return existing, nil
+}
+
+// AddCategory records the category and returns its id, lastUser is the subject who made the change.
+func (h *Handler) AddCategory(ctx context.Context, category model.Category, lastUser string) (int64, error) {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return 0, err
+	}
+
+	var id int64
+
+	_, err := h.db.Insert("categories").Rows(
+		goqu.Record{
+			"name":       category.Name,
+			"parent_id":  category.ParentID,
+			"last_user":  lastUser,
+			"updated_at": time.Now(),
+		},
+	).Returning("id").Executor().ScanValContext(ctx, &id)
+	if err != nil {
+		return 0, categoryError(err, category)
+	}
+
+	return id, nil
+}
+
+// GetCategories returns all categories ordered by the path.
+func (h *Handler) GetCategories(ctx context.Context) ([]model.Category, error) {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return nil, err
+	}
+
+	categories := []model.Category{}
+
+	if err := h.db.From(goqu.L(categoriesWithPath).As("categories")).
+		Order(goqu.C("path").Asc()).
+		Executor().ScanStructsContext(ctx, &categories); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+// GetCategory returns the category with id.
+func (h *Handler) GetCategory(ctx context.Context, id int64) (*model.Category, error) {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return nil, err
+	}
+
+	var category model.Category
+
+	found, err := h.db.From(goqu.L(categoriesWithPath).As("categories")).
+		Where(goqu.C("id").Eq(id)).
+		Executor().ScanStructContext(ctx, &category)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, &model.Error{
+			Kind:   model.ErrNotFound,
+			Detail: fmt.Sprintf("category [%d] not found", id),
+		}
+	}
+
+	return &category, nil
+}
+
+// UpdateCategory changes the name and the parent of the category, the parent can't be the category or its subcategory.
+func (h *Handler) UpdateCategory(ctx context.Context, category model.Category, lastUser string) (*model.Category, error) {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return nil, err
+	}
+
+	if category.ParentID != nil {
+		var cycle bool
+
+		if _, err := h.db.Select(goqu.L("? IN (?)", *category.ParentID, categoryTree(category.ID))).
+			Executor().ScanValContext(ctx, &cycle); err != nil {
+			return nil, err
+		}
+
+		if cycle {
+			return nil, &model.Error{
+				Kind:   model.ErrConflict,
+				Detail: fmt.Sprintf("category [%d] can't be moved under itself or its subcategory", category.ID),
+			}
+		}
+	}
+
+	result, err := h.db.Update("categories").Set(
+		goqu.Record{
+			"name":       category.Name,
+			"parent_id":  category.ParentID,
+			"last_user":  lastUser,
+			"updated_at": time.Now(),
+		},
+	).Where(goqu.C("id").Eq(category.ID)).Executor().ExecContext(ctx)
+	if err != nil {
+		return nil, categoryError(err, category)
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return nil, &model.Error{
+			Kind:   model.ErrNotFound,
+			Detail: fmt.Sprintf("category [%d] not found", category.ID),
+		}
+	}
+
+	return h.GetCategory(ctx, category.ID)
+}
+
+// DeleteCategory removes the category without subcategories, its products have no category after it.
+func (h *Handler) DeleteCategory(ctx context.Context, id int64) error {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return err
+	}
+
+	result, err := h.db.Delete("categories").Where(goqu.C("id").Eq(id)).Executor().ExecContext(ctx)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return &model.Error{
+				Kind:   model.ErrConflict,
+				Detail: fmt.Sprintf("category [%d] has subcategories", id),
+				Cause:  err,
+			}
+		}
+
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return &model.Error{
+			Kind:   model.ErrNotFound,
+			Detail: fmt.Sprintf("category [%d] not found", id),
+		}
+	}
+
+	return nil
+}
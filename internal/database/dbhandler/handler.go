@@ -8,62 +8,310 @@ import (
 
 	"github.com/doug-martin/goqu/v9"
 	"github.com/jackc/pgx/v5/pgconn"
-	"github.com/jmoiron/sqlx"
-	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/chaos"
 	"github.com/worldline-go/telemetry_example/internal/model"
 )
 
-var (
-	ErrDuplicate = errors.New("duplicate record")
-	ErrNotFound  = errors.New("not found")
-)
-
 type Handler struct {
 	db *goqu.Database
 }
 
-func New(db *sqlx.DB) *Handler {
+// New creates the handler, db is the pool or the tenant registry routing to the tenant's pool.
+func New(db goqu.SQLDatabase) *Handler {
 	dbGoqu := goqu.New("posgres", db)
 
 	return &Handler{db: dbGoqu}
 }
 
+// productRow maps the price columns of the product.
+type productRow struct {
+	model.Product
+	PriceAmount   *int64  `db:"price_amount"`
+	PriceCurrency *string `db:"price_currency"`
+}
+
+func (r *productRow) product() model.Product {
+	product := r.Product
+	if r.PriceAmount != nil && r.PriceCurrency != nil {
+		product.Price = &model.Price{Amount: *r.PriceAmount, Currency: *r.PriceCurrency}
+	}
+
+	return product
+}
+
+// productRecord returns the changeable columns of the product.
+func productRecord(product model.Product, lastUser string) goqu.Record {
+	record := goqu.Record{
+		"description":    product.Description,
+		"category_id":    product.CategoryID,
+		"tags":           product.Tags,
+		"price_amount":   nil,
+		"price_currency": nil,
+		"last_user":      lastUser,
+		"updated_at":     time.Now(),
+	}
+
+	if product.Price != nil {
+		record["price_amount"] = product.Price.Amount
+		record["price_currency"] = product.Price.Currency
+	}
+
+	return record
+}
+
+// importColumns returns the columns changed by the import, last_user and updated_at are always changed.
+func importColumns(columns model.ImportColumns) []string {
+	result := []string{"last_user", "updated_at"}
+	if columns.Description {
+		result = append(result, "description")
+	}
+
+	if columns.CategoryID {
+		result = append(result, "category_id")
+	}
+
+	if columns.Tags {
+		result = append(result, "tags")
+	}
+
+	if columns.Price {
+		result = append(result, "price_amount", "price_currency")
+	}
+
+	return result
+}
+
+// productError converts the constraint violations of the product to domain errors.
+func productError(err error, product model.Product) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Code {
+	case "23505":
+		return &model.Error{
+			Kind:   model.ErrDuplicate,
+			Detail: fmt.Sprintf("product [%s] already exists", product.Name),
+			Cause:  err,
+		}
+	case "23503":
+		return &model.ValidationError{Fields: []model.FieldError{{
+			Field:   "category_id",
+			Rule:    "exists",
+			Message: "category_id is not found",
+		}}}
+	}
+
+	return err
+}
+
 func (h *Handler) GetProduct(ctx context.Context, name string) (*model.Product, error) {
-	var product model.Product
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return nil, err
+	}
+
+	var row productRow
 
-	found, err := h.db.From("products").Where(goqu.C("name").Eq(name)).Executor().ScanStructContext(ctx, &product)
+	found, err := h.db.From("products").Where(goqu.C("name").Eq(name)).Executor().ScanStructContext(ctx, &row)
 	if err != nil {
 		return nil, err
 	}
 
 	if found {
+		product := row.product()
+
 		return &product, nil
 	}
 
-	return nil, fmt.Errorf("product [%s] %w", name, ErrNotFound)
+	return nil, &model.Error{
+		Kind:   model.ErrNotFound,
+		Detail: fmt.Sprintf("product [%s] not found", name),
+	}
 }
 
-func (h *Handler) AddNewProduct(ctx context.Context, name, description string) (int64, error) {
+// AddNewProduct records the product, lastUser is the subject who made the change.
+func (h *Handler) AddNewProduct(ctx context.Context, product model.Product, lastUser string) (int64, error) {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return 0, err
+	}
+
 	var id int64
 
-	_, err := h.db.Insert("products").Rows(
-		goqu.Record{
-			"name":        name,
-			"description": description,
-			"last_user":   config.ServiceName,
-			"updated_at":  time.Now(),
-		},
-	).Returning("id").Executor().ScanValContext(ctx, &id)
+	record := productRecord(product, lastUser)
+	record["name"] = product.Name
+
+	_, err := h.db.Insert("products").Rows(record).Returning("id").Executor().ScanValContext(ctx, &id)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			if pgErr.Code == "23505" {
-				return 0, fmt.Errorf("name [%s] %w: %w", name, ErrDuplicate, err)
-			}
+		return 0, productError(err, product)
+	}
+
+	return id, nil
+}
+
+// GetProducts returns the products with the names in one query, missing names are not in the map.
+func (h *Handler) GetProducts(ctx context.Context, names []string) (map[string]*model.Product, error) {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return nil, err
+	}
+
+	var rows []productRow
+
+	if err := h.db.From("products").Where(goqu.C("name").In(names)).Executor().ScanStructsContext(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*model.Product, len(rows))
+	for i := range rows {
+		product := rows[i].product()
+		result[product.Name] = &product
+	}
+
+	return result, nil
+}
+
+// ListProducts returns at most limit products matching the filter with id bigger than afterID ordered by id.
+func (h *Handler) ListProducts(ctx context.Context, filter model.ProductFilter, afterID int64, limit uint) ([]model.Product, error) {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return nil, err
+	}
+
+	query := h.db.From("products").Where(goqu.C("id").Gt(afterID))
+
+	if filter.CategoryID > 0 {
+		query = query.Where(goqu.L("category_id IN (?)", categoryTree(filter.CategoryID)))
+	}
+
+	if len(filter.Tags) > 0 {
+		query = query.Where(goqu.L("tags @> ?", model.Tags(filter.Tags)))
+	}
+
+	if filter.Currency != "" {
+		query = query.Where(goqu.C("price_currency").Eq(filter.Currency))
+	}
+
+	if filter.MinPrice != nil {
+		query = query.Where(goqu.C("price_amount").Gte(*filter.MinPrice))
+	}
+
+	if filter.MaxPrice != nil {
+		query = query.Where(goqu.C("price_amount").Lte(*filter.MaxPrice))
+	}
+
+	var rows []productRow
+
+	if err := query.Order(goqu.C("id").Asc()).
+		Limit(limit).
+		Executor().ScanStructsContext(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	products := make([]model.Product, 0, len(rows))
+	for i := range rows {
+		products = append(products, rows[i].product())
+	}
+
+	return products, nil
+}
+
+// UpdateProduct replaces the changeable fields of the product, lastUser is the subject who made the change.
+func (h *Handler) UpdateProduct(ctx context.Context, product model.Product, lastUser string) (*model.Product, error) {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return nil, err
+	}
+
+	var row productRow
+
+	found, err := h.db.Update("products").Set(productRecord(product, lastUser)).
+		Where(goqu.C("name").Eq(product.Name)).Returning(&row).Executor().ScanStructContext(ctx, &row)
+	if err != nil {
+		return nil, productError(err, product)
+	}
+
+	if !found {
+		return nil, &model.Error{
+			Kind:   model.ErrNotFound,
+			Detail: fmt.Sprintf("product [%s] not found", product.Name),
 		}
+	}
 
-		return 0, err
+	updated := row.product()
+
+	return &updated, nil
+}
+
+// DeleteProduct removes the product and returns it.
+func (h *Handler) DeleteProduct(ctx context.Context, name string) (*model.Product, error) {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return nil, err
 	}
 
-	return id, nil
+	var row productRow
+
+	found, err := h.db.Delete("products").Where(goqu.C("name").Eq(name)).
+		Returning(&row).Executor().ScanStructContext(ctx, &row)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, &model.Error{
+			Kind:   model.ErrNotFound,
+			Detail: fmt.Sprintf("product [%s] not found", name),
+		}
+	}
+
+	deleted := row.product()
+
+	return &deleted, nil
+}
+
+// ImportProducts records the products in one query, existing products are updated when update is set.
+// Updates only change the given columns, so the fields missing in the import are kept.
+// It returns the recorded products with their names, skipped names are not in the map.
+func (h *Handler) ImportProducts(ctx context.Context, products []model.Product, columns model.ImportColumns, lastUser string, update bool) (map[string]model.ImportedProduct, error) {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return nil, err
+	}
+
+	rows := make([]interface{}, 0, len(products))
+	for _, p := range products {
+		record := productRecord(p, lastUser)
+		record["name"] = p.Name
+
+		rows = append(rows, record)
+	}
+
+	conflict := goqu.DoNothing()
+	if update {
+		updates := goqu.Record{}
+		for _, column := range importColumns(columns) {
+			updates[column] = goqu.L("EXCLUDED." + column)
+		}
+
+		conflict = goqu.DoUpdate("name", updates)
+	}
+
+	var results []struct {
+		productRow
+		// Inserted rows have no previous version.
+		Inserted bool `db:"inserted"`
+	}
+
+	if err := h.db.Insert("products").Rows(rows...).OnConflict(conflict).
+		Returning(
+			"id", "name", "description", "category_id", "tags", "price_amount", "price_currency",
+			"last_user", "updated_at", "created_at",
+			goqu.L("(xmax = 0)").As("inserted"),
+		).
+		Executor().ScanStructsContext(ctx, &results); err != nil {
+		return nil, productError(err, model.Product{})
+	}
+
+	imported := make(map[string]model.ImportedProduct, len(results))
+	for _, r := range results {
+		imported[r.Name] = model.ImportedProduct{Product: r.product(), Inserted: r.Inserted}
+	}
+
+	return imported, nil
 }
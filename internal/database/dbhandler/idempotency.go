@@ -0,0 +1,87 @@
+package dbhandler
+
+import (
+	"context"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/worldline-go/telemetry_example/internal/chaos"
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+// ReserveIdempotencyKey records the key for the running request, an expired key is reserved again.
+// It returns the existing record when the key is already used.
+func (h *Handler) ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, expiresAt time.Time) (*model.IdempotencyRecord, error) {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return nil, err
+	}
+
+	var reserved string
+
+	found, err := h.db.Insert("idempotency_keys").Rows(
+		goqu.Record{
+			"key":         key,
+			"fingerprint": fingerprint,
+			"expires_at":  expiresAt,
+		},
+	).OnConflict(goqu.DoUpdate("key", goqu.Record{
+		"fingerprint":  goqu.L("EXCLUDED.fingerprint"),
+		"status":       0,
+		"content_type": "",
+		"body":         "",
+		"expires_at":   goqu.L("EXCLUDED.expires_at"),
+	}).Where(goqu.T("idempotency_keys").Col("expires_at").Lt(time.Now()))).
+		Returning("key").Executor().ScanValContext(ctx, &reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	if found {
+		return nil, nil
+	}
+
+	var record model.IdempotencyRecord
+
+	found, err = h.db.From("idempotency_keys").Where(goqu.C("key").Eq(key)).Executor().ScanStructContext(ctx, &record)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		// removed by the failed request at the same time
+		return &model.IdempotencyRecord{Key: key, Fingerprint: fingerprint}, nil
+	}
+
+	return &record, nil
+}
+
+// CompleteIdempotencyKey stores the response of the reserved key.
+func (h *Handler) CompleteIdempotencyKey(ctx context.Context, record model.IdempotencyRecord) error {
+	_, err := h.db.Update("idempotency_keys").Set(
+		goqu.Record{
+			"status":       record.Status,
+			"content_type": record.ContentType,
+			"body":         string(record.Body),
+		},
+	).Where(goqu.C("key").Eq(record.Key)).Executor().ExecContext(ctx)
+
+	return err
+}
+
+// DeleteIdempotencyKey releases the key to let the request run again.
+func (h *Handler) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	_, err := h.db.Delete("idempotency_keys").Where(goqu.C("key").Eq(key)).Executor().ExecContext(ctx)
+
+	return err
+}
+
+// DeleteExpiredIdempotencyKeys removes the expired keys and returns the number of them.
+func (h *Handler) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	result, err := h.db.Delete("idempotency_keys").Where(goqu.C("expires_at").Lt(time.Now())).Executor().ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected() //nolint:wrapcheck // no need
+}
@@ -0,0 +1,68 @@
+package dbhandler
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/worldline-go/telemetry_example/internal/chaos"
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+// headlineOptions wraps the matched words with <mark> tags in the whole text, fragments could cut the escaped text.
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, HighlightAll=true"
+
+// escapeHTML is the html escaped column for the headlines, only the <mark> tags are html in them.
+func escapeHTML(column string) string {
+	return `replace(replace(replace(replace(replace(` + column +
+		`, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;')`
+}
+
+// SearchProducts matches the words of the text with the full-text index and the names and descriptions with trigrams.
+// Results are ordered by the sum of the text rank and the name similarity.
+func (h *Handler) SearchProducts(ctx context.Context, text string, limit uint) ([]model.SearchResult, error) {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		productRow
+		Rank                 float64 `db:"rank"`
+		NameHighlight        string  `db:"name_highlight"`
+		DescriptionHighlight string  `db:"description_highlight"`
+	}
+
+	if err := h.db.From(
+		goqu.T("products"),
+		goqu.L("websearch_to_tsquery('english', ?)", text).As("query"),
+	).Select(
+		"id", "name", "description", "category_id", "tags", "price_amount", "price_currency",
+		"last_user", "updated_at", "created_at",
+		goqu.L("ts_rank(search, query) + similarity(name, ?)", text).As("rank"),
+		goqu.L("ts_headline('english', "+escapeHTML("name")+", query, ?)", headlineOptions).As("name_highlight"),
+		goqu.L("ts_headline('english', "+escapeHTML("description")+", query, ?)", headlineOptions).As("description_highlight"),
+	).Where(goqu.Or(
+		goqu.L("search @@ query"),
+		goqu.L("name % ?", text),
+		goqu.L("? <% description", text),
+	)).
+		Order(goqu.I("rank").Desc(), goqu.C("id").Asc()).
+		Limit(limit).
+		Executor().ScanStructsContext(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	results := make([]model.SearchResult, 0, len(rows))
+	for _, r := range rows {
+		results = append(results, model.SearchResult{
+			Product: r.product(),
+			Rank:    r.Rank,
+			Highlights: model.Highlights{
+				Name:        r.NameHighlight,
+				Description: r.DescriptionHighlight,
+			},
+		})
+	}
+
+	return results, nil
+}
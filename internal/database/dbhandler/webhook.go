@@ -0,0 +1,118 @@
+package dbhandler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+
+	"github.com/worldline-go/telemetry_example/internal/chaos"
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+// AddWebhook records the webhook and returns its id.
+func (h *Handler) AddWebhook(ctx context.Context, webhook model.Webhook) (int64, error) {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return 0, err
+	}
+
+	var id int64
+
+	_, err := h.db.Insert("webhooks").Rows(
+		goqu.Record{
+			"url":       webhook.URL,
+			"events":    webhook.Events,
+			"secret":    webhook.Secret,
+			"last_user": webhook.LastUser,
+		},
+	).Returning("id").Executor().ScanValContext(ctx, &id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetWebhooks returns all webhooks with their stored secrets ordered by id.
+func (h *Handler) GetWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return nil, err
+	}
+
+	webhooks := []model.Webhook{}
+
+	if err := h.db.From("webhooks").Order(goqu.C("id").Asc()).Executor().ScanStructsContext(ctx, &webhooks); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook removes the webhook with its deliveries.
+func (h *Handler) DeleteWebhook(ctx context.Context, id int64) error {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return err
+	}
+
+	result, err := h.db.Delete("webhooks").Where(goqu.C("id").Eq(id)).Executor().ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return &model.Error{
+			Kind:   model.ErrNotFound,
+			Detail: fmt.Sprintf("webhook [%d] not found", id),
+		}
+	}
+
+	return nil
+}
+
+// AddWebhookDelivery records an attempt of a delivery.
+func (h *Handler) AddWebhookDelivery(ctx context.Context, delivery model.WebhookDelivery) error {
+	_, err := h.db.Insert("webhook_deliveries").Rows(
+		goqu.Record{
+			"webhook_id":  delivery.WebhookID,
+			"delivery_id": delivery.DeliveryID,
+			"event":       delivery.Event,
+			"attempt":     delivery.Attempt,
+			"status_code": delivery.StatusCode,
+			"latency_ms":  delivery.LatencyMS,
+			"error":       delivery.Error,
+			"trace_id":    delivery.TraceID,
+		},
+	).Executor().ExecContext(ctx)
+
+	return err
+}
+
+// DeleteWebhookDeliveries removes the attempts recorded before the time and returns the number of them.
+func (h *Handler) DeleteWebhookDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	result, err := h.db.Delete("webhook_deliveries").Where(goqu.C("created_at").Lt(before)).Executor().ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected() //nolint:wrapcheck // no need
+}
+
+// GetWebhookDeliveries returns the latest attempts of the webhook.
+func (h *Handler) GetWebhookDeliveries(ctx context.Context, webhookID int64, limit uint) ([]model.WebhookDelivery, error) {
+	if err := chaos.Fail(ctx, chaos.KindDB); err != nil {
+		return nil, err
+	}
+
+	deliveries := []model.WebhookDelivery{}
+
+	if err := h.db.From("webhook_deliveries").
+		Where(goqu.C("webhook_id").Eq(webhookID)).
+		Order(goqu.C("id").Desc()).
+		Limit(limit).
+		Executor().ScanStructsContext(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
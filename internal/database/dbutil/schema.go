@@ -38,3 +38,18 @@ func GetDBSchema(dbSource string) string {
 
 	return u.Query().Get("search_path")
 }
+
+// ReplaceDBSchema sets the search_path of the dbsource's url even it is defined.
+func ReplaceDBSchema(dbSource, dbSchema string) (string, error) {
+	u, err := url.Parse(dbSource)
+	if err != nil {
+		return dbSource, fmt.Errorf("failed to parse url")
+	}
+
+	qValues := u.Query()
+	qValues.Set("search_path", dbSchema)
+
+	u.RawQuery = qValues.Encode()
+
+	return u.String(), nil
+}
@@ -4,13 +4,18 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jmoiron/sqlx"
 	"github.com/rs/zerolog/log"
 	"github.com/worldline-go/igmigrator"
 
 	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/database/dbutil"
 )
 
+// MigrationsDir holds the migration files.
+var MigrationsDir = "migrations"
+
 func MigrateDB(ctx context.Context, migrate config.Migrate) error {
 	if migrate.DBDatasource == "" {
 		return fmt.Errorf("migrate database datasource is empty")
@@ -23,18 +28,95 @@ func MigrateDB(ctx context.Context, migrate config.Migrate) error {
 
 	defer db.Close()
 
-	prevVersion, newVersion, err := igmigrator.Migrate(ctx, db, &igmigrator.Config{
-		MigrationsDir:  "migrations",
+	return run(ctx, db, &igmigrator.Config{
+		MigrationsDir:  MigrationsDir,
 		Schema:         migrate.DBSchema,
 		MigrationTable: migrate.DBTable,
 	})
+}
+
+// MigrateSchema creates the schema when it is missing and runs the migrations in it.
+// The main schema stays in the search path to use its extensions like pg_trgm.
+func MigrateSchema(ctx context.Context, migrate config.Migrate, schema string) error {
+	if migrate.DBDatasource == "" {
+		return fmt.Errorf("migrate database datasource is empty")
+	}
+
+	dbDatasource, err := dbutil.ReplaceDBSchema(migrate.DBDatasource, schema+","+migrate.DBSchema)
+	if err != nil {
+		return fmt.Errorf("set schema [%s]: %w", schema, err)
+	}
+
+	db, err := sqlx.Connect(migrate.DBType, dbDatasource)
+	if err != nil {
+		return fmt.Errorf("migrate database connect: %w", err)
+	}
+
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+pgx.Identifier{schema}.Sanitize()); err != nil {
+		return fmt.Errorf("create schema [%s]: %w", schema, err)
+	}
+
+	// the search path of the datasource is used, igmigrator's schema would replace it
+	return run(ctx, db, &igmigrator.Config{
+		MigrationsDir:  MigrationsDir,
+		MigrationTable: schema + "." + migrate.DBTable,
+	})
+}
+
+// Schemas returns the names of the schemas starting with the prefix.
+func Schemas(ctx context.Context, db *sqlx.DB, prefix string) ([]string, error) {
+	var schemas []string
+	if err := db.SelectContext(ctx, &schemas,
+		"SELECT schema_name FROM information_schema.schemata WHERE starts_with(schema_name, $1) ORDER BY schema_name",
+		prefix,
+	); err != nil {
+		return nil, fmt.Errorf("list schemas: %w", err)
+	}
+
+	return schemas, nil
+}
+
+func run(ctx context.Context, db *sqlx.DB, cnf *igmigrator.Config) error {
+	prevVersion, newVersion, err := igmigrator.Migrate(ctx, db, cnf)
 	if err != nil {
 		return fmt.Errorf("run migrations: %w", err)
 	}
 
 	if newVersion != prevVersion {
-		log.Info().Msgf("ran migrations from version %d to %d", prevVersion, newVersion)
+		log.Info().Str("table", cnf.MigrationTable).Msgf("ran migrations from version %d to %d", prevVersion, newVersion)
 	}
 
 	return nil
 }
+
+// MigrationVersion returns the applied migration version and the latest version in the migration files.
+func MigrationVersion(ctx context.Context, db *sqlx.DB, migrate config.Migrate) (current, latest int, err error) {
+	cnf := &igmigrator.Config{
+		MigrationsDir:  MigrationsDir,
+		Schema:         migrate.DBSchema,
+		MigrationTable: migrate.DBTable,
+	}
+	cnf.SetDefaults()
+
+	migrator := &igmigrator.Migrator{Cnf: cnf, Tx: db}
+
+	current, err = migrator.GetLastVersion(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("get migration version: %w", err)
+	}
+
+	files, err := migrator.GetMigrationFiles(cnf.MigrationsDir, 0)
+	if err != nil {
+		return 0, 0, fmt.Errorf("read migration files: %w", err)
+	}
+
+	for _, file := range files {
+		if v := igmigrator.VersionFromFile(file); v > latest {
+			latest = v
+		}
+	}
+
+	return current, latest, nil
+}
@@ -0,0 +1,76 @@
+package hold
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/worldline-go/klient"
+)
+
+// Clients holds http clients by name, the set can be replaced on config reload.
+type Clients struct {
+	options []klient.OptionClientFn
+
+	mu      sync.RWMutex
+	clients map[string]*klient.Client
+	configs map[string]klient.Config
+}
+
+// NewClients creates clients from configs, options applied to every client.
+func NewClients(configs map[string]klient.Config, options ...klient.OptionClientFn) (*Clients, error) {
+	c := &Clients{options: options}
+	if err := c.Set(configs); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Clients) Get(name string) (*klient.Client, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	client, ok := c.clients[name]
+
+	return client, ok
+}
+
+// Set replaces the clients, unchanged configs keep their clients.
+// On error nothing is changed.
+func (c *Clients) Set(configs map[string]klient.Config) error {
+	c.mu.RLock()
+	clients := make(map[string]*klient.Client, len(configs))
+	for name, cfg := range configs {
+		if old, ok := c.clients[name]; ok && reflect.DeepEqual(c.configs[name], cfg) {
+			clients[name] = old
+
+			continue
+		}
+
+		client, err := cfg.New(c.options...)
+		if err != nil {
+			c.mu.RUnlock()
+
+			return fmt.Errorf("failed to create client [%s]; %w", name, err)
+		}
+
+		clients[name] = client
+	}
+	c.mu.RUnlock()
+
+	c.mu.Lock()
+	old := c.clients
+	c.clients = clients
+	c.configs = configs
+	c.mu.Unlock()
+
+	// in-flight requests are not affected
+	for name, client := range old {
+		if clients[name] != client {
+			client.HTTP.CloseIdleConnections()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,254 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/auth"
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/database/dbhandler"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/telemetry"
+	"github.com/worldline-go/telemetry_example/internal/tenant"
+)
+
+const (
+	// HeaderKey is the idempotency key of the request.
+	HeaderKey = "Idempotency-Key"
+	// HeaderReplayed is set to true on the replayed responses.
+	HeaderReplayed = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+
+	resultStored   = "stored"
+	resultReplayed = "replayed"
+	resultConflict = "conflict"
+)
+
+// cleanupInterval to remove the expired keys.
+var cleanupInterval = 10 * time.Minute
+
+// Idempotency replays the first successful response of the requests with the same key.
+type Idempotency struct {
+	store   Store
+	ttl     time.Duration
+	maxBody int64
+	tenants *tenant.Registry
+}
+
+// New creates the idempotency with the configured store, db is required for the postgres store.
+// Expired keys of the tenant schemas are also removed when tenants is set.
+func New(cfg config.Idempotency, db *dbhandler.Handler, tenants *tenant.Registry) *Idempotency {
+	var store Store = newMemory()
+	if cfg.Store == StorePostgres {
+		store = postgres{db: db}
+	}
+
+	return &Idempotency{
+		store:   store,
+		ttl:     cfg.TTL,
+		maxBody: cfg.MaxBody,
+		tenants: tenants,
+	}
+}
+
+// Run removes the expired keys until the context is done.
+func (i *Idempotency) Run(ctx context.Context) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			i.tenants.Each(ctx, i.deleteExpired)
+		}
+	}
+}
+
+func (i *Idempotency) deleteExpired(ctx context.Context) {
+	id := tenant.FromContext(ctx)
+
+	count, err := i.store.DeleteExpired(ctx)
+	if err != nil {
+		log.Warn().Err(err).Str("tenant", id).Msg("failed to delete expired idempotency keys")
+
+		return
+	}
+
+	if count > 0 {
+		log.Debug().Int64("count", count).Str("tenant", id).Msg("deleted expired idempotency keys")
+	}
+}
+
+// Middleware stores the response of the request with the Idempotency-Key header.
+// Keys are scoped to the tenant, the subject and the route, failed requests release the key to let the client retry.
+func (i *Idempotency) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(HeaderKey)
+			if key == "" {
+				return next(c)
+			}
+
+			if len(key) > maxKeyLength {
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s must be at most %d characters", HeaderKey, maxKeyLength))
+			}
+
+			// the body is kept in memory for the fingerprint
+			body, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, i.maxBody))
+			if err != nil {
+				var errMaxBytes *http.MaxBytesError
+				if errors.As(err, &errMaxBytes) {
+					return echo.NewHTTPError(http.StatusRequestEntityTooLarge,
+						fmt.Sprintf("body of the requests with the %s must be at most %d bytes", HeaderKey, i.maxBody))
+				}
+
+				return echo.NewHTTPError(http.StatusBadRequest, "failed to read body")
+			}
+
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+			ctx := c.Request().Context()
+			req := c.Request()
+
+			subject := auth.Subject(ctx, "")
+			if id := tenant.FromContext(ctx); id != "" {
+				subject = id + "/" + subject
+			}
+
+			scopedKey := hash(subject, req.Method, req.URL.Path, key)
+			fingerprint := hash(req.Method, req.URL.RequestURI(), string(body))
+
+			span := trace.SpanFromContext(ctx)
+
+			record, err := i.store.Reserve(ctx, scopedKey, fingerprint, time.Now().Add(i.ttl))
+			if err != nil {
+				return fmt.Errorf("failed to reserve idempotency key; %w", err)
+			}
+
+			if record != nil {
+				return i.replay(c, record, fingerprint, span)
+			}
+
+			// the request runs until the end even the client is gone
+			ctxStore := context.WithoutCancel(ctx)
+
+			// the key is released unless the response is stored, also when the handler panics,
+			// a reserved key without the response would be in progress until it expires
+			stored := false
+			defer func() {
+				if stored {
+					return
+				}
+
+				if errDelete := i.store.Delete(ctxStore, scopedKey); errDelete != nil {
+					log.Ctx(ctx).Warn().Err(errDelete).Msg("failed to release idempotency key")
+				}
+			}()
+
+			recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+
+			err = next(c)
+
+			status := c.Response().Status
+			if err != nil || status >= http.StatusBadRequest {
+				return err
+			}
+
+			if errComplete := i.store.Complete(ctxStore, model.IdempotencyRecord{
+				Key:         scopedKey,
+				Fingerprint: fingerprint,
+				Status:      status,
+				ContentType: c.Response().Header().Get(echo.HeaderContentType),
+				Body:        recorder.body.Bytes(),
+			}); errComplete != nil {
+				log.Ctx(ctx).Warn().Err(errComplete).Msg("failed to store idempotent response")
+
+				return nil
+			}
+
+			stored = true
+
+			span.SetAttributes(attribute.String("idempotency.result", resultStored))
+			i.count(ctx, resultStored)
+
+			return nil
+		}
+	}
+}
+
+func (i *Idempotency) replay(c echo.Context, record *model.IdempotencyRecord, fingerprint string, span trace.Span) error {
+	ctx := c.Request().Context()
+
+	if record.Fingerprint != fingerprint {
+		span.SetAttributes(attribute.String("idempotency.result", resultConflict))
+		i.count(ctx, resultConflict)
+
+		return &model.Error{
+			Kind:   model.ErrConflict,
+			Detail: HeaderKey + " is used with a different request",
+		}
+	}
+
+	if record.Status == 0 {
+		span.SetAttributes(attribute.String("idempotency.result", resultConflict))
+		i.count(ctx, resultConflict)
+
+		return &model.Error{
+			Kind:   model.ErrConflict,
+			Detail: "request with the " + HeaderKey + " is in progress",
+		}
+	}
+
+	span.SetAttributes(attribute.String("idempotency.result", resultReplayed))
+	i.count(ctx, resultReplayed)
+
+	c.Response().Header().Set(HeaderReplayed, "true")
+
+	return c.Blob(record.Status, record.ContentType, record.Body)
+}
+
+func (i *Idempotency) count(ctx context.Context, result string) {
+	telemetry.GlobalMeter.IdempotencyCounter.Add(ctx, 1, metric.WithAttributes(
+		tenant.Attributes(ctx, attribute.String("result", result))...,
+	))
+}
+
+func hash(values ...string) string {
+	h := sha256.New()
+	for _, v := range values {
+		h.Write([]byte(v))
+		// separator to not mix the values
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the written body.
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+
+	return r.ResponseWriter.Write(b) //nolint:wrapcheck // no need
+}
@@ -0,0 +1,178 @@
+package idempotency
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/server"
+	"github.com/worldline-go/telemetry_example/internal/telemetry"
+)
+
+type step struct {
+	key  string
+	body string
+	// fail is the behavior of the handler: "" succeeds, "error" returns 500, "panic" panics.
+	fail string
+	// nested sends the request again while the handler runs.
+	nested *step
+
+	wantStatus   int
+	wantCalled   bool
+	wantReplayed bool
+}
+
+func TestMiddleware(t *testing.T) {
+	if err := telemetry.SetGlobalMeter(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "replay",
+			steps: []step{
+				{key: "a", body: `{"v":1}`, wantStatus: http.StatusCreated, wantCalled: true},
+				{key: "a", body: `{"v":1}`, wantStatus: http.StatusCreated, wantReplayed: true},
+			},
+		},
+		{
+			name: "without key",
+			steps: []step{
+				{body: `{"v":1}`, wantStatus: http.StatusCreated, wantCalled: true},
+				{body: `{"v":1}`, wantStatus: http.StatusCreated, wantCalled: true},
+			},
+		},
+		{
+			name: "different body",
+			steps: []step{
+				{key: "a", body: `{"v":1}`, wantStatus: http.StatusCreated, wantCalled: true},
+				{key: "a", body: `{"v":2}`, wantStatus: http.StatusConflict},
+				{key: "b", body: `{"v":2}`, wantStatus: http.StatusCreated, wantCalled: true},
+			},
+		},
+		{
+			name: "in progress",
+			steps: []step{
+				{
+					key: "a", body: `{"v":1}`, wantStatus: http.StatusCreated, wantCalled: true,
+					nested: &step{key: "a", body: `{"v":1}`, wantStatus: http.StatusConflict},
+				},
+				{key: "a", body: `{"v":1}`, wantStatus: http.StatusCreated, wantReplayed: true},
+			},
+		},
+		{
+			name: "failed request releases the key",
+			steps: []step{
+				{key: "a", body: `{"v":1}`, fail: "error", wantStatus: http.StatusInternalServerError, wantCalled: true},
+				{key: "a", body: `{"v":1}`, wantStatus: http.StatusCreated, wantCalled: true},
+			},
+		},
+		{
+			name: "panic releases the key",
+			steps: []step{
+				{key: "a", body: `{"v":1}`, fail: "panic", wantStatus: http.StatusInternalServerError, wantCalled: true},
+				{key: "a", body: `{"v":1}`, wantStatus: http.StatusCreated, wantCalled: true},
+			},
+		},
+		{
+			name: "body too large",
+			steps: []step{
+				{key: "a", body: strings.Repeat("x", 65), wantStatus: http.StatusRequestEntityTooLarge},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := New(config.Idempotency{Store: StoreMemory, TTL: time.Hour, MaxBody: 64}, nil, nil)
+
+			e := echo.New()
+			e.HTTPErrorHandler = server.HTTPErrorHandler
+
+			var (
+				current *step
+				called  bool
+			)
+
+			var send func(s step) *httptest.ResponseRecorder
+
+			e.POST("/products", func(c echo.Context) error {
+				called = true
+
+				s := current
+				if s.nested != nil {
+					rec := send(*s.nested)
+					checkStep(t, "nested", *s.nested, rec, false)
+				}
+
+				switch s.fail {
+				case "error":
+					return echo.NewHTTPError(http.StatusInternalServerError, "failed")
+				case "panic":
+					panic("handler failed")
+				}
+
+				return c.JSON(http.StatusCreated, model.Message{Message: "created " + time.Now().String()})
+			}, middleware.Recover(), i.Middleware())
+
+			send = func(s step) *httptest.ResponseRecorder {
+				current = &s
+
+				req := httptest.NewRequest(http.MethodPost, "/products", strings.NewReader(s.body))
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+				if s.key != "" {
+					req.Header.Set(HeaderKey, s.key)
+				}
+
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+
+				return rec
+			}
+
+			var firstBody string
+
+			for n, s := range tt.steps {
+				called = false
+
+				rec := send(s)
+				checkStep(t, fmt.Sprintf("step %d", n), s, rec, called)
+
+				if s.wantReplayed && rec.Body.String() != firstBody {
+					t.Fatalf("step %d: replayed body %q, want %q", n, rec.Body.String(), firstBody)
+				}
+
+				if rec.Code == http.StatusCreated && firstBody == "" {
+					firstBody = rec.Body.String()
+				}
+			}
+		})
+	}
+}
+
+func checkStep(t *testing.T, name string, s step, rec *httptest.ResponseRecorder, called bool) {
+	t.Helper()
+
+	if rec.Code != s.wantStatus {
+		t.Fatalf("%s: status %d, want %d: %s", name, rec.Code, s.wantStatus, rec.Body.String())
+	}
+
+	if called != s.wantCalled {
+		t.Fatalf("%s: handler called %t, want %t", name, called, s.wantCalled)
+	}
+
+	if replayed := rec.Header().Get(HeaderReplayed) == "true"; replayed != s.wantReplayed {
+		t.Fatalf("%s: replayed %t, want %t", name, replayed, s.wantReplayed)
+	}
+}
@@ -0,0 +1,116 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/worldline-go/telemetry_example/internal/database/dbhandler"
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+const (
+	StoreMemory   = "memory"
+	StorePostgres = "postgres"
+)
+
+// Store keeps the responses of the idempotency keys until they expire.
+type Store interface {
+	// Reserve records the key for the running request, it returns the existing record when the key is used.
+	Reserve(ctx context.Context, key, fingerprint string, expiresAt time.Time) (*model.IdempotencyRecord, error)
+	// Complete stores the response of the reserved key.
+	Complete(ctx context.Context, record model.IdempotencyRecord) error
+	// Delete releases the key.
+	Delete(ctx context.Context, key string) error
+	// DeleteExpired removes the expired keys.
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// memory is the store of a single replica.
+type memory struct {
+	mutex   sync.Mutex
+	records map[string]*model.IdempotencyRecord
+}
+
+func newMemory() *memory {
+	return &memory{records: make(map[string]*model.IdempotencyRecord)}
+}
+
+func (m *memory) Reserve(_ context.Context, key, fingerprint string, expiresAt time.Time) (*model.IdempotencyRecord, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if record, ok := m.records[key]; ok && time.Now().Before(record.ExpiresAt) {
+		v := *record
+
+		return &v, nil
+	}
+
+	m.records[key] = &model.IdempotencyRecord{
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   expiresAt,
+	}
+
+	return nil, nil
+}
+
+func (m *memory) Complete(_ context.Context, record model.IdempotencyRecord) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if current, ok := m.records[record.Key]; ok {
+		current.Status = record.Status
+		current.ContentType = record.ContentType
+		current.Body = record.Body
+	}
+
+	return nil
+}
+
+func (m *memory) Delete(_ context.Context, key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.records, key)
+
+	return nil
+}
+
+func (m *memory) DeleteExpired(_ context.Context) (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+
+	var count int64
+	for key, record := range m.records {
+		if now.After(record.ExpiresAt) {
+			delete(m.records, key)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// postgres shares the keys between replicas.
+type postgres struct {
+	db *dbhandler.Handler
+}
+
+func (p postgres) Reserve(ctx context.Context, key, fingerprint string, expiresAt time.Time) (*model.IdempotencyRecord, error) {
+	return p.db.ReserveIdempotencyKey(ctx, key, fingerprint, expiresAt) //nolint:wrapcheck // no need
+}
+
+func (p postgres) Complete(ctx context.Context, record model.IdempotencyRecord) error {
+	return p.db.CompleteIdempotencyKey(ctx, record) //nolint:wrapcheck // no need
+}
+
+func (p postgres) Delete(ctx context.Context, key string) error {
+	return p.db.DeleteIdempotencyKey(ctx, key) //nolint:wrapcheck // no need
+}
+
+func (p postgres) DeleteExpired(ctx context.Context) (int64, error) {
+	return p.db.DeleteExpiredIdempotencyKeys(ctx) //nolint:wrapcheck // no need
+}
@@ -2,11 +2,17 @@ package kafka
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
-	"github.com/rs/zerolog/log"
 	"github.com/twmb/franz-go/plugin/kotel"
+	"github.com/worldline-go/telemetry_example/internal/cache"
 	"github.com/worldline-go/telemetry_example/internal/database/dbhandler"
+	"github.com/worldline-go/telemetry_example/internal/logging"
 	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/search"
+	"github.com/worldline-go/telemetry_example/internal/stream"
+	"github.com/worldline-go/telemetry_example/internal/tenant"
 	"github.com/worldline-go/wkafka"
 	"go.opentelemetry.io/otel/attribute"
 )
@@ -14,16 +20,55 @@ import (
 type Kafka struct {
 	DB     *dbhandler.Handler
 	Tracer *kotel.Tracer
+	// Streams pushes the consumed products to the stream subscribers.
+	Streams *stream.Broker
+	// Search indexes the consumed products when set.
+	Search search.Searcher
+	// Cache drops the consumed products to read the changes of the other replicas when set.
+	Cache *cache.Products
+	// Tenants checks the tenant header of the records when set, records without it belong to the main schema.
+	Tenants *tenant.Registry
 }
 
 func (k *Kafka) Consume(ctx context.Context, product model.Product) error {
+	record := wkafka.CtxRecord(ctx)
+
 	// use tracer's returned ctx for next spans
-	_, span := k.Tracer.WithProcessSpan(wkafka.CtxRecord(ctx))
+	ctx, span := k.Tracer.WithProcessSpan(record)
 	defer span.End()
 
 	span.SetAttributes(attribute.String("product.name", product.Name))
 
-	log.Info().Str("product", product.Name).Str("description", product.Description).Msg("consume message")
+	if id := tenant.FromRecord(record); id != "" && k.Tenants != nil {
+		if !tenant.Valid(id) {
+			logging.Package("kafka").Warn().Str("tenant", id).Str("product", product.Name).Msg("skip message of invalid tenant")
+
+			return nil
+		}
+
+		if _, err := k.Tenants.DB(ctx, id); err != nil {
+			if !errors.Is(err, model.ErrNotFound) {
+				return fmt.Errorf("failed to get tenant [%s]; %w", id, err)
+			}
+
+			logging.Package("kafka").Warn().Str("tenant", id).Str("product", product.Name).Msg("skip message of unknown tenant")
+
+			return nil
+		}
+
+		span.SetAttributes(attribute.String(tenant.AttributeKey, id))
+		ctx = tenant.WithID(ctx, id)
+	}
+
+	logging.Package("kafka").Info().Str("tenant", tenant.FromContext(ctx)).Str("product", product.Name).Str("description", product.Description).Msg("consume message")
+
+	k.Cache.Invalidate(ctx, product.Name)
+
+	if k.Search != nil {
+		k.Search.Index(ctx, &product)
+	}
+
+	k.Streams.Publish(ctx, stream.TypeProductReceived, stream.SourceKafka, product.Name, &product)
 
 	return nil
 }
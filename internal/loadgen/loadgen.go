@@ -0,0 +1,291 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+)
+
+// Config of the load generator.
+type Config struct {
+	// Target is the base url of the service like http://localhost:8080.
+	Target string
+	// Scenarios run one after another by the workers.
+	Scenarios []string
+	// RPS is the target scenario runs per second, zero runs as fast as the workers can.
+	RPS float64
+	// Concurrency is the number of workers.
+	Concurrency int
+	// RampUp increases the rate from zero to RPS, or starts the workers gradually without RPS.
+	RampUp time.Duration
+	// Duration of the run.
+	Duration time.Duration
+	// Timeout of each request.
+	Timeout time.Duration
+	// Interval to log the progress, zero disables it.
+	Interval time.Duration
+	// Headers added to all requests like authentication.
+	Headers http.Header
+	// Chain of services for the call scenario, first one is called by the generator.
+	Chain []string
+}
+
+func (c Config) validate() error {
+	var errs []error
+
+	if c.Target == "" {
+		errs = append(errs, errors.New("target is required"))
+	}
+
+	if len(c.Scenarios) == 0 {
+		errs = append(errs, errors.New("at least one scenario is required"))
+	}
+
+	for _, name := range c.Scenarios {
+		if _, ok := scenarios[name]; !ok {
+			errs = append(errs, fmt.Errorf("unknown scenario [%s], must be one of [%s]", name, strings.Join(Scenarios(), " ")))
+		}
+
+		if name == ScenarioCall && len(c.Chain) == 0 {
+			errs = append(errs, errors.New("chain is required for the call scenario"))
+		}
+	}
+
+	if c.RPS < 0 {
+		errs = append(errs, errors.New("rps must not be negative"))
+	}
+
+	if c.Concurrency < 1 {
+		errs = append(errs, errors.New("concurrency must be at least 1"))
+	}
+
+	if c.Duration <= 0 {
+		errs = append(errs, errors.New("duration must be positive"))
+	}
+
+	if c.RampUp < 0 || c.RampUp > c.Duration {
+		errs = append(errs, errors.New("ramp-up must be between 0 and duration"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Runner sends the requests of the scenarios and collects the results.
+type Runner struct {
+	cfg    Config
+	client *http.Client
+	tracer trace.Tracer
+	stats  *stats
+}
+
+// Run drives the scenarios until the duration ends and returns the report.
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	r := &Runner{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		tracer: otel.Tracer("loadgen"),
+		stats:  newStats(),
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	if cfg.RPS > 0 {
+		limiter = rate.NewLimiter(rate.Limit(cfg.RPS), 1)
+	}
+
+	start := time.Now()
+
+	var wg sync.WaitGroup
+
+	if cfg.RPS > 0 && cfg.RampUp > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.ramp(runCtx, limiter, start)
+		}()
+	}
+
+	if cfg.Interval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.progress(runCtx, start)
+		}()
+	}
+
+	var next atomic.Uint64
+
+	for i := range cfg.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// without rate, ramp-up starts the workers one by one
+			if cfg.RPS == 0 && cfg.RampUp > 0 {
+				select {
+				case <-runCtx.Done():
+					return
+				case <-time.After(cfg.RampUp * time.Duration(i) / time.Duration(cfg.Concurrency)):
+				}
+			}
+
+			for {
+				if err := limiter.Wait(runCtx); err != nil {
+					return
+				}
+
+				name := cfg.Scenarios[(next.Add(1)-1)%uint64(len(cfg.Scenarios))]
+
+				// in-flight requests are not canceled at the end of the duration
+				r.run(context.WithoutCancel(runCtx), name)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return r.stats.report(time.Since(start)), nil
+}
+
+func (r *Runner) ramp(ctx context.Context, limiter *rate.Limiter, start time.Time) {
+	limiter.SetLimit(rate.Limit(min(1, r.cfg.RPS)))
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		elapsed := time.Since(start)
+		if elapsed >= r.cfg.RampUp {
+			limiter.SetLimit(rate.Limit(r.cfg.RPS))
+
+			return
+		}
+
+		limiter.SetLimit(rate.Limit(max(1, r.cfg.RPS*float64(elapsed)/float64(r.cfg.RampUp))))
+	}
+}
+
+func (r *Runner) progress(ctx context.Context, start time.Time) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		requests, errs := r.stats.totals()
+		log.Info().Str("elapsed", time.Since(start).Truncate(time.Second).String()).
+			Int("requests", requests).Int("errors", errs).
+			Msg("loadgen progress")
+	}
+}
+
+func (r *Runner) run(ctx context.Context, name string) {
+	ctx, span := r.tracer.Start(ctx, "loadgen "+name, trace.WithSpanKind(trace.SpanKindInternal))
+	defer span.End()
+
+	span.SetAttributes(attribute.String("loadgen.scenario", name))
+
+	if err := scenarios[name](ctx, r); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// do sends the request with a client span and records the result with the operation name.
+func (r *Runner) do(ctx context.Context, op, method, path string, body any) ([]byte, error) {
+	ctx, span := r.tracer.Start(ctx, op, trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	url := strings.TrimSuffix(r.cfg.Target, "/") + path
+
+	span.SetAttributes(
+		attribute.String("http.request.method", method),
+		attribute.String("url.full", url),
+	)
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal body; %w", err)
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request; %w", err)
+	}
+
+	for key, values := range r.cfg.Headers {
+		request.Header[key] = values
+	}
+
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(request.Header))
+
+	start := time.Now()
+
+	response, err := r.client.Do(request)
+	if err != nil {
+		r.stats.record(op, time.Since(start), 0)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, fmt.Errorf("%s failed; %w", op, err)
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+
+	r.stats.record(op, time.Since(start), response.StatusCode)
+	span.SetAttributes(attribute.Int("http.response.status_code", response.StatusCode))
+
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, fmt.Errorf("%s failed to read response; %w", op, err)
+	}
+
+	if response.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(response.StatusCode))
+
+		return nil, fmt.Errorf("%s responded with status %d", op, response.StatusCode)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,171 @@
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+type result struct {
+	durations []time.Duration
+	errors    int
+	statuses  map[int]int
+}
+
+type stats struct {
+	mutex   sync.Mutex
+	results map[string]*result
+}
+
+func newStats() *stats {
+	return &stats{results: make(map[string]*result)}
+}
+
+// record keeps the result of the operation, status 0 is a failed request without response.
+func (s *stats) record(op string, duration time.Duration, status int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	res, ok := s.results[op]
+	if !ok {
+		res = &result{statuses: make(map[int]int)}
+		s.results[op] = res
+	}
+
+	res.durations = append(res.durations, duration)
+	res.statuses[status]++
+
+	if status == 0 || status >= 400 {
+		res.errors++
+	}
+}
+
+func (s *stats) totals() (requests, errors int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, res := range s.results {
+		requests += len(res.durations)
+		errors += res.errors
+	}
+
+	return requests, errors
+}
+
+func (s *stats) report(elapsed time.Duration) *Report {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	report := &Report{Elapsed: elapsed}
+
+	for op, res := range s.results {
+		durations := append([]time.Duration(nil), res.durations...)
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+		statuses := make(map[int]int, len(res.statuses))
+		for status, count := range res.statuses {
+			statuses[status] = count
+		}
+
+		report.Operations = append(report.Operations, Operation{
+			Name:     op,
+			Requests: len(durations),
+			Errors:   res.errors,
+			Statuses: statuses,
+			P50:      percentile(durations, 50),
+			P90:      percentile(durations, 90),
+			P99:      percentile(durations, 99),
+			Max:      durations[len(durations)-1],
+		})
+	}
+
+	sort.Slice(report.Operations, func(i, j int) bool {
+		return report.Operations[i].Name < report.Operations[j].Name
+	})
+
+	return report
+}
+
+// percentile with nearest rank of the sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := (p*len(sorted) + 99) / 100
+
+	return sorted[max(rank, 1)-1]
+}
+
+// Report of the run per operation.
+type Report struct {
+	Elapsed    time.Duration
+	Operations []Operation
+}
+
+type Operation struct {
+	Name     string
+	Requests int
+	Errors   int
+	// Statuses count of the response codes, 0 is a request without response.
+	Statuses map[int]int
+
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// ErrorRate is between 0 and 1.
+func (o Operation) ErrorRate() float64 {
+	if o.Requests == 0 {
+		return 0
+	}
+
+	return float64(o.Errors) / float64(o.Requests)
+}
+
+// Print writes the report as a table.
+func (r *Report) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "OPERATION\tREQUESTS\tRPS\tERRORS\tERROR RATE\tP50\tP90\tP99\tMAX\tSTATUSES\n")
+
+	for _, o := range r.Operations {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%d\t%.2f%%\t%s\t%s\t%s\t%s\t%s\n",
+			o.Name, o.Requests, float64(o.Requests)/r.Elapsed.Seconds(),
+			o.Errors, o.ErrorRate()*100,
+			round(o.P50), round(o.P90), round(o.P99), round(o.Max),
+			statuses(o.Statuses),
+		)
+	}
+
+	return tw.Flush() //nolint:wrapcheck // no need
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
+
+func statuses(m map[int]int) string {
+	codes := make([]int, 0, len(m))
+	for code := range m {
+		codes = append(codes, code)
+	}
+
+	sort.Ints(codes)
+
+	var out string
+	for i, code := range codes {
+		if i > 0 {
+			out += " "
+		}
+
+		out += fmt.Sprintf("%d:%d", code, m[code])
+	}
+
+	return out
+}
@@ -0,0 +1,86 @@
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+const (
+	ScenarioCount   = "count"
+	ScenarioProduct = "product"
+	ScenarioCall    = "call"
+)
+
+var scenarios = map[string]func(ctx context.Context, r *Runner) error{
+	ScenarioCount:   countScenario,
+	ScenarioProduct: productScenario,
+	ScenarioCall:    callScenario,
+}
+
+// Scenarios returns the names of the scenarios.
+func Scenarios() []string {
+	names := make([]string, 0, len(scenarios))
+	for name := range scenarios {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// countScenario increments the counter and reads it.
+func countScenario(ctx context.Context, r *Runner) error {
+	count := strconv.Itoa(rand.IntN(100) + 1) //nolint:gosec // no need
+
+	if _, err := r.do(ctx, "count_add", http.MethodPost, "/api/v1/count?count="+count, nil); err != nil {
+		return err
+	}
+
+	_, err := r.do(ctx, "count_get", http.MethodGet, "/api/v1/count", nil)
+
+	return err
+}
+
+// productScenario creates a product, gets it and sends it to kafka.
+func productScenario(ctx context.Context, r *Runner) error {
+	name := fmt.Sprintf("loadgen-%016x", rand.Uint64()) //nolint:gosec // no need
+	path := url.PathEscape(name)
+
+	if _, err := r.do(ctx, "product_create", http.MethodPost, "/api/v1/products", model.Product{
+		Name:        name,
+		Description: "created by loadgen",
+	}); err != nil {
+		return err
+	}
+
+	if _, err := r.do(ctx, "product_get", http.MethodGet, "/api/v1/products/"+path, nil); err != nil {
+		return err
+	}
+
+	_, err := r.do(ctx, "product_send", http.MethodPost, "/api/v1/products-send/"+path, nil)
+
+	return err
+}
+
+// callScenario calls the first service of the chain with the rest of the chain.
+func callScenario(ctx context.Context, r *Runner) error {
+	steps := make([]model.Step, 0, len(r.cfg.Chain)-1)
+	for _, service := range r.cfg.Chain[1:] {
+		steps = append(steps, model.Step{Service: service})
+	}
+
+	_, err := r.do(ctx, "call", http.MethodPost, "/api/v1/call/"+url.PathEscape(r.cfg.Chain[0]), model.Service{
+		Message: "loadgen",
+		Chain:   steps,
+	})
+
+	return err
+}
@@ -0,0 +1,235 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/worldline-go/logz"
+
+	"github.com/worldline-go/telemetry_example/internal/secret"
+)
+
+// Settings of the logger changeable at runtime.
+type Settings struct {
+	Level string `json:"level" validate:"required"`
+	// Routes level per echo route prefix like {"/api/v1/products": "debug"}.
+	Routes map[string]string `json:"routes,omitempty"`
+	// Packages level per package name like {"kafka": "debug"}.
+	Packages map[string]string `json:"packages,omitempty"`
+	// SampleRate logs 1 of every N events below warn level, 0 and 1 logs all.
+	SampleRate uint32 `json:"sample_rate,omitempty"`
+}
+
+// sampler drops the events below the level and samples the events below warn level.
+// zerolog global level is set to the lowest level, samplers do the real filtering.
+type sampler struct {
+	level   zerolog.Level
+	counter atomic.Uint32
+}
+
+func (s *sampler) Sample(lvl zerolog.Level) bool {
+	if lvl < s.level {
+		return false
+	}
+
+	if lvl >= zerolog.WarnLevel {
+		return true
+	}
+
+	n := sampleRate.Load()
+	if n <= 1 {
+		return true
+	}
+
+	return s.counter.Add(1)%n == 0
+}
+
+// baseSampler shared by all copies of log.Logger.
+type baseSampler struct {
+	level   atomic.Int32
+	sampler sampler
+}
+
+func (s *baseSampler) Sample(lvl zerolog.Level) bool {
+	if lvl < zerolog.Level(s.level.Load()) {
+		return false
+	}
+
+	return s.sampler.Sample(lvl)
+}
+
+type state struct {
+	settings Settings
+	routes   []routeLevel
+	packages map[string]*sampler
+}
+
+type routeLevel struct {
+	prefix  string
+	sampler *sampler
+}
+
+var (
+	base       = &baseSampler{sampler: sampler{level: zerolog.TraceLevel}}
+	sampleRate atomic.Uint32
+
+	mutex   sync.RWMutex
+	current = &state{settings: Settings{Level: zerolog.InfoLevel.String()}}
+
+	initOnce sync.Once
+)
+
+// Init wraps log.Logger with the runtime changeable sampler and the secret redaction.
+// It should be called before copying log.Logger to other loggers.
+func Init(level string) error {
+	initOnce.Do(func() {
+		log.Logger = log.Logger.Output(output()).Sample(base)
+	})
+
+	return SetLevel(level)
+}
+
+// Current returns the active settings.
+func Current() Settings {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	return current.settings
+}
+
+// SetLevel changes only the base level.
+func SetLevel(level string) error {
+	settings := Current()
+	settings.Level = level
+
+	return Apply(settings)
+}
+
+// Apply replaces all the settings, nothing changes if one of the levels is invalid.
+func Apply(settings Settings) error {
+	baseLevel, err := zerolog.ParseLevel(settings.Level)
+	if err != nil {
+		return fmt.Errorf("invalid level [%s]; %w", settings.Level, err)
+	}
+
+	lowest := baseLevel
+
+	newState := &state{
+		settings: settings,
+		packages: make(map[string]*sampler, len(settings.Packages)),
+	}
+
+	for prefix, level := range settings.Routes {
+		lvl, err := zerolog.ParseLevel(level)
+		if err != nil {
+			return fmt.Errorf("invalid level [%s] of route [%s]; %w", level, prefix, err)
+		}
+
+		lowest = min(lowest, lvl)
+
+		newState.routes = append(newState.routes, routeLevel{prefix: prefix, sampler: &sampler{level: lvl}})
+	}
+
+	// longest prefix first
+	sort.Slice(newState.routes, func(i, j int) bool {
+		return len(newState.routes[i].prefix) > len(newState.routes[j].prefix)
+	})
+
+	for name, level := range settings.Packages {
+		lvl, err := zerolog.ParseLevel(level)
+		if err != nil {
+			return fmt.Errorf("invalid level [%s] of package [%s]; %w", level, name, err)
+		}
+
+		lowest = min(lowest, lvl)
+
+		newState.packages[name] = &sampler{level: lvl}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	current = newState
+
+	base.level.Store(int32(baseLevel))
+	sampleRate.Store(settings.SampleRate)
+	zerolog.SetGlobalLevel(lowest)
+
+	return nil
+}
+
+// Package returns logger of the package, level is the package's level if set.
+func Package(name string) *zerolog.Logger {
+	mutex.RLock()
+	s, ok := current.packages[name]
+	mutex.RUnlock()
+
+	logger := log.Logger.With().Str("package", name).Logger()
+	if ok {
+		logger = logger.Sample(s)
+	}
+
+	return &logger
+}
+
+func routeSampler(path string) *sampler {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	for _, r := range current.routes {
+		if strings.HasPrefix(path, r.prefix) {
+			return r.sampler
+		}
+	}
+
+	return nil
+}
+
+// Middleware changes the request's context logger when the route has a level.
+// It should be after the middleware adding the request logger to the context.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			s := routeSampler(c.Path())
+			if s == nil {
+				return next(c)
+			}
+
+			ctx := c.Request().Context()
+			logger := zerolog.Ctx(ctx).Sample(s)
+
+			c.SetRequest(c.Request().WithContext(logger.WithContext(ctx)))
+
+			return next(c)
+		}
+	}
+}
+
+// output is the same writer of logz with redacted secrets.
+func output() io.Writer {
+	out := secret.NewWriter(os.Stderr)
+
+	pretty := isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd())
+	if v, ok := os.LookupEnv(logz.EnvPretty); ok {
+		pretty, _ = strconv.ParseBool(v)
+	}
+
+	if pretty {
+		w := logz.LogWriter
+		w.Out = out
+
+		return w
+	}
+
+	return out
+}
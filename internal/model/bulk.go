@@ -0,0 +1,53 @@
+package model
+
+const (
+	ConflictUpsert = "upsert"
+	ConflictSkip   = "skip"
+	ConflictFail   = "fail"
+
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// ImportQuery is the query of the product import.
+type ImportQuery struct {
+	// OnConflict is upsert, skip or fail for the existing products, default is fail.
+	OnConflict string `query:"on_conflict" validate:"omitempty,oneof=upsert skip fail"`
+}
+
+// ImportColumns are the optional fields given in the import rows, existing products only get these fields changed.
+type ImportColumns struct {
+	Description bool
+	CategoryID  bool
+	Tags        bool
+	Price       bool
+}
+
+// ImportedProduct is the recorded product of an import row.
+type ImportedProduct struct {
+	Product  Product
+	Inserted bool
+}
+
+// ExportQuery is the query of the product export.
+type ExportQuery struct {
+	// Format is ndjson or csv, default is ndjson.
+	Format string `query:"format" validate:"omitempty,oneof=ndjson csv"`
+}
+
+// ImportReport is the result of the product import.
+type ImportReport struct {
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	Skipped  int `json:"skipped"`
+	Failed   int `json:"failed"`
+	// Errors of the failed rows, only the first ones are listed.
+	Errors []ImportError `json:"errors,omitempty"`
+}
+
+// ImportError is the problem of a row, line starts from 1 and the csv header is line 1.
+type ImportError struct {
+	Line  int    `json:"line"`
+	Name  string `json:"name,omitempty"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,13 @@
+package model
+
+// Category of the products, categories with a parent are subcategories.
+type Category struct {
+	ID       int64  `db:"id"        json:"id"                  readonly:"true"`
+	Name     string `db:"name"      json:"name"                validate:"required,max=255"`
+	ParentID *int64 `db:"parent_id" json:"parent_id,omitempty" validate:"omitempty,min=1"`
+	// Path is the names from the root category like "Food / Fruit".
+	Path      string `db:"path"       json:"path"       readonly:"true"`
+	LastUser  string `db:"last_user"  json:"last_user"  readonly:"true"`
+	UpdatedAt string `db:"updated_at" json:"updated_at" readonly:"true"`
+	CreatedAt string `db:"created_at" json:"created_at" readonly:"true"`
+}
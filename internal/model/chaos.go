@@ -0,0 +1,106 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	LatencyFixed       = "fixed"
+	LatencyUniform     = "uniform"
+	LatencyNormal      = "normal"
+	LatencyExponential = "exponential"
+)
+
+// Fault to inject to a request, rates are probabilities between 0 and 1.
+type Fault struct {
+	Latency Latency `cfg:"latency" json:"latency,omitempty"`
+	// ErrorRate of responding with Status.
+	ErrorRate float64 `cfg:"error_rate" json:"error_rate,omitempty" validate:"gte=0,lte=1"`
+	// Status of the injected error, default is 500.
+	Status int `cfg:"status" json:"status,omitempty" validate:"omitempty,gte=400,lte=599"`
+	// PanicRate of panicking in the handler.
+	PanicRate float64 `cfg:"panic_rate" json:"panic_rate,omitempty" validate:"gte=0,lte=1"`
+	// DBErrorRate of failing the database calls.
+	DBErrorRate float64 `cfg:"db_error_rate" json:"db_error_rate,omitempty" validate:"gte=0,lte=1"`
+	// KafkaErrorRate of failing the produced messages.
+	KafkaErrorRate float64 `cfg:"kafka_error_rate" json:"kafka_error_rate,omitempty" validate:"gte=0,lte=1"`
+}
+
+// Latency added before the handler, durations are like "250ms" in JSON.
+type Latency struct {
+	// Distribution is one of fixed, uniform, normal, exponential; default is fixed.
+	Distribution string `cfg:"distribution" json:"distribution,omitempty" validate:"omitempty,oneof=fixed uniform normal exponential"`
+	// Duration is the value of fixed, minimum of uniform and mean of normal and exponential.
+	Duration time.Duration `cfg:"duration" json:"duration,omitempty" validate:"gte=0,lte=1m" swaggertype:"string" example:"250ms"`
+	// Spread is the maximum above Duration for uniform and standard deviation for normal.
+	Spread time.Duration `cfg:"spread" json:"spread,omitempty" validate:"gte=0,lte=1m" swaggertype:"string" example:"100ms"`
+	// Rate of the requests getting latency, zero adds it to all requests.
+	Rate float64 `cfg:"rate" json:"rate,omitempty" validate:"gte=0,lte=1"`
+}
+
+func (l Latency) MarshalJSON() ([]byte, error) {
+	type plain Latency
+
+	v := struct {
+		plain
+		Duration string `json:"duration,omitempty"`
+		Spread   string `json:"spread,omitempty"`
+	}{plain: plain(l)}
+
+	// forwarded faults are read with UnmarshalJSON, it doesn't accept the nanoseconds
+	if l.Duration != 0 {
+		v.Duration = l.Duration.String()
+	}
+
+	if l.Spread != 0 {
+		v.Spread = l.Spread.String()
+	}
+
+	return json.Marshal(v) //nolint:wrapcheck // no need
+}
+
+func (l *Latency) UnmarshalJSON(data []byte) error {
+	type plain Latency
+
+	var v struct {
+		plain
+		Duration string `json:"duration"`
+		Spread   string `json:"spread"`
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err //nolint:wrapcheck // no need
+	}
+
+	*l = Latency(v.plain)
+
+	for _, d := range []struct {
+		name  string
+		value string
+		to    *time.Duration
+	}{
+		{"duration", v.Duration, &l.Duration},
+		{"spread", v.Spread, &l.Spread},
+	} {
+		if d.value == "" {
+			continue
+		}
+
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			return fmt.Errorf("latency %s [%s] is invalid; %w", d.name, d.value, err)
+		}
+
+		*d.to = parsed
+	}
+
+	return nil
+}
+
+// Enabled returns true if any fault is set.
+func (f *Fault) Enabled() bool {
+	return f != nil && (f.Latency.Duration > 0 || f.Latency.Spread > 0 || f.ErrorRate > 0 || f.PanicRate > 0 ||
+		f.DBErrorRate > 0 || f.KafkaErrorRate > 0)
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestLatencyJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		latency Latency
+		want    string
+	}{
+		{
+			name:    "durations",
+			latency: Latency{Distribution: LatencyNormal, Duration: 250 * time.Millisecond, Spread: 100 * time.Millisecond, Rate: 0.5},
+			want:    `{"distribution":"normal","rate":0.5,"duration":"250ms","spread":"100ms"}`,
+		},
+		{
+			name:    "without spread",
+			latency: Latency{Duration: 1500 * time.Millisecond},
+			want:    `{"duration":"1.5s"}`,
+		},
+		{
+			name: "empty",
+			want: `{}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// faults of the chain steps are forwarded inside of the request body
+			data, err := json.Marshal(Fault{Latency: tt.latency})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := `{"latency":` + tt.want + `}`; string(data) != want {
+				t.Fatalf("want %s, got %s", want, data)
+			}
+
+			var got Fault
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Latency != tt.latency {
+				t.Fatalf("want %+v, got %+v", tt.latency, got.Latency)
+			}
+		})
+	}
+}
@@ -5,7 +5,58 @@ type Message struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
+const (
+	// MaxChainSteps limits the service steps of a chain with the parallel ones, each step is a call.
+	MaxChainSteps = 32
+	// MaxChainDepth limits the nested parallel steps.
+	MaxChainDepth = 3
+)
+
 type Service struct {
-	Message string `json:"message"`
+	Message string `json:"message" validate:"max=1024"`
 	Error   bool   `json:"error"`
+	// Fault injected to the called service, chaos should be enabled.
+	Fault *Fault `json:"fault,omitempty"`
+	// Chain of the next steps, each called service forwards the remaining steps.
+	Chain []Step `json:"chain,omitempty" validate:"max=16,chain,dive"`
+}
+
+// Step of a call chain, one of Service and Parallel should be set.
+type Step struct {
+	// Service to call with the remaining steps.
+	Service string `json:"service,omitempty" validate:"required_without=Parallel,excluded_with=Parallel,max=64"`
+	// Parallel chains called at the same time, responses are gathered before the next step.
+	Parallel [][]Step `json:"parallel,omitempty" validate:"max=8,dive,min=1,max=16,dive"`
+	// Fault injected to the service of the step.
+	Fault *Fault `json:"fault,omitempty"`
+}
+
+// Gather is the data of the parallel step response.
+type Gather struct {
+	Parallel []Message `json:"parallel"`
+	// Next is the response of the steps after the parallel step.
+	Next *Message `json:"next,omitempty"`
+}
+
+// Count is the query of the count endpoint.
+type Count struct {
+	Count int64 `query:"count" validate:"gte=0,lte=1000000"`
+}
+
+// ChainSize returns the service steps and the nesting depth of the parallel steps.
+func ChainSize(steps []Step) (count, depth int) {
+	for _, step := range steps {
+		if step.Service != "" {
+			count++
+		}
+
+		for _, branch := range step.Parallel {
+			c, d := ChainSize(branch)
+
+			count += c
+			depth = max(depth, d+1)
+		}
+	}
+
+	return count, depth
 }
@@ -1,10 +1,91 @@
 package model
 
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Product is also the kafka message, new fields are optional to read the messages of the older versions.
 type Product struct {
-	ID          int64  `db:"id"          json:"id"`
-	Name        string `db:"name"        json:"name"`
-	Description string `db:"description" json:"description"`
-	LastUser    string `db:"last_user"   json:"last_user"`
-	UpdatedAt   string `db:"updated_at"  json:"updated_at"`
-	CreatedAt   string `db:"created_at"  json:"created_at"`
+	ID          int64  `db:"id"          json:"id"          readonly:"true"`
+	Name        string `db:"name"        json:"name"        validate:"required,max=255"`
+	Description string `db:"description" json:"description" validate:"max=4096"`
+	// CategoryID of the product, listing with a category includes the products of its subcategories.
+	CategoryID *int64 `db:"category_id" json:"category_id,omitempty" validate:"omitempty,min=1"`
+	Tags       Tags   `db:"tags"        json:"tags,omitempty"        validate:"max=20,dive,required,max=64" swaggertype:"array,string"`
+	// Price is stored in the price_amount and price_currency columns.
+	Price     *Price `db:"-"          json:"price,omitempty"`
+	LastUser  string `db:"last_user"  json:"last_user"  readonly:"true"`
+	UpdatedAt string `db:"updated_at" json:"updated_at" readonly:"true"`
+	CreatedAt string `db:"created_at" json:"created_at" readonly:"true"`
+}
+
+// Price in the minor units of the currency, 1999 is 19.99 EUR.
+type Price struct {
+	Amount   int64  `json:"amount"   validate:"min=0"`
+	Currency string `json:"currency" validate:"required,iso4217"`
+}
+
+// Tags are stored as json array.
+type Tags []string
+
+func (t Tags) Value() (driver.Value, error) {
+	if t == nil {
+		return "[]", nil
+	}
+
+	v, err := json.Marshal([]string(t))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tags: %w", err)
+	}
+
+	return string(v), nil
+}
+
+func (t *Tags) Scan(src interface{}) error {
+	var v []byte
+	switch src := src.(type) {
+	case string:
+		v = []byte(src)
+	case []byte:
+		v = src
+	case nil:
+		*t = nil
+
+		return nil
+	default:
+		return fmt.Errorf("unsupported tags type %T", src)
+	}
+
+	*t = nil
+	if err := json.Unmarshal(v, (*[]string)(t)); err != nil {
+		return fmt.Errorf("failed to unmarshal tags: %w", err)
+	}
+
+	if len(*t) == 0 {
+		*t = nil
+	}
+
+	return nil
+}
+
+// ProductFilter selects the listed products, all set fields should match.
+type ProductFilter struct {
+	// CategoryID matches the category and its subcategories.
+	CategoryID int64 `query:"category" validate:"omitempty,min=1"`
+	// Tags matches the products having all tags.
+	Tags []string `query:"tag" validate:"max=10,dive,required,max=64"`
+	// Currency of the price, required with the price range.
+	Currency string `query:"currency"  validate:"required_with=MinPrice MaxPrice,omitempty,iso4217"`
+	MinPrice *int64 `query:"min_price" validate:"omitempty,min=0"`
+	MaxPrice *int64 `query:"max_price" validate:"omitempty,min=0"`
+}
+
+// ProductsQuery is the query of the product listing.
+type ProductsQuery struct {
+	ProductFilter
+	// After is the id of the last product of the previous page.
+	After int64 `query:"after" validate:"min=0"`
+	Limit int   `query:"limit" validate:"omitempty,min=1,max=100"`
 }
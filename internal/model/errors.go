@@ -0,0 +1,88 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrDuplicate = errors.New("duplicate record")
+	ErrConflict  = errors.New("conflict")
+	ErrUpstream  = errors.New("upstream failure")
+
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+
+	ErrTooManyRequests = errors.New("too many requests")
+	ErrUnavailable     = errors.New("service unavailable")
+)
+
+// Error is a domain error with a detail safe to return to clients.
+// Cause is kept for logs and never rendered in responses.
+type Error struct {
+	// Kind is one of the Err* values like ErrNotFound.
+	Kind   error
+	Detail string
+	Cause  error
+}
+
+func (e *Error) Error() string {
+	if e.Cause == nil {
+		return e.Detail
+	}
+
+	return e.Detail + ": " + e.Cause.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Cause}
+}
+
+// UpstreamError is returned when a call to another service fails.
+type UpstreamError struct {
+	Service string
+	// StatusCode of the upstream response, 0 if no response received.
+	StatusCode int
+	Cause      error
+}
+
+func (e *UpstreamError) Error() string {
+	return e.Detail() + ": " + e.Cause.Error()
+}
+
+// Detail returns message without the upstream's response.
+func (e *UpstreamError) Detail() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("service [%s] is not reachable", e.Service)
+	}
+
+	return fmt.Sprintf("service [%s] responded with status %d", e.Service, e.StatusCode)
+}
+
+func (e *UpstreamError) Unwrap() []error {
+	return []error{ErrUpstream, e.Cause}
+}
+
+// FieldError describes one invalid field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationError holds all invalid fields of a request.
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Message)
+	}
+
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// Event is a change pushed to the stream subscribers.
+type Event struct {
+	// ID increases with every event of the service.
+	ID uint64 `json:"id"`
+	// Type like count or product.created.
+	Type string `json:"type"`
+	// Source is local for the writes of this service and kafka for the consumed messages.
+	Source string `json:"source,omitempty"`
+	// Key is the product name of the product events.
+	Key  string      `json:"key,omitempty"`
+	Data interface{} `json:"data,omitempty"`
+	// Tenant of the request which made the change.
+	Tenant string `json:"tenant,omitempty"`
+	// TraceID of the request which made the change.
+	TraceID string    `json:"trace_id,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// StreamFilter is the query of the stream endpoints, empty fields match all events.
+type StreamFilter struct {
+	// Types like count or product, product matches all product events.
+	Types []string `query:"type" validate:"dive,oneof=count product product.created product.updated product.deleted product.sent product.received"`
+	// Names of the products, count events are not filtered by names.
+	Names []string `query:"name" validate:"dive,max=255"`
+}
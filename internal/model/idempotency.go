@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// IdempotencyRecord is the stored response of a request with an idempotency key.
+type IdempotencyRecord struct {
+	// Key is the hash of the idempotency key with the subject and the route.
+	Key string `db:"key"`
+	// Fingerprint is the hash of the request to detect a reused key.
+	Fingerprint string `db:"fingerprint"`
+	// Status is 0 until the first request is completed.
+	Status      int       `db:"status"`
+	ContentType string    `db:"content_type"`
+	Body        []byte    `db:"body"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
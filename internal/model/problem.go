@@ -0,0 +1,17 @@
+package model
+
+// Problem is the RFC 7807 error response with tracing information.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	TraceID   string `json:"trace_id,omitempty"`
+	SpanID    string `json:"span_id,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+
+	// Errors filled for validation problems.
+	Errors []FieldError `json:"errors,omitempty"`
+}
@@ -0,0 +1,21 @@
+package model
+
+// SearchQuery is the query of the product search.
+type SearchQuery struct {
+	// Q is the search text, words are matched with stemming and names with typos.
+	Q     string `query:"q"     validate:"required,max=255"`
+	Limit int    `query:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+// SearchResult is a matched product, results are ordered by the rank.
+type SearchResult struct {
+	Product    Product    `json:"product"`
+	Rank       float64    `json:"rank"`
+	Highlights Highlights `json:"highlights"`
+}
+
+// Highlights are the html escaped texts with the matched words wrapped with <mark> tags.
+type Highlights struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
@@ -0,0 +1,8 @@
+package model
+
+// Tenant has its own database schema.
+type Tenant struct {
+	ID string `json:"id" validate:"required,max=48"`
+	// Schema of the tenant in the database.
+	Schema string `json:"schema,omitempty"`
+}
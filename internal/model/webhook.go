@@ -0,0 +1,81 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WebhookEvents are stored comma separated.
+type WebhookEvents []string
+
+func (e WebhookEvents) Value() (driver.Value, error) {
+	return strings.Join(e, ","), nil
+}
+
+func (e *WebhookEvents) Scan(src interface{}) error {
+	var v string
+	switch src := src.(type) {
+	case string:
+		v = src
+	case []byte:
+		v = string(src)
+	case nil:
+	default:
+		return fmt.Errorf("unsupported webhook events type %T", src)
+	}
+
+	*e = nil
+	if v != "" {
+		*e = strings.Split(v, ",")
+	}
+
+	return nil
+}
+
+// Has reports whether the event is in the list.
+func (e WebhookEvents) Has(event string) bool {
+	for _, v := range e {
+		if v == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Webhook struct {
+	ID     int64         `db:"id"     json:"id"     readonly:"true"`
+	URL    string        `db:"url"    json:"url"    validate:"required,http_url,max=2048"`
+	Events WebhookEvents `db:"events" json:"events" validate:"required,min=1,dive,oneof=product.created product.updated product.deleted product.sent" swaggertype:"array,string"`
+	// Secret signs the deliveries, generated when empty and only returned when the webhook is added.
+	Secret    string `db:"secret"     json:"secret,omitempty" validate:"omitempty,min=16,max=255"`
+	LastUser  string `db:"last_user"  json:"last_user"        readonly:"true"`
+	CreatedAt string `db:"created_at" json:"created_at"       readonly:"true"`
+}
+
+// WebhookDelivery is one attempt to deliver an event to a webhook.
+type WebhookDelivery struct {
+	ID        int64 `db:"id"         json:"id"`
+	WebhookID int64 `db:"webhook_id" json:"webhook_id"`
+	// DeliveryID is same for the attempts of an event, sent in the X-Webhook-Delivery header.
+	DeliveryID string `db:"delivery_id" json:"delivery_id"`
+	Event      string `db:"event"       json:"event"`
+	Attempt    int    `db:"attempt"     json:"attempt"`
+	// StatusCode of the response, 0 if no response received.
+	StatusCode int    `db:"status_code" json:"status_code"`
+	LatencyMS  int64  `db:"latency_ms"  json:"latency_ms"`
+	Error      string `db:"error"       json:"error,omitempty"`
+	// TraceID of the delivery span.
+	TraceID   string `db:"trace_id"   json:"trace_id"`
+	CreatedAt string `db:"created_at" json:"created_at"`
+}
+
+// WebhookPayload is the body of the deliveries.
+type WebhookPayload struct {
+	ID   string      `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
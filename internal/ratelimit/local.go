@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	// idleTimeout to remove buckets and counters of inactive keys.
+	idleTimeout = 10 * time.Minute
+	// sweepInterval to remove the refilled buckets, they behave like new ones.
+	sweepInterval = time.Minute
+	// maxEntries sweeps before the interval when there are more keys.
+	maxEntries = 100_000
+)
+
+type localEntry struct {
+	limiter  *rate.Limiter
+	inflight int
+	lastSeen time.Time
+}
+
+// local keeps token buckets and in-flight counters in memory.
+type local struct {
+	rate  rate.Limit
+	burst int
+
+	mutex     sync.Mutex
+	entries   map[string]*localEntry
+	lastSweep time.Time
+}
+
+func newLocal(r float64, burst int) *local {
+	return &local{
+		rate:      rate.Limit(r),
+		burst:     burst,
+		entries:   make(map[string]*localEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// entry returns the key's entry, caller must hold the mutex.
+func (l *local) entry(key string, now time.Time) *localEntry {
+	sinceSweep := now.Sub(l.lastSweep)
+	if sinceSweep > sweepInterval || (len(l.entries) >= maxEntries && sinceSweep > time.Second) {
+		for k, e := range l.entries {
+			if e.inflight > 0 {
+				continue
+			}
+
+			if e.limiter.TokensAt(now) >= float64(l.burst) || now.Sub(e.lastSeen) > idleTimeout {
+				delete(l.entries, k)
+			}
+		}
+
+		l.lastSweep = now
+	}
+
+	e, ok := l.entries[key]
+	if !ok {
+		e = &localEntry{limiter: rate.NewLimiter(l.rate, l.burst)}
+		l.entries[key] = e
+	}
+
+	e.lastSeen = now
+
+	return e
+}
+
+// Allow takes a token, returns wait duration for the next token when not allowed.
+func (l *local) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	reservation := l.entry(key, now).limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return false, time.Second
+	}
+
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+
+		return false, delay
+	}
+
+	return true, 0
+}
+
+// Wait returns the duration until the bucket of the key has a token without taking it, zero when it has.
+func (l *local) Wait(key string) time.Duration {
+	now := time.Now()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return 0
+	}
+
+	tokens := e.limiter.TokensAt(now)
+	if tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration((1 - tokens) / float64(l.rate) * float64(time.Second))
+}
+
+// Acquire increases in-flight requests of the key if it is under the limit.
+func (l *local) Acquire(key string, limit int) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	e := l.entry(key, time.Now())
+	if e.inflight >= limit {
+		return false
+	}
+
+	e.inflight++
+
+	return true
+}
+
+func (l *local) Release(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if e, ok := l.entries[key]; ok && e.inflight > 0 {
+		e.inflight--
+	}
+}
@@ -0,0 +1,349 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/auth"
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/telemetry"
+)
+
+const (
+	KeyByIP     = "ip"
+	KeyByAPIKey = "api_key"
+	KeyByHeader = "header"
+
+	reasonRate         = "rate"
+	reasonConcurrency  = "concurrency"
+	reasonAuthFailures = "auth_failures"
+)
+
+type rule struct {
+	cfg    config.RateLimitRule
+	name   string
+	local  *local
+	shared *shared
+}
+
+// failures are the buckets of the failed authentications per client IP.
+type failures struct {
+	cfg   config.AuthFailures
+	local *local
+}
+
+// Limiter applies the first matching rule of the route to the request.
+// It runs after the authentication, api_key rules use the authenticated subject.
+// Failed authentications are limited per client IP before the authentication.
+type Limiter struct {
+	rules       atomic.Pointer[[]*rule]
+	failures    atomic.Pointer[failures]
+	redisClient *redis.Client
+}
+
+// New creates limiter, redisClient is optional to share the buckets between replicas.
+func New(cfg config.RateLimit, redisClient *redis.Client) (*Limiter, error) {
+	l := &Limiter{
+		redisClient: redisClient,
+	}
+
+	if err := l.Update(cfg); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Update replaces the rules, buckets of unchanged rules are kept.
+// Shared setting is not changed, it depends on the redis client given in New.
+func (l *Limiter) Update(cfg config.RateLimit) error {
+	var current []*rule
+	if rules := l.rules.Load(); rules != nil {
+		current = *rules
+	}
+
+	rules := make([]*rule, 0, len(cfg.Rules))
+
+	for i, ruleCfg := range cfg.Rules {
+		if ruleCfg.KeyBy == "" {
+			ruleCfg.KeyBy = KeyByIP
+		}
+
+		switch ruleCfg.KeyBy {
+		case KeyByIP, KeyByAPIKey:
+		case KeyByHeader:
+			if ruleCfg.Header == "" {
+				return fmt.Errorf("rate limit rule [%d] header is required", i)
+			}
+		default:
+			return fmt.Errorf("rate limit rule [%d] unknown key_by [%s]", i, ruleCfg.KeyBy)
+		}
+
+		if ruleCfg.Burst <= 0 {
+			ruleCfg.Burst = 1
+		}
+
+		if r := findRule(current, ruleCfg); r != nil {
+			rules = append(rules, r)
+
+			continue
+		}
+
+		r := &rule{
+			cfg:   ruleCfg,
+			name:  ruleCfg.Route,
+			local: newLocal(ruleCfg.Rate, ruleCfg.Burst),
+		}
+
+		if r.name == "" {
+			r.name = "*"
+		}
+
+		if l.redisClient != nil && ruleCfg.Rate > 0 {
+			r.shared = &shared{
+				client: l.redisClient,
+				prefix: config.ServiceName + ":ratelimit:" + r.name + ":",
+				rate:   ruleCfg.Rate,
+				burst:  ruleCfg.Burst,
+			}
+		}
+
+		rules = append(rules, r)
+	}
+
+	l.rules.Store(&rules)
+
+	switch current := l.failures.Load(); {
+	case cfg.AuthFailures.Rate <= 0:
+		l.failures.Store(nil)
+	case current == nil || current.cfg != cfg.AuthFailures:
+		l.failures.Store(&failures{
+			cfg:   cfg.AuthFailures,
+			local: newLocal(cfg.AuthFailures.Rate, max(cfg.AuthFailures.Burst, 1)),
+		})
+	}
+
+	return nil
+}
+
+func findRule(rules []*rule, cfg config.RateLimitRule) *rule {
+	for _, r := range rules {
+		if r.cfg == cfg {
+			return r
+		}
+	}
+
+	return nil
+}
+
+func (l *Limiter) match(path string) *rule {
+	for _, r := range *l.rules.Load() {
+		if strings.HasPrefix(path, r.cfg.Route) {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// SameRule reports whether the routes use the same rule, a call inside the other one shouldn't take it twice.
+func (l *Limiter) SameRule(route, other string) bool {
+	return l.match(route) == l.match(other)
+}
+
+// Request is the client of the limited call.
+type Request struct {
+	// Route is the path of the call like "/api/v1/products", rules match its prefix.
+	Route string
+	IP    string
+	// Header reads the request headers, nil has no headers.
+	Header func(key string) string
+}
+
+func (l *Limiter) key(ctx context.Context, req Request, r *rule) string {
+	var value string
+
+	switch r.cfg.KeyBy {
+	case KeyByAPIKey:
+		// the header is not used, every new value would get a new bucket
+		if p := auth.PrincipalFromContext(ctx); p != nil {
+			value = p.Subject
+		}
+	case KeyByHeader:
+		if req.Header != nil {
+			value = req.Header(r.cfg.Header)
+		}
+	}
+
+	if value == "" {
+		return KeyByIP + ":" + req.IP
+	}
+
+	// not keep credentials in memory and redis keys
+	sum := sha256.Sum256([]byte(value))
+
+	return r.cfg.KeyBy + ":" + hex.EncodeToString(sum[:8])
+}
+
+func (r *rule) allow(ctx context.Context, key string) (bool, time.Duration) {
+	if r.shared != nil {
+		allowed, wait, err := r.shared.Allow(ctx, key)
+		if err == nil {
+			return allowed, wait
+		}
+
+		if !errors.Is(err, errSharedUnavailable) {
+			log.Ctx(ctx).Warn().Err(err).Str("rule", r.name).Msg("shared rate limit failed, using local")
+		}
+	}
+
+	return r.local.Allow(key)
+}
+
+// Middleware returns 429 with Retry-After header when the limit exceeded.
+func (l *Limiter) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			release, wait, err := l.Acquire(c.Request().Context(), Request{
+				Route:  c.Path(),
+				IP:     c.RealIP(),
+				Header: c.Request().Header.Get,
+			})
+			if err != nil {
+				c.Response().Header().Set("Retry-After", strconv.Itoa(RetryAfter(wait)))
+
+				return err
+			}
+
+			release = sync.OnceFunc(release)
+			defer release()
+
+			c.SetRequest(c.Request().WithContext(context.WithValue(c.Request().Context(), releaseKey{}, release)))
+
+			return next(c)
+		}
+	}
+}
+
+// AuthMiddleware rejects the clients with too many failed authentications, it runs before the authentication.
+// Requests failing with unauthorized take a token from the bucket of the client IP.
+func (l *Limiter) AuthMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ip := c.RealIP()
+
+			if wait, err := l.CheckFailures(c.Request().Context(), ip); err != nil {
+				c.Response().Header().Set("Retry-After", strconv.Itoa(RetryAfter(wait)))
+
+				return err
+			}
+
+			err := next(c)
+			if errors.Is(err, model.ErrUnauthorized) {
+				l.Failed(ip)
+			}
+
+			return err
+		}
+	}
+}
+
+// CheckFailures returns the too many requests error when the failures of the IP used its bucket.
+func (l *Limiter) CheckFailures(ctx context.Context, ip string) (time.Duration, error) {
+	f := l.failures.Load()
+	if f == nil {
+		return 0, nil
+	}
+
+	if wait := f.local.Wait(KeyByIP + ":" + ip); wait > 0 {
+		return wait, l.throttled(ctx, reasonAuthFailures, reasonAuthFailures)
+	}
+
+	return 0, nil
+}
+
+// Failed takes a token of the failed authentication from the bucket of the IP.
+func (l *Limiter) Failed(ip string) {
+	if f := l.failures.Load(); f != nil {
+		f.local.Allow(KeyByIP + ":" + ip)
+	}
+}
+
+type releaseKey struct{}
+
+// Release gives the concurrency of the request back before it ends, long-lived streams use it.
+func Release(ctx context.Context) {
+	if release, ok := ctx.Value(releaseKey{}).(func()); ok {
+		release()
+	}
+}
+
+// Acquire applies the first rule matching the route, release should be called when the call ends.
+// Throttled calls get the wait duration and the too many requests error.
+func (l *Limiter) Acquire(ctx context.Context, req Request) (release func(), wait time.Duration, err error) {
+	release = func() {}
+
+	r := l.match(req.Route)
+	if r == nil {
+		return release, 0, nil
+	}
+
+	key := l.key(ctx, req, r)
+
+	if r.cfg.Rate > 0 {
+		if allowed, wait := r.allow(ctx, key); !allowed {
+			return nil, wait, l.throttled(ctx, r.name, reasonRate)
+		}
+	}
+
+	if r.cfg.Concurrency > 0 {
+		if !r.local.Acquire(key, r.cfg.Concurrency) {
+			return nil, time.Second, l.throttled(ctx, r.name, reasonConcurrency)
+		}
+
+		release = func() { r.local.Release(key) }
+	}
+
+	return release, 0, nil
+}
+
+func (l *Limiter) throttled(ctx context.Context, name, reason string) error {
+	attrs := []attribute.KeyValue{
+		attribute.String("ratelimit.rule", name),
+		attribute.String("ratelimit.reason", reason),
+	}
+
+	trace.SpanFromContext(ctx).AddEvent("throttled", trace.WithAttributes(attrs...))
+
+	telemetry.GlobalMeter.ThrottledCounter.Add(ctx, 1,
+		metric.WithAttributes(telemetry.GlobalAttr...),
+		metric.WithAttributes(attrs...),
+	)
+
+	return &model.Error{
+		Kind:   model.ErrTooManyRequests,
+		Detail: reason + " limit exceeded",
+	}
+}
+
+// RetryAfter is the wait in seconds for the Retry-After header, at least one.
+func RetryAfter(wait time.Duration) int {
+	return max(int(math.Ceil(wait.Seconds())), 1)
+}
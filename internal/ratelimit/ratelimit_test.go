@@ -0,0 +1,235 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/worldline-go/telemetry_example/internal/auth"
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/telemetry"
+)
+
+func TestMain(m *testing.M) {
+	if err := telemetry.SetGlobalMeter(); err != nil {
+		panic(err)
+	}
+
+	m.Run()
+}
+
+func TestLocalRefill(t *testing.T) {
+	l := newLocal(100, 2)
+
+	for i := range 2 {
+		if allowed, _ := l.Allow("ip:a"); !allowed {
+			t.Fatalf("token %d of the burst is not allowed", i)
+		}
+	}
+
+	allowed, wait := l.Allow("ip:a")
+	if allowed || wait <= 0 || wait > 10*time.Millisecond {
+		t.Fatalf("empty bucket: want wait up to 10ms, got %t, %s", allowed, wait)
+	}
+
+	if allowed, _ := l.Allow("ip:b"); !allowed {
+		t.Fatal("other key shares the bucket")
+	}
+
+	time.Sleep(wait + 5*time.Millisecond)
+
+	if allowed, _ := l.Allow("ip:a"); !allowed {
+		t.Fatal("bucket is not refilled")
+	}
+}
+
+func TestLocalEviction(t *testing.T) {
+	defer func(interval time.Duration) { sweepInterval = interval }(sweepInterval)
+	sweepInterval = 0
+
+	l := newLocal(1000, 1)
+
+	l.Allow("ip:refilled")
+	if !l.Acquire("ip:inflight", 1) {
+		t.Fatal("concurrency is not acquired")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	// sweeps with the next entry
+	l.Allow("ip:new")
+
+	tests := []struct {
+		key  string
+		kept bool
+	}{
+		{key: "ip:refilled", kept: false},
+		{key: "ip:inflight", kept: true},
+		{key: "ip:new", kept: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if _, ok := l.entries[tt.key]; ok != tt.kept {
+				t.Fatalf("want kept %t, got %t", tt.kept, ok)
+			}
+		})
+	}
+}
+
+func TestLocalConcurrency(t *testing.T) {
+	l := newLocal(0, 1)
+
+	if !l.Acquire("ip:a", 2) || !l.Acquire("ip:a", 2) {
+		t.Fatal("concurrency under the limit is not acquired")
+	}
+
+	if l.Acquire("ip:a", 2) {
+		t.Fatal("concurrency over the limit is acquired")
+	}
+
+	l.Release("ip:a")
+
+	if !l.Acquire("ip:a", 2) {
+		t.Fatal("released concurrency is not acquired")
+	}
+}
+
+func TestLimiterKey(t *testing.T) {
+	limiter, err := New(config.RateLimit{Rules: []config.RateLimitRule{
+		{Route: "/api/v1/products", KeyBy: KeyByAPIKey, Rate: 0.001, Burst: 1},
+		{Route: "/api/v1/count", KeyBy: KeyByHeader, Header: "X-Client", Rate: 0.001, Burst: 1},
+		{Rate: 0.001, Burst: 1},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject := func(name string) context.Context {
+		return auth.WithPrincipal(context.Background(), &auth.Principal{Subject: name})
+	}
+
+	header := func(value string) func(string) string {
+		return func(string) string { return value }
+	}
+
+	// every step takes the only token of its bucket, the same bucket again is throttled
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		req       Request
+		throttled bool
+	}{
+		{name: "subject", ctx: subject("svc-1"), req: Request{Route: "/api/v1/products", IP: "192.0.2.1"}},
+		{name: "same subject other ip", ctx: subject("svc-1"), req: Request{Route: "/api/v1/products", IP: "192.0.2.2"}, throttled: true},
+		{name: "other subject same ip", ctx: subject("svc-2"), req: Request{Route: "/api/v1/products", IP: "192.0.2.1"}},
+		{name: "without subject uses ip", ctx: context.Background(), req: Request{Route: "/api/v1/products", IP: "192.0.2.3"}},
+		{name: "without subject same ip", ctx: context.Background(), req: Request{Route: "/api/v1/products", IP: "192.0.2.3"}, throttled: true},
+		{name: "header", ctx: context.Background(), req: Request{Route: "/api/v1/count", IP: "192.0.2.1", Header: header("a")}},
+		{name: "same header other ip", ctx: context.Background(), req: Request{Route: "/api/v1/count", IP: "192.0.2.2", Header: header("a")}, throttled: true},
+		{name: "default rule", ctx: context.Background(), req: Request{Route: "/api/v1/call", IP: "192.0.2.1"}},
+		{name: "default rule same ip", ctx: context.Background(), req: Request{Route: "/api/v1/call", IP: "192.0.2.1"}, throttled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release, wait, err := limiter.Acquire(tt.ctx, tt.req)
+			if tt.throttled {
+				if !errors.Is(err, model.ErrTooManyRequests) || wait <= 0 {
+					t.Fatalf("want too many requests with wait, got %v, %s", err, wait)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("want allowed, got %v", err)
+			}
+
+			release()
+		})
+	}
+}
+
+func TestLimiterUpdateKeepsBuckets(t *testing.T) {
+	rule := config.RateLimitRule{Route: "/api/v1/products", Rate: 0.001, Burst: 1}
+
+	limiter, err := New(config.RateLimit{Rules: []config.RateLimitRule{rule}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := Request{Route: "/api/v1/products", IP: "192.0.2.1"}
+
+	if _, _, err := limiter.Acquire(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := limiter.Update(config.RateLimit{Rules: []config.RateLimitRule{{Route: "/api/v1/count", Rate: 1}, rule}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := limiter.Acquire(context.Background(), req); !errors.Is(err, model.ErrTooManyRequests) {
+		t.Fatalf("unchanged rule got a new bucket, got %v", err)
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	limiter, err := New(config.RateLimit{AuthFailures: config.AuthFailures{Rate: 0.001, Burst: 2}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.HTTPErrorHandler = func(err error, c echo.Context) {
+		switch {
+		case errors.Is(err, model.ErrUnauthorized):
+			_ = c.NoContent(http.StatusUnauthorized)
+		case errors.Is(err, model.ErrTooManyRequests):
+			_ = c.NoContent(http.StatusTooManyRequests)
+		default:
+			_ = c.NoContent(http.StatusInternalServerError)
+		}
+	}
+	e.GET("/", func(c echo.Context) error {
+		if c.Request().Header.Get("X-API-Key") != "valid" {
+			return &model.Error{Kind: model.ErrUnauthorized, Detail: "authentication required"}
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	}, limiter.AuthMiddleware())
+
+	tests := []struct {
+		name       string
+		ip         string
+		key        string
+		wantStatus int
+	}{
+		{name: "valid key", ip: "192.0.2.1", key: "valid", wantStatus: http.StatusNoContent},
+		{name: "first failure", ip: "192.0.2.1", key: "guess-1", wantStatus: http.StatusUnauthorized},
+		{name: "second failure", ip: "192.0.2.1", key: "guess-2", wantStatus: http.StatusUnauthorized},
+		{name: "blocked before authentication", ip: "192.0.2.1", key: "valid", wantStatus: http.StatusTooManyRequests},
+		{name: "other ip", ip: "192.0.2.2", key: "guess-1", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.ip + ":4000"
+			req.Header.Set("X-API-Key", tt.key)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript refills the bucket by elapsed time and takes one token.
+// Returns allowed flag and wait time in milliseconds for the next token.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local data = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(data[1]) or burst
+local ts = tonumber(data[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+
+return {allowed, wait}
+`)
+
+// sharedRetry is the wait duration to use redis again after a failure.
+var sharedRetry = 5 * time.Second
+
+var errSharedUnavailable = errors.New("shared rate limit unavailable")
+
+type shared struct {
+	client *redis.Client
+	prefix string
+	rate   float64
+	burst  int
+
+	// retryAt unix nano time to use redis again
+	retryAt atomic.Int64
+}
+
+func (s *shared) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	if time.Now().UnixNano() < s.retryAt.Load() {
+		return false, 0, errSharedUnavailable
+	}
+
+	result, err := tokenBucketScript.Run(ctx, s.client,
+		[]string{s.prefix + key},
+		s.rate, s.burst, time.Now().UnixMilli(),
+	).Int64Slice()
+	if err != nil {
+		s.retryAt.Store(time.Now().Add(sharedRetry).UnixNano())
+
+		return false, 0, err //nolint:wrapcheck // no need
+	}
+
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
@@ -0,0 +1,184 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/worldline-go/igconfig/loader"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/logging"
+	"github.com/worldline-go/telemetry_example/internal/telemetry"
+)
+
+const (
+	SourceSignal = "signal"
+	SourceFile   = "file"
+	SourceConsul = "consul"
+	SourceSecret = "secret"
+)
+
+// Apply changes a running component with the new configuration.
+// Only settings that are safe to change at runtime should be used.
+type Apply struct {
+	Name string
+	Func func(ctx context.Context, cfg *config.Config) error
+}
+
+// Reloader reads the configuration again and calls the applies.
+type Reloader struct {
+	Applies []Apply
+
+	mutex sync.Mutex
+}
+
+// Reload loads the configuration from all loaders, every apply is called even if one fails.
+func (r *Reloader) Reload(ctx context.Context, source string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ctx, span := otel.Tracer("").Start(ctx, "config_reload", trace.WithSpanKind(trace.SpanKindInternal))
+	defer span.End()
+
+	span.SetAttributes(attribute.String("config.source", source))
+
+	result := "success"
+
+	err := r.reload(ctx)
+	if err != nil {
+		result = "failure"
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	telemetry.GlobalMeter.ConfigReloadCounter.Add(ctx, 1,
+		metric.WithAttributes(telemetry.GlobalAttr...),
+		metric.WithAttributes(
+			attribute.String("source", source),
+			attribute.String("result", result),
+		),
+	)
+
+	logger := logging.Package("config")
+	if err != nil {
+		logger.Error().Err(err).Str("source", source).Msg("failed to reload config")
+	} else {
+		logger.Info().Str("source", source).Msg("reloaded config")
+	}
+
+	return err
+}
+
+func (r *Reloader) reload(ctx context.Context) error {
+	cfg, err := config.LoadConfig(ctx)
+	if err != nil {
+		return err //nolint:wrapcheck // no need
+	}
+
+	span := trace.SpanFromContext(ctx)
+
+	var errs []error
+	for _, apply := range r.Applies {
+		if err := apply.Func(ctx, cfg); err != nil {
+			span.AddEvent("apply_failed", trace.WithAttributes(
+				attribute.String("config.apply", apply.Name),
+				attribute.String("error", err.Error()),
+			))
+
+			errs = append(errs, fmt.Errorf("failed to apply %s; %w", apply.Name, err))
+
+			continue
+		}
+
+		span.AddEvent("applied", trace.WithAttributes(attribute.String("config.apply", apply.Name)))
+	}
+
+	return errors.Join(errs...)
+}
+
+// WatchSignal reloads on SIGHUP.
+func (r *Reloader) WatchSignal(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			_ = r.Reload(ctx, SourceSignal)
+		}
+	}
+}
+
+// WatchFile reloads when modification time or size of the file changes.
+func (r *Reloader) WatchFile(ctx context.Context, source, file string, interval time.Duration) {
+	if file == "" || interval <= 0 {
+		return
+	}
+
+	last, _ := os.Stat(file)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(file)
+			if err != nil {
+				// file could be in the middle of a replace
+				continue
+			}
+
+			if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+				continue
+			}
+
+			last = info
+
+			_ = r.Reload(ctx, source)
+		}
+	}
+}
+
+// WatchConsul reloads when the service key in consul changes.
+// It is no-op if consul is not configured with CONSUL_HTTP_ADDR.
+func (r *Reloader) WatchConsul(ctx context.Context) {
+	values, err := loader.Consul{}.DynamicValue(ctx, config.ServiceName)
+	if err != nil {
+		if !errors.Is(err, loader.ErrNoClient) {
+			logging.Package("config").Error().Err(err).Msg("failed to watch consul")
+		}
+
+		return
+	}
+
+	// first value is the current one which is already loaded
+	first := true
+
+	for range values {
+		if first {
+			first = false
+
+			continue
+		}
+
+		_ = r.Reload(ctx, SourceConsul)
+	}
+}
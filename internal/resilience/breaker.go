@@ -0,0 +1,210 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/telemetry"
+)
+
+// ErrOpen is returned when the circuit doesn't allow the request.
+var ErrOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateHalfOpen
+	StateOpen
+)
+
+// Result of the allowed request reported to the breaker.
+type Result int
+
+const (
+	ResultSuccess Result = iota
+	ResultFailure
+	// ResultIgnored is neither success nor failure like a canceled request, it frees the half-open probe.
+	ResultIgnored
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	}
+
+	return "unknown"
+}
+
+// Breaker opens the circuit after failures in a row and probes with half-open requests after the open timeout.
+type Breaker struct {
+	name string
+
+	mutex    sync.Mutex
+	cfg      config.Breaker
+	state    State
+	failures int
+	openedAt time.Time
+	// generation changes with the state to ignore the results of the old requests
+	generation uint64
+	probes     int
+	successes  int
+}
+
+func NewBreaker(name string, cfg config.Breaker) *Breaker {
+	b := &Breaker{name: name, cfg: cfg}
+	b.record(context.Background())
+
+	return b
+}
+
+// SetConfig changes the thresholds, state is kept.
+func (b *Breaker) SetConfig(cfg config.Breaker) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.cfg = cfg
+}
+
+func (b *Breaker) State() State {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.state
+}
+
+// Allow returns done function to report the result of the allowed request.
+func (b *Breaker) Allow(ctx context.Context) (func(result Result), error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.cfg.Failures <= 0 {
+		return func(Result) {}, nil
+	}
+
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.cfg.OpenTimeout {
+			return nil, b.reject(ctx)
+		}
+
+		b.setState(ctx, StateHalfOpen)
+	case StateHalfOpen:
+		if b.probes >= b.cfg.HalfOpenRequests {
+			return nil, b.reject(ctx)
+		}
+	}
+
+	if b.state == StateHalfOpen {
+		b.probes++
+	}
+
+	generation := b.generation
+
+	return func(result Result) {
+		b.done(ctx, generation, result)
+	}, nil
+}
+
+func (b *Breaker) reject(ctx context.Context) error {
+	trace.SpanFromContext(ctx).AddEvent("circuit_breaker.rejected", trace.WithAttributes(
+		attribute.String("circuit_breaker.name", b.name),
+		attribute.String("circuit_breaker.state", b.state.String()),
+	))
+
+	return ErrOpen
+}
+
+func (b *Breaker) done(ctx context.Context, generation uint64, result Result) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	if result == ResultIgnored {
+		if b.state == StateHalfOpen && b.probes > 0 {
+			b.probes--
+		}
+
+		return
+	}
+
+	success := result == ResultSuccess
+
+	switch b.state {
+	case StateClosed:
+		if success {
+			b.failures = 0
+
+			return
+		}
+
+		b.failures++
+		if b.failures >= b.cfg.Failures {
+			b.setState(ctx, StateOpen)
+		}
+	case StateHalfOpen:
+		if !success {
+			b.setState(ctx, StateOpen)
+
+			return
+		}
+
+		b.successes++
+		if b.successes >= b.cfg.HalfOpenRequests {
+			b.setState(ctx, StateClosed)
+		}
+	}
+}
+
+func (b *Breaker) setState(ctx context.Context, state State) {
+	from := b.state
+
+	b.state = state
+	b.generation++
+	b.failures = 0
+	b.probes = 0
+	b.successes = 0
+
+	if state == StateOpen {
+		b.openedAt = time.Now()
+	}
+
+	trace.SpanFromContext(ctx).AddEvent("circuit_breaker.state_change", trace.WithAttributes(
+		attribute.String("circuit_breaker.name", b.name),
+		attribute.String("circuit_breaker.from", from.String()),
+		attribute.String("circuit_breaker.to", state.String()),
+	))
+
+	log.Ctx(ctx).Warn().Str("service", b.name).
+		Str("from", from.String()).Str("to", state.String()).
+		Msg("circuit breaker state changed")
+
+	b.record(ctx)
+}
+
+func (b *Breaker) record(ctx context.Context) {
+	if telemetry.GlobalMeter == nil {
+		return
+	}
+
+	telemetry.GlobalMeter.BreakerStateGauge.Record(ctx, int64(b.state),
+		metric.WithAttributes(telemetry.GlobalAttr...),
+		metric.WithAttributes(attribute.String("service", b.name)),
+	)
+}
@@ -0,0 +1,159 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+)
+
+func TestBreaker(t *testing.T) {
+	ctx := context.Background()
+	openTimeout := 20 * time.Millisecond
+
+	type step struct {
+		// wait before the step, open timeout passes with it
+		wait time.Duration
+		// results of the requests allowed in the step, rejected requests have none
+		results   []Result
+		rejected  int
+		wantState State
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "success resets failures",
+			steps: []step{
+				{results: []Result{ResultFailure, ResultFailure, ResultSuccess, ResultFailure, ResultFailure}, wantState: StateClosed},
+			},
+		},
+		{
+			name: "open after failures in a row",
+			steps: []step{
+				{results: []Result{ResultFailure, ResultFailure, ResultFailure}, wantState: StateOpen},
+				{rejected: 1, wantState: StateOpen},
+			},
+		},
+		{
+			name: "half-open probes close the circuit",
+			steps: []step{
+				{results: []Result{ResultFailure, ResultFailure, ResultFailure}, wantState: StateOpen},
+				{wait: openTimeout, results: []Result{ResultSuccess, ResultSuccess}, wantState: StateClosed},
+			},
+		},
+		{
+			name: "failed probe opens the circuit again",
+			steps: []step{
+				{results: []Result{ResultFailure, ResultFailure, ResultFailure}, wantState: StateOpen},
+				{wait: openTimeout, results: []Result{ResultSuccess, ResultFailure}, wantState: StateOpen},
+				{rejected: 1, wantState: StateOpen},
+			},
+		},
+		{
+			name: "ignored probe frees the slot",
+			steps: []step{
+				{results: []Result{ResultFailure, ResultFailure, ResultFailure}, wantState: StateOpen},
+				{wait: openTimeout, results: []Result{ResultIgnored, ResultIgnored, ResultSuccess, ResultSuccess}, wantState: StateClosed},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBreaker("service", config.Breaker{Failures: 3, OpenTimeout: openTimeout, HalfOpenRequests: 2})
+
+			for i, s := range tt.steps {
+				time.Sleep(s.wait)
+
+				for _, result := range s.results {
+					done, err := b.Allow(ctx)
+					if err != nil {
+						t.Fatalf("step %d: request rejected in %s", i, b.State())
+					}
+
+					done(result)
+				}
+
+				for range s.rejected {
+					if _, err := b.Allow(ctx); !errors.Is(err, ErrOpen) {
+						t.Fatalf("step %d: want rejected, got %v", i, err)
+					}
+				}
+
+				if state := b.State(); state != s.wantState {
+					t.Fatalf("step %d: want state %s, got %s", i, s.wantState, state)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerHalfOpenLimit(t *testing.T) {
+	ctx := context.Background()
+
+	b := NewBreaker("service", config.Breaker{Failures: 1, OpenTimeout: time.Millisecond, HalfOpenRequests: 2})
+
+	done, _ := b.Allow(ctx)
+	done(ResultFailure)
+
+	time.Sleep(2 * time.Millisecond)
+
+	probe1, err := b.Allow(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	probe2, err := b.Allow(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Allow(ctx); !errors.Is(err, ErrOpen) {
+		t.Fatalf("probe over the half-open requests: want rejected, got %v", err)
+	}
+
+	probe1(ResultSuccess)
+	probe2(ResultSuccess)
+
+	if b.State() != StateClosed {
+		t.Fatalf("want closed, got %s", b.State())
+	}
+}
+
+func TestBreakerGeneration(t *testing.T) {
+	ctx := context.Background()
+
+	b := NewBreaker("service", config.Breaker{Failures: 2, OpenTimeout: time.Millisecond, HalfOpenRequests: 1})
+
+	// started while closed, it ends after the circuit opened and closed again
+	slow, _ := b.Allow(ctx)
+
+	for range 2 {
+		done, _ := b.Allow(ctx)
+		done(ResultFailure)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+
+	probe, err := b.Allow(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	probe(ResultSuccess)
+
+	failure, _ := b.Allow(ctx)
+	failure(ResultFailure)
+
+	// the old failure is not counted, the circuit needs two failures of the new generation
+	slow(ResultFailure)
+
+	if b.State() != StateClosed {
+		t.Fatalf("result of an old generation changed the state to %s", b.State())
+	}
+}
@@ -0,0 +1,198 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/worldline-go/klient"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/telemetry"
+)
+
+// Caller runs outbound calls with the call policy of the service.
+type Caller struct {
+	cfg atomic.Pointer[config.Config]
+
+	mutex    sync.Mutex
+	breakers map[string]*Breaker
+}
+
+func NewCaller(cfg *config.Config) *Caller {
+	c := &Caller{breakers: make(map[string]*Breaker)}
+	c.cfg.Store(cfg)
+
+	return c
+}
+
+// Update changes the policies, breaker states are kept.
+func (c *Caller) Update(cfg *config.Config) {
+	c.cfg.Store(cfg)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for name, b := range c.breakers {
+		b.SetConfig(cfg.Policy(name).Breaker)
+	}
+}
+
+// Breaker returns the circuit breaker of the service.
+func (c *Caller) Breaker(service string) *Breaker {
+	return c.breaker(service, c.cfg.Load().Policy(service).Breaker)
+}
+
+func (c *Caller) breaker(service string, cfg config.Breaker) *Breaker {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	b, ok := c.breakers[service]
+	if !ok {
+		b = NewBreaker(service, cfg)
+		c.breakers[service] = b
+	}
+
+	return b
+}
+
+// Do calls fn with timeout of each attempt, retries the retryable failures of the idempotent calls with jitter
+// and rejects the call without trying when the circuit of the service is open.
+// Calls are idempotent with GET, HEAD, PUT, DELETE or an Idempotency-Key, others could be processed twice.
+// Canceled calls don't change the breaker.
+func (c *Caller) Do(ctx context.Context, service string, idempotent bool, fn func(ctx context.Context) error) error {
+	policy := c.cfg.Load().Policy(service)
+	b := c.breaker(service, policy.Breaker)
+
+	attempts := 1
+	if idempotent {
+		attempts += max(policy.Retry.Max, 0)
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			wait := backoff(policy.Retry, attempt)
+
+			trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+				attribute.String("service", service),
+				attribute.Int("retry.attempt", attempt),
+				attribute.String("retry.wait", wait.String()),
+				attribute.String("retry.error", err.Error()),
+			))
+
+			telemetry.GlobalMeter.CallRetryCounter.Add(ctx, 1,
+				metric.WithAttributes(telemetry.GlobalAttr...),
+				metric.WithAttributes(attribute.String("service", service)),
+			)
+
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(wait):
+			}
+		}
+
+		done, bErr := b.Allow(ctx)
+		if bErr != nil {
+			// retry is rejected, keep the upstream error
+			if err != nil {
+				return err
+			}
+
+			return &model.Error{
+				Kind:   model.ErrUnavailable,
+				Detail: "service [" + service + "] circuit is open",
+				Cause:  bErr,
+			}
+		}
+
+		err = call(ctx, policy.Timeout, fn)
+
+		// the caller gave up, the result doesn't tell the health of the service
+		if ctx.Err() != nil {
+			done(ResultIgnored)
+
+			return err
+		}
+
+		result, retryable := classify(err)
+		done(result)
+
+		if err == nil || !retryable {
+			return err
+		}
+	}
+
+	return err
+}
+
+func call(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return fn(ctx)
+}
+
+// classify returns the result for the breaker and retryable for the retry.
+// Client errors of the upstream are not failures, canceled calls are ignored.
+func classify(err error) (Result, bool) {
+	if err == nil {
+		return ResultSuccess, false
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ResultIgnored, false
+	}
+
+	var responseErr *klient.ResponseError
+	if errors.As(err, &responseErr) {
+		switch responseErr.StatusCode {
+		case http.StatusTooManyRequests:
+			return ResultSuccess, true
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return ResultFailure, true
+		}
+
+		if responseErr.StatusCode >= http.StatusInternalServerError {
+			return ResultFailure, false
+		}
+
+		return ResultSuccess, false
+	}
+
+	var netErr net.Error
+	var urlErr *url.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) || errors.As(err, &urlErr) {
+		return ResultFailure, true
+	}
+
+	return ResultFailure, false
+}
+
+// backoff is exponential with full jitter between WaitMin and the exponential cap.
+func backoff(cfg config.Retry, attempt int) time.Duration {
+	ceiling := cfg.WaitMin << (attempt - 1)
+	if ceiling > cfg.WaitMax || ceiling <= 0 {
+		ceiling = cfg.WaitMax
+	}
+
+	if ceiling <= cfg.WaitMin {
+		return cfg.WaitMin
+	}
+
+	return cfg.WaitMin + rand.N(ceiling-cfg.WaitMin) //nolint:gosec // jitter
+}
@@ -0,0 +1,199 @@
+package search
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/tenant"
+)
+
+const (
+	// similarityThreshold of the names like the pg_trgm default.
+	similarityThreshold = 0.3
+
+	nameWeight        = 1.0
+	descriptionWeight = 0.4
+)
+
+// memory keeps the indexed products of the replica per tenant, it is used without postgres.
+// Words match with the prefix instead of stemming, names also match with the trigram similarity.
+type memory struct {
+	mutex sync.RWMutex
+	// tenants holds the products by name, empty tenant is the main schema.
+	tenants map[string]map[string]model.Product
+}
+
+func newMemory() *memory {
+	return &memory{tenants: make(map[string]map[string]model.Product)}
+}
+
+func (m *memory) Index(ctx context.Context, product *model.Product) {
+	if product == nil || product.Name == "" {
+		return
+	}
+
+	id := tenant.FromContext(ctx)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	products, ok := m.tenants[id]
+	if !ok {
+		products = make(map[string]model.Product)
+		m.tenants[id] = products
+	}
+
+	products[product.Name] = *product
+}
+
+func (m *memory) Remove(ctx context.Context, name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.tenants[tenant.FromContext(ctx)], name)
+}
+
+func (m *memory) Backend() string {
+	return BackendMemory
+}
+
+func (m *memory) Search(ctx context.Context, text string, limit int) ([]model.SearchResult, error) {
+	terms := words(text)
+	textTrigrams := trigrams(text)
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var results []model.SearchResult
+	for _, product := range m.tenants[tenant.FromContext(ctx)] {
+		score := 0.0
+		if len(terms) > 0 {
+			score = (nameWeight*matches(terms, words(product.Name)) +
+				descriptionWeight*matches(terms, words(product.Description))) / float64(len(terms))
+		}
+
+		similar := similarity(textTrigrams, trigrams(product.Name))
+		if score == 0 && similar < similarityThreshold {
+			continue
+		}
+
+		results = append(results, model.SearchResult{
+			Product: product,
+			Rank:    score + similar,
+			Highlights: model.Highlights{
+				Name:        highlight(product.Name, terms),
+				Description: highlight(product.Description, terms),
+			},
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+
+		return results[i].Product.ID < results[j].Product.ID
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// matches returns the number of terms found in the words.
+func matches(terms, fields []string) float64 {
+	count := 0.0
+	for _, term := range terms {
+		for _, field := range fields {
+			if strings.HasPrefix(field, term) {
+				count++
+
+				break
+			}
+		}
+	}
+
+	return count
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// words returns the lower case words of the text.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !isWordRune(r)
+	})
+}
+
+// trigrams returns the trigrams of the words padded like pg_trgm.
+func trigrams(text string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, word := range words(text) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+
+	return set
+}
+
+// similarity is the shared trigrams divided by all trigrams.
+func similarity(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for t := range a {
+		if _, ok := b[t]; ok {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// highlight escapes the text for html and wraps the words starting with a term in <mark> tags.
+func highlight(text string, terms []string) string {
+	if len(terms) == 0 {
+		return html.EscapeString(text)
+	}
+
+	var b strings.Builder
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			b.WriteString(html.EscapeString(string(runes[i])))
+			i++
+
+			continue
+		}
+
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+
+		word := string(runes[i:j])
+		if matches(terms, []string{strings.ToLower(word)}) > 0 {
+			b.WriteString("<mark>" + html.EscapeString(word) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(word))
+		}
+
+		i = j
+	}
+
+	return b.String()
+}
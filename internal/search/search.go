@@ -0,0 +1,60 @@
+package search
+
+import (
+	"context"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/database/dbhandler"
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+const (
+	BackendPostgres = "postgres"
+	BackendMemory   = "memory"
+)
+
+// Searcher finds the products matching the text.
+type Searcher interface {
+	// Search returns at most limit products ordered by the rank.
+	Search(ctx context.Context, text string, limit int) ([]model.SearchResult, error)
+	// Index records the created or changed product of the context's tenant, backends reading the database ignore it.
+	Index(ctx context.Context, product *model.Product)
+	// Remove drops the deleted product of the context's tenant, backends reading the database ignore it.
+	Remove(ctx context.Context, name string)
+	// Backend returns the name of the backend.
+	Backend() string
+}
+
+// New creates the configured backend, empty backend is postgres when the database is enabled.
+func New(cfg config.Search, enableDatabase bool, db *dbhandler.Handler) Searcher {
+	backend := cfg.Backend
+	if backend == "" {
+		backend = BackendMemory
+		if enableDatabase {
+			backend = BackendPostgres
+		}
+	}
+
+	if backend == BackendPostgres {
+		return postgres{db: db}
+	}
+
+	return newMemory()
+}
+
+// postgres uses the full-text and trigram indexes of the products table.
+type postgres struct {
+	db *dbhandler.Handler
+}
+
+func (p postgres) Search(ctx context.Context, text string, limit int) ([]model.SearchResult, error) {
+	return p.db.SearchProducts(ctx, text, uint(limit)) //nolint:wrapcheck // no need
+}
+
+func (postgres) Index(context.Context, *model.Product) {}
+
+func (postgres) Remove(context.Context, string) {}
+
+func (postgres) Backend() string {
+	return BackendPostgres
+}
@@ -0,0 +1,37 @@
+package secret
+
+import (
+	"net/http"
+	"strings"
+)
+
+// SensitiveHeader reports whether the header carries credentials like Authorization or names ending with -Key and -Token.
+func SensitiveHeader(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+
+	switch name {
+	case "Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie":
+		return true
+	}
+
+	return strings.HasSuffix(name, "-Key") || strings.HasSuffix(name, "-Token")
+}
+
+// Headers returns a copy of the header to log, values of the sensitive headers and the extra names are masked.
+func Headers(header http.Header, extra ...string) http.Header {
+	masked := header.Clone()
+
+	for name := range masked {
+		if SensitiveHeader(name) {
+			masked[name] = []string{Mask}
+		}
+	}
+
+	for _, name := range extra {
+		if name = http.CanonicalHeaderKey(name); masked.Get(name) != "" {
+			masked[name] = []string{Mask}
+		}
+	}
+
+	return masked
+}
@@ -0,0 +1,40 @@
+package secret
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer token")
+	header.Set("X-API-Key", "key")
+	header.Set("X-Auth-Token", "token")
+	header.Set("X-Service-Credential", "credential")
+	header.Set("Traceparent", "00-trace")
+
+	masked := Headers(header, "x-service-credential")
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "Authorization", want: Mask},
+		{name: "X-Api-Key", want: Mask},
+		{name: "X-Auth-Token", want: Mask},
+		{name: "X-Service-Credential", want: Mask},
+		{name: "Traceparent", want: "00-trace"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := masked.Get(tt.name); got != tt.want {
+				t.Fatalf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if header.Get("Authorization") != "Bearer token" {
+		t.Fatal("original header changed")
+	}
+}
@@ -0,0 +1,89 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Mask replaces the secret values.
+const Mask = "***"
+
+var mask = []byte(Mask)
+
+// MinLength of the values to register, shorter values would mask unrelated text.
+var MinLength = 4
+
+var (
+	mutex sync.RWMutex
+	// values with their byte form, json escaped forms are also added
+	values = map[string][]byte{}
+)
+
+// Register adds value to redact from logs and spans.
+// Password of an url value is registered separately.
+func Register(value string) {
+	if len(value) < MinLength {
+		return
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	add(value)
+
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		if password, ok := u.User.Password(); ok && len(password) >= MinLength {
+			add(password)
+			add(url.QueryEscape(password))
+		}
+	}
+}
+
+func add(value string) {
+	values[value] = []byte(value)
+
+	if escaped, err := json.Marshal(value); err == nil {
+		escaped = escaped[1 : len(escaped)-1]
+		values[string(escaped)] = escaped
+	}
+}
+
+// Redact replaces registered values in s.
+func Redact(s string) string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	for value := range values {
+		s = strings.ReplaceAll(s, value, Mask)
+	}
+
+	return s
+}
+
+// Writer redacts registered values before writing to w.
+type Writer struct {
+	w io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	mutex.RLock()
+	redacted := p
+	for _, value := range values {
+		redacted = bytes.ReplaceAll(redacted, value, mask)
+	}
+	mutex.RUnlock()
+
+	if _, err := w.w.Write(redacted); err != nil {
+		return 0, err //nolint:wrapcheck // no need
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,202 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/worldline-go/igconfig/loader"
+)
+
+const (
+	SchemeFile  = "file://"
+	SchemeEnv   = "env://"
+	SchemeVault = "vault://"
+)
+
+// IsReference reports whether value is a secret reference.
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, SchemeFile) ||
+		strings.HasPrefix(value, SchemeEnv) ||
+		strings.HasPrefix(value, SchemeVault)
+}
+
+// Resolver resolves secret references, vault client is created on first use.
+type Resolver struct {
+	vault loader.Vaulter
+	// Files are the paths of resolved file references.
+	Files []string
+}
+
+// Resolve returns the secret of the reference, other values returned as is.
+//
+//	file:///run/secrets/db      content of the file without trailing newline
+//	env://DB_PASSWORD           environment variable
+//	vault://finops/data/db#dsn  key of the vault secret, kv-v2 data is unwrapped
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	var (
+		resolved string
+		err      error
+	)
+
+	switch {
+	case strings.HasPrefix(value, SchemeFile):
+		resolved, err = r.file(strings.TrimPrefix(value, SchemeFile))
+	case strings.HasPrefix(value, SchemeEnv):
+		resolved, err = env(strings.TrimPrefix(value, SchemeEnv))
+	case strings.HasPrefix(value, SchemeVault):
+		resolved, err = r.vaultValue(ctx, strings.TrimPrefix(value, SchemeVault))
+	default:
+		return value, nil
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve secret [%s]; %w", value, err)
+	}
+
+	Register(resolved)
+
+	return resolved, nil
+}
+
+func (r *Resolver) file(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err //nolint:wrapcheck // no need
+	}
+
+	r.Files = append(r.Files, path)
+
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+func env(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("env [%s] not set", name)
+	}
+
+	return value, nil
+}
+
+func (r *Resolver) vaultValue(ctx context.Context, ref string) (string, error) {
+	path, key, ok := strings.Cut(ref, "#")
+	if !ok || key == "" {
+		return "", fmt.Errorf("key is required like vault://path#key")
+	}
+
+	if r.vault == nil {
+		client, err := loader.NewVaulterFromEnv(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to create vault client; %w", err)
+		}
+
+		if client == nil {
+			return "", loader.ErrNoClient
+		}
+
+		r.vault = client
+	}
+
+	secret, err := r.vault.Read(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read vault path; %w", err)
+	}
+
+	if secret == nil {
+		return "", fmt.Errorf("vault path not found")
+	}
+
+	data := secret.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		data = inner
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("vault key [%s] not found", key)
+	}
+
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	return fmt.Sprint(value), nil
+}
+
+// ResolveAll resolves the references in all string values of the struct pointer.
+// Values of the fields tagged with log:"false" are registered as secrets even if they are not references.
+func (r *Resolver) ResolveAll(ctx context.Context, v any) error {
+	return r.walk(ctx, reflect.ValueOf(v), false)
+}
+
+func (r *Resolver) walk(ctx context.Context, v reflect.Value, hidden bool) error {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+
+		return r.walk(ctx, v.Elem(), hidden)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+
+			if err := r.walk(ctx, v.Field(i), hidden || isHidden(f)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := r.walk(ctx, v.Index(i), hidden); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			// map values are not addressable
+			value := reflect.New(iter.Value().Type()).Elem()
+			value.Set(iter.Value())
+
+			if err := r.walk(ctx, value, hidden); err != nil {
+				return err
+			}
+
+			v.SetMapIndex(iter.Key(), value)
+		}
+	case reflect.String:
+		if !v.CanSet() {
+			return nil
+		}
+
+		resolved, err := r.Resolve(ctx, v.String())
+		if err != nil {
+			return err
+		}
+
+		if hidden {
+			Register(resolved)
+		}
+
+		v.SetString(resolved)
+	}
+
+	return nil
+}
+
+func isHidden(f reflect.StructField) bool {
+	if value, ok := f.Tag.Lookup("log"); ok {
+		loggable, _ := strconv.ParseBool(value)
+
+		return !loggable
+	}
+
+	return false
+}
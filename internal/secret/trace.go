@@ -0,0 +1,113 @@
+package secret
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerProvider redacts registered secrets from span names, attributes, events and statuses.
+// Set it as the global provider before creating instrumentations.
+func TracerProvider(tp trace.TracerProvider) trace.TracerProvider {
+	return &tracerProvider{TracerProvider: tp}
+}
+
+type tracerProvider struct {
+	trace.TracerProvider
+}
+
+func (p *tracerProvider) Tracer(name string, options ...trace.TracerOption) trace.Tracer {
+	return &tracer{Tracer: p.TracerProvider.Tracer(name, options...), provider: p}
+}
+
+type tracer struct {
+	trace.Tracer
+	provider *tracerProvider
+}
+
+func (t *tracer) Start(ctx context.Context, spanName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	if attrs := cfg.Attributes(); len(attrs) > 0 {
+		// later attributes with the same key win
+		opts = append(opts, trace.WithAttributes(redactAttributes(attrs)...))
+	}
+
+	ctx, s := t.Tracer.Start(ctx, Redact(spanName), opts...)
+	wrapped := &span{Span: s, provider: t.provider}
+
+	return trace.ContextWithSpan(ctx, wrapped), wrapped
+}
+
+type span struct {
+	trace.Span
+	provider *tracerProvider
+}
+
+func (s *span) SetName(name string) {
+	s.Span.SetName(Redact(name))
+}
+
+func (s *span) SetAttributes(kv ...attribute.KeyValue) {
+	s.Span.SetAttributes(redactAttributes(kv)...)
+}
+
+func (s *span) SetStatus(code codes.Code, description string) {
+	s.Span.SetStatus(code, Redact(description))
+}
+
+func (s *span) AddEvent(name string, options ...trace.EventOption) {
+	s.Span.AddEvent(Redact(name), redactEventOptions(options)...)
+}
+
+func (s *span) RecordError(err error, options ...trace.EventOption) {
+	if err != nil {
+		if msg := Redact(err.Error()); msg != err.Error() {
+			err = errors.New(msg)
+		}
+	}
+
+	s.Span.RecordError(err, redactEventOptions(options)...)
+}
+
+func (s *span) TracerProvider() trace.TracerProvider {
+	return s.provider
+}
+
+func redactEventOptions(options []trace.EventOption) []trace.EventOption {
+	cfg := trace.NewEventConfig(options...)
+	if len(cfg.Attributes()) == 0 {
+		return options
+	}
+
+	redacted := []trace.EventOption{
+		trace.WithTimestamp(cfg.Timestamp()),
+		trace.WithStackTrace(cfg.StackTrace()),
+		trace.WithAttributes(redactAttributes(cfg.Attributes())...),
+	}
+
+	return redacted
+}
+
+func redactAttributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	redacted := make([]attribute.KeyValue, len(attrs))
+	for i, attr := range attrs {
+		switch attr.Value.Type() {
+		case attribute.STRING:
+			attr.Value = attribute.StringValue(Redact(attr.Value.AsString()))
+		case attribute.STRINGSLICE:
+			values := attr.Value.AsStringSlice()
+			for j := range values {
+				values[j] = Redact(values[j])
+			}
+
+			attr.Value = attribute.StringSliceValue(values)
+		}
+
+		redacted[i] = attr
+	}
+
+	return redacted
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/rs/zerolog/log"
+	"github.com/ziflex/lecho/v3"
+
+	"github.com/worldline-go/telemetry_example/internal/server/admin"
+	"github.com/worldline-go/telemetry_example/internal/util"
+)
+
+// NewAdminRouter returns router for health, readiness and diagnostics.
+// It is not traced and not logged to keep probes out of the telemetry.
+func NewAdminRouter(rs RouterSettings, handler *admin.Handler) *Router {
+	e := echo.New()
+	e.HideBanner = true
+
+	e.Logger = lecho.From(log.Logger)
+	e.Validator = util.NewValidator()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.IPExtractor = IPExtractor(rs.TrustedProxies)
+
+	e.Use(
+		middleware.Recover(),
+		middleware.RequestID(),
+	)
+
+	handler.Register(e)
+
+	return &Router{
+		echo: e,
+		rs:   rs.SetDefaults(),
+	}
+}
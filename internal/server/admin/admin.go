@@ -0,0 +1,247 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/logging"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/tenant"
+)
+
+// checkTimeout for each readiness check.
+var checkTimeout = 3 * time.Second
+
+// Check is a readiness check, returns error when the dependency is not usable.
+type Check struct {
+	Name string
+	Func func(ctx context.Context) error
+}
+
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Readiness struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type BuildInfo struct {
+	Service   string            `json:"service"`
+	Version   string            `json:"version"`
+	GoVersion string            `json:"go_version"`
+	Module    string            `json:"module,omitempty"`
+	Settings  map[string]string `json:"settings,omitempty"`
+}
+
+type LogLevel struct {
+	Level string `json:"level" validate:"required,oneof=trace debug info warn error fatal panic disabled"`
+}
+
+type Handler struct {
+	Checks []Check
+	// Middlewares protects the log and tenant endpoints, like authentication.
+	Middlewares []echo.MiddlewareFunc
+	// Authenticated is set when the Middlewares authenticate the requests.
+	// Log settings are read only without it, anyone reaching the admin port could change them.
+	Authenticated bool
+	// Tenants enables the tenant endpoints when set.
+	Tenants *tenant.Registry
+}
+
+func (h *Handler) Register(e *echo.Echo) {
+	e.GET("/healthz", h.Healthz)
+	e.GET("/readyz", h.Readyz)
+	e.GET("/info", h.Info)
+
+	logGroup := e.Group("/log", h.Middlewares...)
+	logGroup.GET("", h.GetLog)
+	logGroup.GET("/level", h.GetLogLevel)
+
+	if h.Authenticated {
+		logGroup.PUT("", h.SetLog)
+		logGroup.PUT("/level", h.SetLogLevel)
+	}
+
+	if h.Tenants != nil {
+		// provisioning opens pools and runs migrations, it always needs the admin credentials
+		tenantGroup := e.Group("/tenants", slices.Concat(h.Middlewares, []echo.MiddlewareFunc{h.Tenants.AdminMiddleware()})...)
+		tenantGroup.GET("", h.GetTenants)
+		tenantGroup.POST("", h.ProvisionTenant)
+	}
+
+	e.GET("/debug/pprof/*", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
+	e.GET("/debug/pprof/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+	e.GET("/debug/pprof/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)))
+	e.GET("/debug/pprof/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	e.POST("/debug/pprof/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	e.GET("/debug/pprof/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)))
+}
+
+// Healthz returns ok while the process is running.
+func (h *Handler) Healthz(c echo.Context) error {
+	return c.JSON(http.StatusOK, model.Message{Message: "ok"})
+}
+
+// Readyz runs all checks concurrently, returns 503 if one of them fails.
+func (h *Handler) Readyz(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), checkTimeout)
+	defer cancel()
+
+	result := Readiness{
+		Status: "ok",
+		Checks: make(map[string]CheckResult, len(h.Checks)),
+	}
+
+	var (
+		mutex sync.Mutex
+		wg    sync.WaitGroup
+	)
+
+	for _, check := range h.Checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+
+			checkResult := CheckResult{Status: "ok"}
+			if err := check.Func(ctx); err != nil {
+				checkResult = CheckResult{Status: "fail", Error: err.Error()}
+			}
+
+			mutex.Lock()
+			result.Checks[check.Name] = checkResult
+			if checkResult.Status != "ok" {
+				result.Status = "fail"
+			}
+			mutex.Unlock()
+		}(check)
+	}
+
+	wg.Wait()
+
+	if result.Status != "ok" {
+		return c.JSON(http.StatusServiceUnavailable, result)
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) Info(c echo.Context) error {
+	info := BuildInfo{
+		Service:   config.ServiceName,
+		Version:   config.ServiceVersion,
+		GoVersion: runtime.Version(),
+	}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		info.Module = buildInfo.Main.Path
+		info.Settings = make(map[string]string)
+
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision", "vcs.time", "vcs.modified", "GOOS", "GOARCH", "CGO_ENABLED":
+				info.Settings[setting.Key] = setting.Value
+			}
+		}
+	}
+
+	return c.JSON(http.StatusOK, info)
+}
+
+func (h *Handler) GetLogLevel(c echo.Context) error {
+	return c.JSON(http.StatusOK, LogLevel{Level: logging.Current().Level})
+}
+
+func (h *Handler) SetLogLevel(c echo.Context) error {
+	var level LogLevel
+	if err := c.Bind(&level); err != nil {
+		return err //nolint:wrapcheck // echo.HTTPError
+	}
+
+	if err := c.Validate(&level); err != nil {
+		return err //nolint:wrapcheck // model.ValidationError
+	}
+
+	if err := logging.SetLevel(level.Level); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, LogLevel{Level: logging.Current().Level})
+}
+
+// GetLog returns the log level, route and package levels and sampling rate.
+func (h *Handler) GetLog(c echo.Context) error {
+	return c.JSON(http.StatusOK, logging.Current())
+}
+
+// SetLog replaces all the log settings.
+func (h *Handler) SetLog(c echo.Context) error {
+	var settings logging.Settings
+	if err := c.Bind(&settings); err != nil {
+		return err //nolint:wrapcheck // echo.HTTPError
+	}
+
+	if err := c.Validate(&settings); err != nil {
+		return err //nolint:wrapcheck // model.ValidationError
+	}
+
+	if err := logging.Apply(settings); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	log.Ctx(c.Request().Context()).Info().Interface("log", settings).Msg("changed log settings")
+
+	return c.JSON(http.StatusOK, logging.Current())
+}
+
+// GetTenants returns the provisioned tenants.
+func (h *Handler) GetTenants(c echo.Context) error {
+	ids := h.Tenants.IDs()
+
+	tenants := make([]model.Tenant, 0, len(ids))
+	for _, id := range ids {
+		tenants = append(tenants, model.Tenant{ID: id, Schema: h.Tenants.Schema(id)})
+	}
+
+	return c.JSON(http.StatusOK, model.Message{Data: tenants})
+}
+
+// ProvisionTenant creates the schema of the tenant and runs the migrations, it is safe to repeat.
+func (h *Handler) ProvisionTenant(c echo.Context) error {
+	var t model.Tenant
+	if err := c.Bind(&t); err != nil {
+		return err //nolint:wrapcheck // echo.HTTPError
+	}
+
+	if err := c.Validate(&t); err != nil {
+		return err //nolint:wrapcheck // model.ValidationError
+	}
+
+	created, err := h.Tenants.Provision(c.Request().Context(), t.ID)
+	if err != nil {
+		return fmt.Errorf("failed to provision tenant; %w", err)
+	}
+
+	message := "tenant already provisioned"
+	if created {
+		message = "tenant provisioned"
+	}
+
+	return c.JSON(http.StatusOK, model.Message{
+		Message: message,
+		Data:    model.Tenant{ID: t.ID, Schema: h.Tenants.Schema(t.ID)},
+	})
+}
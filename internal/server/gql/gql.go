@@ -0,0 +1,175 @@
+package gql
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	gqlotel "github.com/graph-gophers/graphql-go/trace/otel"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+
+	"github.com/worldline-go/telemetry_example/internal/auth"
+	"github.com/worldline-go/telemetry_example/internal/ratelimit"
+	"github.com/worldline-go/telemetry_example/internal/server"
+	"github.com/worldline-go/telemetry_example/internal/server/handler"
+	"github.com/worldline-go/telemetry_example/internal/util"
+)
+
+//go:embed schema.graphql
+var schema string
+
+// maxDepth stops the deeply nested queries before resolving.
+const maxDepth = 10
+
+// GraphQL serves the queries with the same handler of the http api.
+type GraphQL struct {
+	schema *graphql.Schema
+	h      *handler.Handler
+}
+
+// Request is the body of the graphql call.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Settings of the checks of the resolvers, they use the scopes and rate limits of the matching http routes.
+type Settings struct {
+	// Auth checks the route scopes when set.
+	Auth *auth.Auth
+	// Limiter applies the rate limits when set.
+	Limiter *ratelimit.Limiter
+}
+
+func New(h *handler.Handler, settings Settings) (*GraphQL, error) {
+	r := &resolver{
+		h:         h,
+		validator: util.NewValidator(),
+		auth:      settings.Auth,
+		limiter:   settings.Limiter,
+	}
+
+	s, err := graphql.ParseSchema(schema, r,
+		graphql.Tracer(gqlotel.DefaultTracer()),
+		graphql.MaxDepth(maxDepth),
+		graphql.UseStringDescriptions(),
+		graphql.PanicHandler(panicHandler{}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse graphql schema; %w", err)
+	}
+
+	return &GraphQL{schema: s, h: h}, nil
+}
+
+// Handle
+//
+// @Summary     GraphQL query
+// @Description Products and counter queries, errors are in the response with the problem type and status.
+// @Tags        graphql
+// @Accept      application/json
+// @Produce     application/json
+// @Param       request body Request true "GraphQL request"
+// @Router      /graphql [POST]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} object
+// @Failure     400 {object} model.Problem
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+func (g *GraphQL) Handle(c echo.Context) error {
+	var req Request
+	if err := c.Bind(&req); err != nil {
+		return err //nolint:wrapcheck // echo.HTTPError
+	}
+
+	if req.Query == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "query is required")
+	}
+
+	ctx := withLoaders(withClient(c.Request().Context(), c), newLoaders(g.h))
+
+	response := g.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	for _, qErr := range response.Errors {
+		resolverError(ctx, qErr)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// resolverError hides the cause of the resolver error like the problem responses.
+func resolverError(ctx context.Context, qErr *gqlerrors.QueryError) {
+	if qErr.ResolverError == nil {
+		return
+	}
+
+	problem := server.NewProblem(qErr.ResolverError)
+	if problem.Status >= http.StatusInternalServerError {
+		log.Ctx(ctx).Error().Err(qErr.ResolverError).Interface("path", qErr.Path).Msg("graphql resolver failed")
+	}
+
+	qErr.Message = problem.Detail
+	if qErr.Message == "" {
+		qErr.Message = problem.Title
+	}
+
+	qErr.Extensions = map[string]interface{}{
+		"type":   problem.Type,
+		"status": problem.Status,
+	}
+
+	if len(problem.Errors) > 0 {
+		qErr.Extensions["errors"] = problem.Errors
+	}
+}
+
+// panicHandler hides the panic value from the response, graphql recovers the resolvers itself.
+type panicHandler struct{}
+
+func (panicHandler) MakePanicError(ctx context.Context, value interface{}) *gqlerrors.QueryError {
+	log.Ctx(ctx).Error().Interface("panic", value).Bytes("stack", debug.Stack()).Msg("graphql panic recovered")
+
+	return &gqlerrors.QueryError{
+		Message:    "internal server error",
+		Extensions: map[string]interface{}{"type": server.ProblemTypeInternal, "status": http.StatusInternalServerError},
+	}
+}
+
+// Int64 is the 64-bit integer scalar, Int of graphql is 32-bit.
+type Int64 int64
+
+func (Int64) ImplementsGraphQLType(name string) bool {
+	return name == "Int64"
+}
+
+func (i *Int64) UnmarshalGraphQL(input interface{}) error {
+	switch v := input.(type) {
+	case int32:
+		*i = Int64(v)
+	case int64:
+		*i = Int64(v)
+	case float64:
+		*i = Int64(v)
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid Int64 value %q: %w", v, err)
+		}
+
+		*i = Int64(n)
+	default:
+		return fmt.Errorf("invalid Int64 type %T", input)
+	}
+
+	return nil
+}
+
+func (i Int64) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, int64(i), 10), nil
+}
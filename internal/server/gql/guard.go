@@ -0,0 +1,56 @@
+package gql
+
+import (
+	"context"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/worldline-go/telemetry_example/internal/ratelimit"
+)
+
+// client of the graphql request, resolvers check it like the requests of the http routes.
+type client struct {
+	// prefix of the http routes like "/api/v1".
+	prefix string
+	// route of the graphql endpoint, its rate limit rule is not taken again by the resolvers.
+	route   string
+	request ratelimit.Request
+}
+
+type clientKey struct{}
+
+func withClient(ctx context.Context, c echo.Context) context.Context {
+	return context.WithValue(ctx, clientKey{}, client{
+		prefix: strings.TrimSuffix(c.Path(), "/graphql"),
+		route:  c.Path(),
+		request: ratelimit.Request{
+			IP:     c.RealIP(),
+			Header: c.Request().Header.Get,
+		},
+	})
+}
+
+// guard checks the scopes and rate limits of the http route with the method and path like "/count".
+// Returned release should be called when the resolver ends.
+func (r *resolver) guard(ctx context.Context, method, path string) (func(), error) {
+	cl, _ := ctx.Value(clientKey{}).(client)
+	path = cl.prefix + path
+
+	if err := r.auth.Authorize(ctx, method+" "+path); err != nil {
+		return nil, err //nolint:wrapcheck // model.Error
+	}
+
+	if r.limiter == nil || r.limiter.SameRule(path, cl.route) {
+		return func() {}, nil
+	}
+
+	cl.request.Route = path
+
+	release, _, err := r.limiter.Acquire(ctx, cl.request)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // model.Error
+	}
+
+	return release, nil
+}
@@ -0,0 +1,77 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/graph-gophers/dataloader/v7"
+	dataloaderotel "github.com/graph-gophers/dataloader/v7/trace/otel"
+	"go.opentelemetry.io/otel"
+
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/server/handler"
+)
+
+// loaderWait collects the product fields of the same query in one batch.
+var loaderWait = 2 * time.Millisecond
+
+type loadersKey struct{}
+
+// loaders are created for every request, cached products are not shared between requests.
+type loaders struct {
+	product *dataloader.Loader[string, *model.Product]
+}
+
+func newLoaders(h *handler.Handler) *loaders {
+	return &loaders{
+		product: dataloader.NewBatchedLoader(productBatch(h),
+			dataloader.WithWait[string, *model.Product](loaderWait),
+			dataloader.WithTracer[string, *model.Product](
+				dataloaderotel.NewTracer[string, *model.Product](otel.Tracer("dataloader")),
+			),
+		),
+	}
+}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	l, _ := ctx.Value(loadersKey{}).(*loaders)
+
+	return l
+}
+
+// productBatch gets all requested names with one query.
+func productBatch(h *handler.Handler) dataloader.BatchFunc[string, *model.Product] {
+	return func(ctx context.Context, names []string) []*dataloader.Result[*model.Product] {
+		results := make([]*dataloader.Result[*model.Product], len(names))
+
+		products, err := h.FindProducts(ctx, names)
+		if err != nil {
+			for i := range results {
+				results[i] = &dataloader.Result[*model.Product]{Error: err}
+			}
+
+			return results
+		}
+
+		for i, name := range names {
+			product, ok := products[name]
+			if !ok {
+				results[i] = &dataloader.Result[*model.Product]{Error: &model.Error{
+					Kind:   model.ErrNotFound,
+					Detail: fmt.Sprintf("product [%s] not found", name),
+				}}
+
+				continue
+			}
+
+			results[i] = &dataloader.Result[*model.Product]{Data: product}
+		}
+
+		return results
+	}
+}
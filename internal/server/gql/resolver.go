@@ -0,0 +1,299 @@
+package gql
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"github.com/worldline-go/telemetry_example/internal/auth"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/ratelimit"
+	"github.com/worldline-go/telemetry_example/internal/server/handler"
+	"github.com/worldline-go/telemetry_example/internal/util"
+)
+
+const (
+	maxPageSize = 100
+	// scope is the tracer name of the handler spans.
+	scope = "graphql"
+)
+
+// resolver is the root of the queries and mutations.
+type resolver struct {
+	h         *handler.Handler
+	validator *util.Validator
+	auth      *auth.Auth
+	limiter   *ratelimit.Limiter
+}
+
+type productArgs struct {
+	Name string
+}
+
+type productsArgs struct {
+	// First has the default value in the schema.
+	First int32
+	After *string
+}
+
+type productInputArgs struct {
+	Input struct {
+		Name        string
+		Description string
+	}
+}
+
+// Product returns null for the missing product like a nullable field.
+func (r *resolver) Product(ctx context.Context, args productArgs) (*productResolver, error) {
+	release, err := r.guard(ctx, http.MethodGet, "/products/:name")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	product, err := loadersFrom(ctx).product.Load(ctx, args.Name)()
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return &productResolver{product: product}, nil
+}
+
+func (r *resolver) Products(ctx context.Context, args productsArgs) (*connectionResolver, error) {
+	release, err := r.guard(ctx, http.MethodGet, "/products")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	first := args.First
+	if first < 1 || first > maxPageSize {
+		return nil, &model.ValidationError{Fields: []model.FieldError{{
+			Field:   "first",
+			Rule:    "range",
+			Param:   "1-" + strconv.Itoa(maxPageSize),
+			Message: fmt.Sprintf("first must be between 1 and %d", maxPageSize),
+		}}}
+	}
+
+	var afterID int64
+	if args.After != nil {
+		id, err := decodeCursor(*args.After)
+		if err != nil {
+			return nil, err
+		}
+
+		afterID = id
+	}
+
+	// one more row tells there is a next page
+	products, err := r.h.ListProducts(ctx, model.ProductFilter{}, afterID, uint(first)+1)
+	if err != nil {
+		return nil, err
+	}
+
+	connection := &connectionResolver{}
+	if len(products) > int(first) {
+		products = products[:first]
+		connection.hasNextPage = true
+	}
+
+	productLoader := loadersFrom(ctx).product
+	for i := range products {
+		product := &products[i]
+		// product queries of the same request don't go to the database again
+		productLoader.Prime(ctx, product.Name, product)
+		connection.nodes = append(connection.nodes, &productResolver{product: product})
+	}
+
+	if len(products) > 0 {
+		cursor := encodeCursor(products[len(products)-1].ID)
+		connection.endCursor = &cursor
+	}
+
+	return connection, nil
+}
+
+func (r *resolver) Count(ctx context.Context) (Int64, error) {
+	release, err := r.guard(ctx, http.MethodGet, "/count")
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	return Int64(r.h.Count(ctx, scope)), nil
+}
+
+func (r *resolver) AddCount(ctx context.Context, args struct{ Count int32 }) (Int64, error) {
+	release, err := r.guard(ctx, http.MethodPost, "/count")
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	count := model.Count{Count: int64(args.Count)}
+	if err := r.validator.Validate(&count); err != nil {
+		return 0, err //nolint:wrapcheck // model.ValidationError
+	}
+
+	return Int64(r.h.AddCount(ctx, scope, count.Count)), nil
+}
+
+func (r *resolver) CreateProduct(ctx context.Context, args productInputArgs) (*productResolver, error) {
+	release, err := r.guard(ctx, http.MethodPost, "/products")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	product := model.Product{
+		Name:        args.Input.Name,
+		Description: args.Input.Description,
+	}
+
+	if err := r.validator.Validate(&product); err != nil {
+		return nil, err //nolint:wrapcheck // model.ValidationError
+	}
+
+	if _, err := r.h.CreateProduct(ctx, product); err != nil {
+		return nil, err
+	}
+
+	// read back for the generated fields like id and dates
+	created, err := r.h.FindProduct(ctx, product.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.prime(ctx, created), nil
+}
+
+func (r *resolver) UpdateProduct(ctx context.Context, args productInputArgs) (*productResolver, error) {
+	release, err := r.guard(ctx, http.MethodPut, "/products/:name")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	input := model.Product{
+		Name:        args.Input.Name,
+		Description: args.Input.Description,
+	}
+
+	if err := r.validator.Validate(&input); err != nil {
+		return nil, err //nolint:wrapcheck // model.ValidationError
+	}
+
+	// only the description is changed, other fields are kept
+	product, err := r.h.FindProduct(ctx, input.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	product.Description = input.Description
+
+	updated, err := r.h.UpdateProduct(ctx, *product)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.prime(ctx, updated), nil
+}
+
+func (r *resolver) SendProduct(ctx context.Context, args productArgs) (*productResolver, error) {
+	release, err := r.guard(ctx, http.MethodPost, "/products-send/:name")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	product, err := r.h.PublishProduct(ctx, args.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.prime(ctx, product), nil
+}
+
+// prime replaces the cached product with the changed one for the next fields of the request.
+func (r *resolver) prime(ctx context.Context, product *model.Product) *productResolver {
+	loadersFrom(ctx).product.Clear(ctx, product.Name).Prime(ctx, product.Name, product)
+
+	return &productResolver{product: product}
+}
+
+type productResolver struct {
+	product *model.Product
+}
+
+func (p *productResolver) ID() graphql.ID {
+	return graphql.ID(strconv.FormatInt(p.product.ID, 10))
+}
+
+func (p *productResolver) Name() string        { return p.product.Name }
+func (p *productResolver) Description() string { return p.product.Description }
+func (p *productResolver) LastUser() string    { return p.product.LastUser }
+func (p *productResolver) UpdatedAt() string   { return p.product.UpdatedAt }
+func (p *productResolver) CreatedAt() string   { return p.product.CreatedAt }
+
+type connectionResolver struct {
+	nodes       []*productResolver
+	endCursor   *string
+	hasNextPage bool
+}
+
+func (c *connectionResolver) Nodes() []*productResolver {
+	return c.nodes
+}
+
+func (c *connectionResolver) PageInfo() *pageInfoResolver {
+	return &pageInfoResolver{endCursor: c.endCursor, hasNextPage: c.hasNextPage}
+}
+
+type pageInfoResolver struct {
+	endCursor   *string
+	hasNextPage bool
+}
+
+func (p *pageInfoResolver) EndCursor() *string { return p.endCursor }
+func (p *pageInfoResolver) HasNextPage() bool  { return p.hasNextPage }
+
+// encodeCursor hides the id to let the pagination change without breaking clients.
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("product:" + strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	invalid := &model.ValidationError{Fields: []model.FieldError{{
+		Field:   "after",
+		Rule:    "cursor",
+		Message: "after must be an endCursor of the products",
+	}}}
+
+	v, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, invalid
+	}
+
+	idStr, ok := strings.CutPrefix(string(v), "product:")
+	if !ok {
+		return 0, invalid
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id < 0 {
+		return 0, invalid
+	}
+
+	return id, nil
+}
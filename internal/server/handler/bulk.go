@@ -0,0 +1,591 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/auth"
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+const (
+	MIMEApplicationNDJSON = "application/x-ndjson"
+	MIMETextCSV           = "text/csv"
+
+	// importBatchSize rows are recorded with one query.
+	importBatchSize = 500
+	// exportBatchSize rows are read with one query.
+	exportBatchSize = 1000
+	// maxImportErrors listed in the import report.
+	maxImportErrors = 100
+	// maxImportLine is the longest ndjson line.
+	maxImportLine = 1 << 20
+)
+
+// csvColumns of the export, import needs the name column and uses the description, category_id, tags and price columns.
+var csvColumns = []string{
+	"id", "name", "description", "category_id", "tags", "price_amount", "price_currency", "last_user", "updated_at", "created_at",
+}
+
+// csvTagSeparator joins the tags in the csv column.
+const csvTagSeparator = "|"
+
+// @Summary     Import products
+// @Description Import products from the ndjson or csv body, csv needs a header with the name column.
+// @Description Optional csv columns are description, category_id, tags separated with | and price_amount with price_currency.
+// @Description Rows are recorded in batches, the report has the errors of the failed rows. Upserts only change the given columns.
+// @Tags        products
+// @Accept      application/x-ndjson,text/csv
+// @Produce     application/json
+// @Param       on_conflict query string false "Existing products are updated, skipped or failed" Enums(upsert, skip, fail) default(fail)
+// @Router      /products:import [POST]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} model.Message{data=model.ImportReport}
+// @Failure     400 {object} model.Problem
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     415 {object} model.Problem
+// @Failure     422 {object} model.Problem
+func (h *Handler) ImportProducts(c echo.Context) error {
+	var query model.ImportQuery
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &query); err != nil {
+		return err //nolint:wrapcheck // echo.HTTPError
+	}
+
+	if err := c.Validate(&query); err != nil {
+		return err //nolint:wrapcheck // model.ValidationError
+	}
+
+	if query.OnConflict == "" {
+		query.OnConflict = model.ConflictFail
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+
+	var next func() (importRow, error)
+	switch mediaType {
+	case MIMEApplicationNDJSON:
+		next = ndjsonRows(c.Request().Body)
+	case MIMETextCSV:
+		var err error
+		next, err = csvRows(c.Request().Body)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	default:
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "content type must be "+MIMEApplicationNDJSON+" or "+MIMETextCSV)
+	}
+
+	ctx, span := otel.Tracer("").Start(c.Request().Context(), "import_products",
+		trace.WithAttributes(
+			attribute.String("import.format", mediaType),
+			attribute.String("import.on_conflict", query.OnConflict),
+		),
+	)
+	defer span.End()
+
+	lastUser := auth.Subject(ctx, config.ServiceName)
+	report := &model.ImportReport{}
+
+	addError := func(line int, name string, err error) {
+		report.Failed++
+		if len(report.Errors) < maxImportErrors {
+			report.Errors = append(report.Errors, model.ImportError{Line: line, Name: name, Error: err.Error()})
+		}
+	}
+
+	var (
+		batch []importRow
+		names = make(map[string]struct{}, importBatchSize)
+	)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		err := h.importBatch(ctx, batch, lastUser, query.OnConflict, report, addError)
+
+		batch = batch[:0]
+		clear(names)
+
+		return err
+	}
+
+	for {
+		row, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			var rowErr *rowError
+			if !errors.As(err, &rowErr) {
+				span.SetStatus(codes.Error, err.Error())
+
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("failed to read line %d: %v", row.line, err))
+			}
+
+			addError(row.line, "", rowErr.err)
+
+			continue
+		}
+
+		if err := c.Validate(&row.product); err != nil {
+			addError(row.line, row.product.Name, err)
+
+			continue
+		}
+
+		// same name in a batch can't be inserted and updated in one query,
+		// and the rows of a batch update the same columns
+		_, ok := names[row.product.Name]
+		if ok || (len(batch) > 0 && batch[0].columns != row.columns) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+
+		batch = append(batch, row)
+		names[row.product.Name] = struct{}{}
+
+		if len(batch) >= importBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	span.SetAttributes(
+		attribute.Int("import.inserted", report.Inserted),
+		attribute.Int("import.updated", report.Updated),
+		attribute.Int("import.skipped", report.Skipped),
+		attribute.Int("import.failed", report.Failed),
+	)
+
+	return c.JSON(http.StatusOK, model.Message{
+		Message: "products imported",
+		Data:    report,
+	})
+}
+
+type importRow struct {
+	line    int
+	product model.Product
+	// columns given in the row, existing products keep the other fields.
+	columns model.ImportColumns
+}
+
+func (h *Handler) importBatch(ctx context.Context, batch []importRow, lastUser, onConflict string, report *model.ImportReport, addError func(int, string, error)) error {
+	ctx, span := otel.Tracer("").Start(ctx, "import_products_batch",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.name", "postgres|products"),
+			attribute.Int("import.batch_size", len(batch)),
+		),
+	)
+	defer span.End()
+
+	batch, err := h.checkCategories(ctx, batch, addError)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return fmt.Errorf("failed to check categories at line %d; %w", batch[0].line, err)
+	}
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	products := make([]model.Product, 0, len(batch))
+	for _, row := range batch {
+		products = append(products, row.product)
+	}
+
+	result, err := h.DB.ImportProducts(ctx, products, batch[0].columns, lastUser, onConflict == model.ConflictUpsert)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return fmt.Errorf("failed to import products at line %d; %w", batch[0].line, err)
+	}
+
+	for _, row := range batch {
+		imported, ok := result[row.product.Name]
+		if ok {
+			h.Cache.Invalidate(ctx, row.product.Name)
+		}
+
+		// recorded product has the kept fields of the existing product
+		if ok && h.Search != nil {
+			h.Search.Index(ctx, &imported.Product)
+		}
+
+		switch {
+		case ok && imported.Inserted:
+			report.Inserted++
+		case ok:
+			report.Updated++
+		case onConflict == model.ConflictSkip:
+			report.Skipped++
+		default:
+			addError(row.line, row.product.Name, errors.New("product already exists"))
+		}
+	}
+
+	return nil
+}
+
+// checkCategories reports the rows with a missing category as failed rows and returns the other rows.
+// A missing category would fail the query of the whole batch.
+func (h *Handler) checkCategories(ctx context.Context, batch []importRow, addError func(int, string, error)) ([]importRow, error) {
+	var ids []int64
+	for _, row := range batch {
+		if row.product.CategoryID != nil {
+			ids = append(ids, *row.product.CategoryID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return batch, nil
+	}
+
+	existing, err := h.DB.ExistingCategories(ctx, ids)
+	if err != nil {
+		return batch, err //nolint:wrapcheck // wrapped by the caller
+	}
+
+	rows := batch[:0]
+	for _, row := range batch {
+		if id := row.product.CategoryID; id != nil {
+			if _, ok := existing[*id]; !ok {
+				addError(row.line, row.product.Name, fmt.Errorf("category_id [%d] is not found", *id))
+
+				continue
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// @Summary     Export products
+// @Description Export all products ordered by id as ndjson or csv stream.
+// @Tags        products
+// @Produce     application/x-ndjson,text/csv
+// @Param       format query string false "Format of the export" Enums(ndjson, csv) default(ndjson)
+// @Router      /products:export [GET]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} model.Product
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     422 {object} model.Problem
+func (h *Handler) ExportProducts(c echo.Context) error {
+	var query model.ExportQuery
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &query); err != nil {
+		return err //nolint:wrapcheck // echo.HTTPError
+	}
+
+	if err := c.Validate(&query); err != nil {
+		return err //nolint:wrapcheck // model.ValidationError
+	}
+
+	if query.Format == "" {
+		query.Format = model.FormatNDJSON
+	}
+
+	ctx, span := otel.Tracer("").Start(c.Request().Context(), "export_products",
+		trace.WithAttributes(attribute.String("export.format", query.Format)),
+	)
+	defer span.End()
+
+	w := c.Response()
+
+	var write func(p *model.Product) error
+
+	switch query.Format {
+	case model.FormatCSV:
+		w.Header().Set(echo.HeaderContentType, MIMETextCSV)
+
+		csvWriter := csv.NewWriter(w)
+		write = func(p *model.Product) error {
+			if p == nil {
+				csvWriter.Flush()
+
+				return csvWriter.Error() //nolint:wrapcheck // client is gone
+			}
+
+			return csvWriter.Write(csvRecord(p)) //nolint:wrapcheck // client is gone
+		}
+
+		if err := csvWriter.Write(csvColumns); err != nil {
+			return err //nolint:wrapcheck // client is gone
+		}
+	default:
+		w.Header().Set(echo.HeaderContentType, MIMEApplicationNDJSON)
+
+		encoder := json.NewEncoder(w)
+		write = func(p *model.Product) error {
+			if p == nil {
+				return nil
+			}
+
+			return encoder.Encode(p) //nolint:wrapcheck // client is gone
+		}
+	}
+
+	w.Header().Set(echo.HeaderContentDisposition, "attachment; filename=products."+query.Format)
+	w.WriteHeader(http.StatusOK)
+
+	var afterID int64
+	count := 0
+
+	for {
+		products, err := h.ListProducts(ctx, model.ProductFilter{}, afterID, exportBatchSize)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			log.Ctx(ctx).Error().Err(err).Int("count", count).Msg("export products failed")
+
+			// response is started, the connection is closed without the end of the chunks
+			// so the client sees a broken transfer instead of a complete file
+			panic(http.ErrAbortHandler)
+		}
+
+		for i := range products {
+			if err := write(&products[i]); err != nil {
+				return nil
+			}
+		}
+
+		if err := write(nil); err != nil {
+			return nil
+		}
+
+		w.Flush()
+
+		count += len(products)
+
+		if len(products) < exportBatchSize {
+			break
+		}
+
+		afterID = products[len(products)-1].ID
+	}
+
+	span.SetAttributes(attribute.Int("export.count", count))
+
+	return nil
+}
+
+// rowError is a problem of one row, other rows are still read.
+type rowError struct {
+	err error
+}
+
+func (e *rowError) Error() string {
+	return e.err.Error()
+}
+
+// ndjsonRows returns the products of the lines, empty lines are skipped.
+func ndjsonRows(r io.Reader) func() (importRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLine)
+
+	line := 0
+
+	return func() (importRow, error) {
+		for scanner.Scan() {
+			line++
+
+			data := bytes.TrimSpace(scanner.Bytes())
+			if len(data) == 0 {
+				continue
+			}
+
+			var product model.Product
+			if err := json.Unmarshal(data, &product); err != nil {
+				return importRow{line: line}, &rowError{err: fmt.Errorf("invalid json: %w", err)}
+			}
+
+			// keys of the object are the given columns, null is given to clear the field
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(data, &fields); err != nil {
+				return importRow{line: line}, &rowError{err: fmt.Errorf("invalid json: %w", err)}
+			}
+
+			has := func(key string) bool {
+				_, ok := fields[key]
+
+				return ok
+			}
+
+			// generated fields are not imported
+			return importRow{
+				line: line,
+				product: model.Product{
+					Name:        product.Name,
+					Description: product.Description,
+					CategoryID:  product.CategoryID,
+					Tags:        product.Tags,
+					Price:       product.Price,
+				},
+				columns: model.ImportColumns{
+					Description: has("description"),
+					CategoryID:  has("category_id"),
+					Tags:        has("tags"),
+					Price:       has("price"),
+				},
+			}, nil
+		}
+
+		if err := scanner.Err(); err != nil {
+			return importRow{line: line + 1}, err //nolint:wrapcheck // reported with the line
+		}
+
+		return importRow{line: line}, io.EOF
+	}
+}
+
+// csvRecord returns the columns of the product in the csvColumns order.
+func csvRecord(p *model.Product) []string {
+	var categoryID, amount, currency string
+	if p.CategoryID != nil {
+		categoryID = strconv.FormatInt(*p.CategoryID, 10)
+	}
+
+	if p.Price != nil {
+		amount = strconv.FormatInt(p.Price.Amount, 10)
+		currency = p.Price.Currency
+	}
+
+	return []string{
+		strconv.FormatInt(p.ID, 10), p.Name, p.Description, categoryID, strings.Join(p.Tags, csvTagSeparator),
+		amount, currency, p.LastUser, p.UpdatedAt, p.CreatedAt,
+	}
+}
+
+// csvRows reads the header and returns the products of the records.
+func csvRows(r io.Reader) (func() (importRow, error), error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("csv header needs the name column")
+	}
+
+	has := func(column string) bool {
+		_, ok := columns[column]
+
+		return ok
+	}
+
+	// empty values of the header columns clear the fields
+	given := model.ImportColumns{
+		Description: has("description"),
+		CategoryID:  has("category_id"),
+		Tags:        has("tags"),
+		Price:       has("price_amount"),
+	}
+
+	// line of the last read record, position of the fields is only known after a successful read
+	line, _ := reader.FieldPos(0)
+
+	return func() (importRow, error) {
+		record, err := reader.Read()
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return importRow{line: parseErr.StartLine}, &rowError{err: parseErr.Err}
+			}
+
+			return importRow{line: line + 1}, err //nolint:wrapcheck // io.EOF or reported with the line
+		}
+
+		line, _ = reader.FieldPos(0)
+
+		value := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+
+			return ""
+		}
+
+		product, err := csvProduct(value)
+		if err != nil {
+			return importRow{line: line}, &rowError{err: err}
+		}
+
+		return importRow{line: line, product: product, columns: given}, nil
+	}, nil
+}
+
+// csvProduct returns the product of the column values, empty values are not set.
+func csvProduct(value func(column string) string) (model.Product, error) {
+	product := model.Product{
+		Name:        value("name"),
+		Description: value("description"),
+	}
+
+	if v := value("category_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return product, fmt.Errorf("category_id [%s] must be a number", v)
+		}
+
+		product.CategoryID = &id
+	}
+
+	if v := value("tags"); v != "" {
+		for _, tag := range strings.Split(v, csvTagSeparator) {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				product.Tags = append(product.Tags, tag)
+			}
+		}
+	}
+
+	if v := value("price_amount"); v != "" {
+		amount, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return product, fmt.Errorf("price_amount [%s] must be a number", v)
+		}
+
+		product.Price = &model.Price{Amount: amount, Currency: value("price_currency")}
+	}
+
+	return product, nil
+}
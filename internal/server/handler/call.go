@@ -2,7 +2,10 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
@@ -10,96 +13,244 @@ import (
 	"github.com/worldline-go/klient"
 	"github.com/worldline-go/telemetry_example/internal/config"
 	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/secret"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
 )
 
 // Call
 //
 // @Summary     Call API
-// @Description Call an api with name
+// @Description Call an api with name, the chain in the body is forwarded to it
 // @Tags        call
 // @Produce     application/json
 // @Accept      application/json
 // @Param       data body model.Service true "message"
 // @Param       service path string true "service name"
 // @Router      /call/{service} [POST]
+// @Security    ApiKeyAuth || BearerAuth
 // @Success     200 {object} model.Message{}
-// @Failure     400 {object} model.Message{}
+// @Failure     400 {object} model.Problem
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     404 {object} model.Problem
+// @Failure     422 {object} model.Problem
+// @Failure     502 {object} model.Problem
+// @Failure     503 {object} model.Problem
 func (h *Handler) Call(c echo.Context) error {
 	var serviceBody model.Service
 	if err := c.Bind(&serviceBody); err != nil {
-		return c.JSON(http.StatusBadRequest, model.Message{
-			Message: "failed to bind request",
-		})
+		return err //nolint:wrapcheck // echo.HTTPError
 	}
 
-	tracer := otel.Tracer("")
-	ctx, span := tracer.Start(c.Request().Context(), "call", trace.WithSpanKind(trace.SpanKindInternal))
-	defer span.End()
+	if err := c.Validate(&serviceBody); err != nil {
+		return err //nolint:wrapcheck // model.ValidationError
+	}
+
+	ctx, err := h.Chaos.InjectRequest(c.Request().Context(), serviceBody.Fault)
+	if err != nil {
+		return err //nolint:wrapcheck // injected error
+	}
 
 	service := c.Param("service")
+	if service == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "service name is required")
+	}
 
-	span.SetAttributes(attribute.String("request.call.service", service))
-	span.SetAttributes(attribute.Bool("request.call.error", serviceBody.Error))
+	steps := append([]model.Step{{Service: service}}, serviceBody.Chain...)
 
-	if service == "" {
-		return c.JSON(http.StatusBadRequest, model.Message{
-			Message: "service name is required",
-		})
+	response, err := h.Chain(ctx, serviceBody, steps)
+	if err != nil {
+		return err
 	}
 
-	if _, ok := h.Clients[service]; !ok {
-		return c.JSON(http.StatusBadRequest, model.Message{
-			Message: "service [" + service + "] not found",
-		})
+	return c.JSON(http.StatusOK, response)
+}
+
+// CallChain
+//
+// @Summary     Call chain
+// @Description Call the steps of the chain, parallel steps fan-out and gather the responses
+// @Tags        call
+// @Produce     application/json
+// @Accept      application/json
+// @Param       data body model.Service true "message with chain"
+// @Router      /call [POST]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} model.Message{}
+// @Failure     400 {object} model.Problem
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     404 {object} model.Problem
+// @Failure     422 {object} model.Problem
+// @Failure     502 {object} model.Problem
+// @Failure     503 {object} model.Problem
+func (h *Handler) CallChain(c echo.Context) error {
+	var serviceBody model.Service
+	if err := c.Bind(&serviceBody); err != nil {
+		return err //nolint:wrapcheck // echo.HTTPError
 	}
 
-	// call service
-	messageFromService := model.Service{
-		Message: serviceBody.Message,
-		Error:   serviceBody.Error,
+	if err := c.Validate(&serviceBody); err != nil {
+		return err //nolint:wrapcheck // model.ValidationError
 	}
 
-	messageByte, err := json.Marshal(messageFromService)
+	ctx, err := h.Chaos.InjectRequest(c.Request().Context(), serviceBody.Fault)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, model.Message{
-			Message: "failed to marshal message",
-		})
+		return err //nolint:wrapcheck // injected error
+	}
+
+	if len(serviceBody.Chain) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "chain is required")
 	}
 
-	request, err := http.NewRequestWithContext(ctx,
-		http.MethodPost, "/api/v1/message",
-		bytes.NewReader(messageByte),
-	)
+	response, err := h.Chain(ctx, serviceBody, serviceBody.Chain)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, model.Message{
-			Message: "failed to create request",
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// Chain runs the first step and forwards the remaining ones.
+func (h *Handler) Chain(ctx context.Context, body model.Service, steps []model.Step) (*model.Message, error) {
+	step, rest := steps[0], steps[1:]
+
+	if step.Service != "" {
+		return h.forward(ctx, step.Service, model.Service{
+			Message: body.Message,
+			Error:   body.Error,
+			Fault:   step.Fault,
+			Chain:   rest,
 		})
 	}
 
-	ctx, spanCall := tracer.Start(ctx, service, trace.WithSpanKind(trace.SpanKindClient))
-	defer spanCall.End()
+	ctx, span := otel.Tracer("").Start(ctx, "fan_out", trace.WithSpanKind(trace.SpanKindInternal))
+	defer span.End()
 
-	// add context propagation
-	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(request.Header))
+	span.SetAttributes(attribute.Int("request.call.parallel", len(step.Parallel)))
 
-	log.Info().Msgf("headers: %v", request.Header)
+	gather := model.Gather{Parallel: make([]model.Message, len(step.Parallel))}
 
-	responseMessage := model.Message{}
+	g, gCtx := errgroup.WithContext(ctx)
+	for i, branch := range step.Parallel {
+		g.Go(func() error {
+			response, err := h.Chain(gCtx, body, branch)
+			if err != nil {
+				return err
+			}
 
-	if err := h.Clients[service].Do(request, klient.ResponseFuncJSON(&responseMessage)); err != nil {
-		spanCall.SetStatus(codes.Error, err.Error())
+			gather.Parallel[i] = *response
 
-		return c.JSON(http.StatusInternalServerError, model.Message{
-			Message: err.Error(),
+			return nil
 		})
 	}
 
-	return c.JSON(http.StatusOK, responseMessage)
+	if err := g.Wait(); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err //nolint:wrapcheck // already wrapped
+	}
+
+	span.AddEvent("gathered")
+
+	if len(rest) > 0 {
+		next, err := h.Chain(ctx, body, rest)
+		if err != nil {
+			return nil, err
+		}
+
+		gather.Next = next
+	}
+
+	return &model.Message{
+		Message: "gathered by service " + config.ServiceName,
+		Data:    gather,
+	}, nil
+}
+
+// forward calls the service, message endpoint is used at the end of the chain.
+func (h *Handler) forward(ctx context.Context, service string, body model.Service) (*model.Message, error) {
+	tracer := otel.Tracer("")
+	ctx, span := tracer.Start(ctx, "call", trace.WithSpanKind(trace.SpanKindInternal))
+	defer span.End()
+
+	span.SetAttributes(attribute.String("request.call.service", service))
+	span.SetAttributes(attribute.Bool("request.call.error", body.Error))
+	span.SetAttributes(attribute.Int("request.call.chain", len(body.Chain)))
+
+	client, ok := h.Clients.Get(service)
+	if !ok {
+		return nil, &model.Error{
+			Kind:   model.ErrNotFound,
+			Detail: "service [" + service + "] not found",
+		}
+	}
+
+	path := "/api/v1/message"
+	if len(body.Chain) > 0 {
+		path = "/api/v1/call"
+	}
+
+	messageByte, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message; %w", err)
+	}
+
+	responseMessage := model.Message{}
+
+	// POST without an idempotency key is not retried, the service could have processed it
+	err = h.Caller.Do(ctx, service, false, func(ctx context.Context) error {
+		request, err := http.NewRequestWithContext(ctx,
+			http.MethodPost, path,
+			bytes.NewReader(messageByte),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create request; %w", err)
+		}
+
+		ctx, spanCall := tracer.Start(ctx, service, trace.WithSpanKind(trace.SpanKindClient))
+		defer spanCall.End()
+
+		// add context propagation
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(request.Header))
+
+		log.Ctx(ctx).Info().Msgf("headers: %v", secret.Headers(request.Header, h.APIKeyHeader))
+
+		if err := client.Do(request, klient.ResponseFuncJSON(&responseMessage)); err != nil {
+			spanCall.SetStatus(codes.Error, err.Error())
+
+			return err //nolint:wrapcheck // classified by caller
+		}
+
+		return nil
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		var domainErr *model.Error
+		if errors.As(err, &domainErr) {
+			return nil, err
+		}
+
+		upstreamErr := &model.UpstreamError{
+			Service: service,
+			Cause:   err,
+		}
+
+		var responseErr *klient.ResponseError
+		if errors.As(err, &responseErr) {
+			upstreamErr.StatusCode = responseErr.StatusCode
+		}
+
+		return nil, upstreamErr
+	}
+
+	return &responseMessage, nil
 }
 
 // @Summary     Message to return
@@ -109,27 +260,35 @@ func (h *Handler) Call(c echo.Context) error {
 // @Produce     application/json
 // @Param       data body model.Service true "message"
 // @Router      /message [POST]
+// @Security    ApiKeyAuth || BearerAuth
 // @Success     200 {object} model.Message{}
-// @Failure     400 {object} model.Message{}
+// @Failure     400 {object} model.Problem
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     422 {object} model.Problem
 func (h *Handler) Message(c echo.Context) error {
 	var serviceBody model.Service
 	if err := c.Bind(&serviceBody); err != nil {
-		return c.JSON(http.StatusBadRequest, model.Message{
-			Message: "failed to bind request",
-			Data:    err.Error(),
-		})
+		return err //nolint:wrapcheck // echo.HTTPError
+	}
+
+	if err := c.Validate(&serviceBody); err != nil {
+		return err //nolint:wrapcheck // model.ValidationError
 	}
 
-	log.Info().Msgf("headers: %v", c.Request().Header)
-	_, span := otel.Tracer("").Start(c.Request().Context(), "message", trace.WithSpanKind(trace.SpanKindInternal))
+	ctx, err := h.Chaos.InjectRequest(c.Request().Context(), serviceBody.Fault)
+	if err != nil {
+		return err //nolint:wrapcheck // injected error
+	}
+
+	log.Ctx(ctx).Info().Msgf("headers: %v", secret.Headers(c.Request().Header, h.APIKeyHeader))
+	_, span := otel.Tracer("").Start(ctx, "message", trace.WithSpanKind(trace.SpanKindInternal))
 	defer span.End()
 
 	span.SetAttributes(attribute.String("request.message", serviceBody.Message))
 
 	if serviceBody.Error {
-		return c.JSON(http.StatusBadRequest, model.Message{
-			Message: "error from service " + config.ServiceName,
-		})
+		return echo.NewHTTPError(http.StatusBadRequest, "error from service "+config.ServiceName)
 	}
 
 	return c.JSON(http.StatusOK, model.Message{
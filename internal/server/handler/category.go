@@ -0,0 +1,232 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/auth"
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+// @Summary     Add category
+// @Description Add new category, categories with a parent are subcategories.
+// @Tags        categories
+// @Accept      application/json
+// @Produce     application/json
+// @Param       category body model.Category true "Category to record"
+// @Router      /categories [POST]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} model.Message{data=model.Category}
+// @Failure     400 {object} model.Problem
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     409 {object} model.Problem
+// @Failure     422 {object} model.Problem
+func (h *Handler) AddCategory(c echo.Context) error {
+	var category model.Category
+	if err := c.Bind(&category); err != nil {
+		return err //nolint:wrapcheck // echo.HTTPError
+	}
+
+	if err := c.Validate(&category); err != nil {
+		return err //nolint:wrapcheck // model.ValidationError
+	}
+
+	ctx, span := otel.Tracer("").Start(context.WithoutCancel(c.Request().Context()),
+		"add_category",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.name", "postgres|categories")),
+	)
+	defer span.End()
+
+	id, err := h.DB.AddCategory(ctx, category, auth.Subject(ctx, config.ServiceName))
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return fmt.Errorf("failed to add category; %w", err)
+	}
+
+	// read back for the path
+	created, err := h.DB.GetCategory(ctx, id)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return fmt.Errorf("failed to get category; %w", err)
+	}
+
+	return c.JSON(http.StatusOK, model.Message{
+		Message: "category added",
+		Data:    created,
+	})
+}
+
+// @Summary     Get categories
+// @Description Get all categories ordered by the path.
+// @Tags        categories
+// @Produce     application/json
+// @Router      /categories [GET]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} model.Message{data=[]model.Category}
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+func (h *Handler) GetCategories(c echo.Context) error {
+	ctx, span := otel.Tracer("").Start(c.Request().Context(),
+		"get_categories",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.name", "postgres|categories")),
+	)
+	defer span.End()
+
+	categories, err := h.DB.GetCategories(ctx)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return fmt.Errorf("failed to get categories; %w", err)
+	}
+
+	return c.JSON(http.StatusOK, model.Message{
+		Data: categories,
+	})
+}
+
+// @Summary     Get category
+// @Description Get category with id
+// @Tags        categories
+// @Produce     application/json
+// @Param       id path int true "Category id"
+// @Router      /categories/{id} [GET]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} model.Message{data=model.Category}
+// @Failure     400 {object} model.Problem
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     404 {object} model.Problem
+func (h *Handler) GetCategory(c echo.Context) error {
+	id, err := pathID(c)
+	if err != nil {
+		return err
+	}
+
+	ctx, span := otel.Tracer("").Start(c.Request().Context(),
+		"get_category",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.name", "postgres|categories")),
+	)
+	defer span.End()
+
+	category, err := h.DB.GetCategory(ctx, id)
+	if err != nil {
+		if !errors.Is(err, model.ErrNotFound) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		return fmt.Errorf("failed to get category; %w", err)
+	}
+
+	return c.JSON(http.StatusOK, model.Message{
+		Data: category,
+	})
+}
+
+// @Summary     Update category
+// @Description Change the name and the parent of the category, it can't be moved under its subcategories.
+// @Tags        categories
+// @Accept      application/json
+// @Produce     application/json
+// @Param       id       path int            true "Category id"
+// @Param       category body model.Category true "Category fields"
+// @Router      /categories/{id} [PUT]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} model.Message{data=model.Category}
+// @Failure     400 {object} model.Problem
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     404 {object} model.Problem
+// @Failure     409 {object} model.Problem
+// @Failure     422 {object} model.Problem
+func (h *Handler) UpdateCategory(c echo.Context) error {
+	id, err := pathID(c)
+	if err != nil {
+		return err
+	}
+
+	var category model.Category
+	if err := c.Bind(&category); err != nil {
+		return err //nolint:wrapcheck // echo.HTTPError
+	}
+
+	category.ID = id
+
+	if err := c.Validate(&category); err != nil {
+		return err //nolint:wrapcheck // model.ValidationError
+	}
+
+	ctx, span := otel.Tracer("").Start(context.WithoutCancel(c.Request().Context()),
+		"update_category",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.name", "postgres|categories")),
+	)
+	defer span.End()
+
+	updated, err := h.DB.UpdateCategory(ctx, category, auth.Subject(ctx, config.ServiceName))
+	if err != nil {
+		if !errors.Is(err, model.ErrNotFound) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		return fmt.Errorf("failed to update category; %w", err)
+	}
+
+	return c.JSON(http.StatusOK, model.Message{
+		Message: "category updated",
+		Data:    updated,
+	})
+}
+
+// @Summary     Delete category
+// @Description Delete category without subcategories, its products have no category after it.
+// @Tags        categories
+// @Produce     application/json
+// @Param       id path int true "Category id"
+// @Router      /categories/{id} [DELETE]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} model.Message{}
+// @Failure     400 {object} model.Problem
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     404 {object} model.Problem
+// @Failure     409 {object} model.Problem
+func (h *Handler) DeleteCategory(c echo.Context) error {
+	id, err := pathID(c)
+	if err != nil {
+		return err
+	}
+
+	ctx, span := otel.Tracer("").Start(context.WithoutCancel(c.Request().Context()),
+		"delete_category",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.name", "postgres|categories")),
+	)
+	defer span.End()
+
+	if err := h.DB.DeleteCategory(ctx, id); err != nil {
+		if !errors.Is(err, model.ErrNotFound) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		return fmt.Errorf("failed to delete category; %w", err)
+	}
+
+	return c.JSON(http.StatusOK, model.Message{
+		Message: "category deleted",
+	})
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/telemetry"
+	"github.com/worldline-go/telemetry_example/internal/tenant"
+)
+
+// lookup is the shared result of the coalesced product reads.
+type lookup struct {
+	product *model.Product
+	// span of the query run by the leader.
+	span trace.SpanContext
+}
+
+// getProduct reads the product once for the concurrent lookups of the same tenant and name.
+// Followers wait for the query of the leader, their spans are linked to the query span of the leader.
+func (h *Handler) getProduct(ctx context.Context, name string) (*model.Product, error) {
+	span := trace.SpanFromContext(ctx)
+
+	// Do runs the function in the goroutine of the leader
+	leader := false
+	v, err, shared := h.productLookups.Do(tenant.FromContext(ctx)+"/"+name, func() (interface{}, error) {
+		leader = true
+
+		ctx, querySpan := otel.Tracer("").Start(ctx, "query_product",
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attribute.String("db.name", "postgres|products")),
+		)
+		defer querySpan.End()
+
+		product, err := h.DB.GetProduct(ctx, name)
+		if err != nil && !errors.Is(err, model.ErrNotFound) {
+			querySpan.SetStatus(codes.Error, err.Error())
+		}
+
+		return lookup{product: product, span: querySpan.SpanContext()}, err
+	})
+
+	result, _ := v.(lookup)
+	if shared && !leader {
+		h.coalesced(ctx, span, result.span)
+	}
+
+	if !shared || err != nil {
+		return result.product, err //nolint:wrapcheck // wrapped by the caller
+	}
+
+	// callers may change the product, the leader and the followers get their own copy
+	return cloneProduct(result.product), nil
+}
+
+// coalesced links the span of the follower to the query span of the leader.
+func (h *Handler) coalesced(ctx context.Context, span trace.Span, query trace.SpanContext) {
+	span.AddLink(trace.Link{SpanContext: query})
+	span.SetAttributes(attribute.Bool("db.coalesced", true))
+
+	telemetry.GlobalMeter.CoalescedCounter.Add(ctx, 1, metric.WithAttributes(
+		tenant.Attributes(ctx, attribute.String("operation", "get_product"))...,
+	))
+}
+
+// cloneProduct copies the product with its slices and pointers.
+func cloneProduct(p *model.Product) *model.Product {
+	product := *p
+	product.Tags = slices.Clone(p.Tags)
+
+	if p.CategoryID != nil {
+		categoryID := *p.CategoryID
+		product.CategoryID = &categoryID
+	}
+
+	if p.Price != nil {
+		price := *p.Price
+		product.Price = &price
+	}
+
+	return &product
+}
@@ -1,13 +1,15 @@
 package handler
 
 import (
+	"context"
 	"net/http"
-	"strconv"
 
 	"github.com/labstack/echo/v4"
 
 	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/stream"
 	"github.com/worldline-go/telemetry_example/internal/telemetry"
+	"github.com/worldline-go/telemetry_example/internal/tenant"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -20,22 +22,28 @@ import (
 // @Description Get Count
 // @Produce     json
 // @Router      /count [get]
-// @Security    ApiKeyAuth
+// @Security    ApiKeyAuth || BearerAuth
 // @Success     200 {object} model.Message{}
-// @Failure     400 {object} model.Message{}
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
 func (h *Handler) GetCount(c echo.Context) error {
-	_, span := otel.GetTracerProvider().Tracer(c.Path()).Start(c.Request().Context(), "GetCount")
+	return c.JSON(http.StatusOK, model.Message{
+		Data: h.Count(c.Request().Context(), c.Path()),
+	})
+}
+
+// Count returns the current count, tracer is named with the scope like the route path.
+func (h *Handler) Count(ctx context.Context, scope string) int64 {
+	_, span := otel.GetTracerProvider().Tracer(scope).Start(ctx, "GetCount")
 	defer span.End()
 
 	count := h.Counter.Get()
 	// Store n as a string to not overflow an int64.
 	span.SetAttributes(attribute.Int64("request.count.get", count))
 
-	telemetry.GlobalMeter.UpDownCounter.Add(c.Request().Context(), 1, metric.WithAttributes(telemetry.GlobalAttr...))
+	telemetry.GlobalMeter.UpDownCounter.Add(ctx, 1, metric.WithAttributes(tenant.Attributes(ctx)...))
 
-	return c.JSON(http.StatusOK, model.Message{
-		Data: h.Counter.Get(),
-	})
+	return count
 }
 
 // PostCount
@@ -44,39 +52,46 @@ func (h *Handler) GetCount(c echo.Context) error {
 // @Description Add new count
 // @Produce     json
 // @Router      /count [post]
-// @Security    ApiKeyAuth
-// @Param       count query int false "Count Value"
+// @Security    ApiKeyAuth || BearerAuth
+// @Param       count query int false "Count Value" minimum(0) maximum(1000000)
+// @Param       Idempotency-Key header string false "Replays the first successful response of the key"
 // @Success     200 {object} model.Message{}
-// @Failure     400 {object} model.Message{}
+// @Failure     400 {object} model.Problem
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     409 {object} model.Problem
+// @Failure     422 {object} model.Problem
 func (h *Handler) PostCount(c echo.Context) error {
-	_, span := otel.GetTracerProvider().Tracer(c.Path()).Start(c.Request().Context(), "PostCount")
-	defer span.End()
+	var query model.Count
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &query); err != nil {
+		return err //nolint:wrapcheck // echo.HTTPError
+	}
 
-	countInt := int64(0)
-	count := c.QueryParam("count")
+	if err := c.Validate(&query); err != nil {
+		return err //nolint:wrapcheck // model.ValidationError
+	}
 
-	if count != "" {
-		var err error
+	return c.JSON(http.StatusOK, model.Message{
+		Data: h.AddCount(c.Request().Context(), c.Path(), query.Count),
+	})
+}
 
-		countInt, err = strconv.ParseInt(count, 10, 64)
-		if err != nil {
-			return c.JSON(http.StatusBadRequest, model.Message{
-				Message: err.Error(),
-			})
-		}
-	}
+// AddCount adds the validated count and returns the new value, tracer is named with the scope like the route path.
+func (h *Handler) AddCount(ctx context.Context, scope string, count int64) int64 {
+	_, span := otel.GetTracerProvider().Tracer(scope).Start(ctx, "PostCount")
+	defer span.End()
 
-	span.SetAttributes(attribute.Key("request.count.set").Int64(countInt))
+	span.SetAttributes(attribute.Key("request.count.set").Int64(count))
 
-	telemetry.GlobalMeter.SuccessCounter.Add(c.Request().Context(), 1, metric.WithAttributes(telemetry.GlobalAttr...))
-	telemetry.GlobalMeter.HistogramCounter.Record(c.Request().Context(), float64(countInt), metric.WithAttributes(telemetry.GlobalAttr...))
+	telemetry.GlobalMeter.SuccessCounter.Add(ctx, 1, metric.WithAttributes(tenant.Attributes(ctx)...))
+	telemetry.GlobalMeter.HistogramCounter.Record(ctx, float64(count), metric.WithAttributes(tenant.Attributes(ctx)...))
 
-	newResult := h.Counter.Add(countInt)
+	newResult := h.Counter.Add(count)
 	telemetry.WatchValue = newResult
 
-	telemetry.GlobalMeter.UpDownCounter.Add(c.Request().Context(), 1, metric.WithAttributes(telemetry.GlobalAttr...))
+	telemetry.GlobalMeter.UpDownCounter.Add(ctx, 1, metric.WithAttributes(tenant.Attributes(ctx)...))
 
-	return c.JSON(http.StatusOK, model.Message{
-		Data: newResult,
-	})
+	h.Streams.Publish(ctx, stream.TypeCount, stream.SourceLocal, "", newResult)
+
+	return newResult
 }
@@ -3,30 +3,93 @@ package handler
 import (
 	"github.com/labstack/echo/v4"
 	"github.com/twmb/franz-go/plugin/kotel"
-	"github.com/worldline-go/klient"
 	"github.com/worldline-go/wkafka"
+	"golang.org/x/sync/singleflight"
 
+	"github.com/worldline-go/telemetry_example/internal/cache"
+	"github.com/worldline-go/telemetry_example/internal/chaos"
 	"github.com/worldline-go/telemetry_example/internal/database/dbhandler"
 	"github.com/worldline-go/telemetry_example/internal/hold"
 	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/resilience"
+	"github.com/worldline-go/telemetry_example/internal/search"
+	"github.com/worldline-go/telemetry_example/internal/stream"
+	"github.com/worldline-go/telemetry_example/internal/webhook"
 )
 
 type Handler struct {
-	Counter       *hold.Counter
-	Clients       map[string]*klient.Client
+	Counter *hold.Counter
+	Clients *hold.Clients
+	Caller  *resilience.Caller
+	// Chaos is nil when it is not enabled.
+	Chaos         *chaos.Chaos
 	KafkaProducer *wkafka.Producer[*model.Product]
 	KafkaTracer   *kotel.Tracer
 	DB            *dbhandler.Handler
+	// Streams pushes the changes to the stream subscribers.
+	Streams *stream.Broker
+	// Webhooks is nil when it is not enabled.
+	Webhooks *webhook.Dispatcher
+	// Search finds the products, nil disables the search endpoint.
+	Search search.Searcher
+	// Cache of the product reads, nil reads the database.
+	Cache *cache.Products
+	// Idempotency replays the responses of the POST endpoints when set.
+	Idempotency echo.MiddlewareFunc
+	// GraphQL serves the /graphql endpoint when set.
+	GraphQL echo.HandlerFunc
+	// APIKeyHeader of the credentials, it is removed from the proxied requests.
+	APIKeyHeader string
+
+	// productLookups coalesces the concurrent reads of the same product.
+	productLookups singleflight.Group
 }
 
 func (h *Handler) Register(group *echo.Group) {
+	var idempotent []echo.MiddlewareFunc
+	if h.Idempotency != nil {
+		idempotent = append(idempotent, h.Idempotency)
+	}
+
 	group.GET("/count", h.GetCount)
-	group.POST("/count", h.PostCount)
+	group.POST("/count", h.PostCount, idempotent...)
 
+	group.POST("/call", h.CallChain)
 	group.POST("/call/:service", h.Call)
 	group.POST("/message", h.Message)
 
-	group.POST("/products", h.AddProduct)
+	group.Any("/proxy/:service/*", h.Proxy)
+
+	group.GET("/products", h.GetProducts)
+	group.POST("/products", h.AddProduct, idempotent...)
+	group.POST("/products\\:import", h.ImportProducts, idempotent...)
+	group.GET("/products\\:export", h.ExportProducts)
 	group.GET("/products/:name", h.GetProduct)
-	group.POST("/products-send/:name", h.SendProduct)
+	group.PUT("/products/:name", h.PutProduct)
+	group.DELETE("/products/:name", h.DeleteProduct)
+	group.POST("/products-send/:name", h.SendProduct, idempotent...)
+
+	group.POST("/categories", h.AddCategory)
+	group.GET("/categories", h.GetCategories)
+	group.GET("/categories/:id", h.GetCategory)
+	group.PUT("/categories/:id", h.UpdateCategory)
+	group.DELETE("/categories/:id", h.DeleteCategory)
+
+	group.GET("/stream", h.Stream)
+	group.GET("/stream/ws", h.StreamWS)
+
+	if h.Search != nil {
+		group.GET("/products\\:search", h.SearchProducts)
+	}
+
+	if h.Webhooks != nil {
+		group.POST("/webhooks", h.AddWebhook)
+		group.GET("/webhooks", h.GetWebhooks)
+		group.DELETE("/webhooks/:id", h.DeleteWebhook)
+		group.GET("/webhooks/:id/deliveries", h.GetWebhookDeliveries)
+	}
+
+	if h.GraphQL != nil {
+		group.POST("/graphql", h.GraphQL)
+	}
 }
@@ -2,53 +2,86 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/worldline-go/telemetry_example/internal/auth"
+	"github.com/worldline-go/telemetry_example/internal/chaos"
+	"github.com/worldline-go/telemetry_example/internal/config"
 	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/stream"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// defaultProductsLimit of the listing when the limit is not set.
+const defaultProductsLimit = 20
+
 // @Summary     Add new product
 // @Description Add new product
 // @Tags        products
 // @Accept      application/json
 // @Produce     application/json
 // @Param       product body model.Product true "Product to record"
+// @Param       Idempotency-Key header string false "Replays the first successful response of the key"
 // @Router      /products [POST]
+// @Security    ApiKeyAuth || BearerAuth
 // @Success     200 {object} model.Message{}
+// @Failure     400 {object} model.Problem
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     409 {object} model.Problem
+// @Failure     422 {object} model.Problem
 func (h *Handler) AddProduct(c echo.Context) error {
-	ctx := context.WithoutCancel(c.Request().Context())
-
 	var product model.Product
 	if err := c.Bind(&product); err != nil {
-		return c.JSON(http.StatusBadRequest, model.Message{
-			Message: err.Error(),
-		})
+		return err //nolint:wrapcheck // echo.HTTPError
 	}
 
-	ctx, span := otel.Tracer("").Start(ctx,
+	if err := c.Validate(&product); err != nil {
+		return err //nolint:wrapcheck // model.ValidationError
+	}
+
+	id, err := h.CreateProduct(c.Request().Context(), product)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, model.Message{
+		Message: "product added",
+		Data:    id,
+	})
+}
+
+// CreateProduct records the validated product, request cancellation doesn't stop it.
+func (h *Handler) CreateProduct(ctx context.Context, product model.Product) (int64, error) {
+	ctx, span := otel.Tracer("").Start(context.WithoutCancel(ctx),
 		"add_product",
 		trace.WithSpanKind(trace.SpanKindClient),
 		trace.WithAttributes(attribute.String("db.name", "postgres|products")),
 	)
 	defer span.End()
 
-	id, err := h.DB.AddNewProduct(ctx, product.Name, product.Description)
+	lastUser := auth.Subject(ctx, config.ServiceName)
+	span.SetAttributes(attribute.String("product.last_user", lastUser))
+
+	id, err := h.DB.AddNewProduct(ctx, product, lastUser)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, model.Message{
-			Message: err.Error(),
-		})
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, fmt.Errorf("failed to add product; %w", err)
 	}
 
-	return c.JSON(http.StatusOK, model.Message{
-		Message: "product added",
-		Data:    id,
-	})
+	product.ID = id
+	product.LastUser = lastUser
+
+	h.notify(ctx, stream.TypeProductCreated, &product)
+
+	return id, nil
 }
 
 // @Summary     Get product
@@ -57,54 +90,281 @@ func (h *Handler) AddProduct(c echo.Context) error {
 // @Produce     application/json
 // @Param       name path string true "Product name"
 // @Router      /products/{name} [GET]
+// @Security    ApiKeyAuth || BearerAuth
 // @Success     200 {object} model.Message{}
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     404 {object} model.Problem
 func (h *Handler) GetProduct(c echo.Context) error {
 	productName := c.Param("name")
 	if productName == "" {
-		return c.JSON(http.StatusBadRequest, model.Message{
-			Message: "name is required",
-		})
+		return echo.NewHTTPError(http.StatusBadRequest, "name is required")
 	}
 
-	ctx := context.WithoutCancel(c.Request().Context())
+	product, err := h.FindProduct(c.Request().Context(), productName)
+	if err != nil {
+		return err
+	}
 
-	ctx, span := otel.Tracer("").Start(ctx,
+	return c.JSON(http.StatusOK, model.Message{
+		Data: product,
+	})
+}
+
+// FindProduct returns the product with name, it is read through the cache when it is enabled.
+// Concurrent reads of the same product share one database query.
+func (h *Handler) FindProduct(ctx context.Context, name string) (*model.Product, error) {
+	ctx, span := otel.Tracer("").Start(context.WithoutCancel(ctx),
 		"get_product",
 		trace.WithSpanKind(trace.SpanKindClient),
 		trace.WithAttributes(attribute.String("db.name", "postgres|products")),
 	)
 	defer span.End()
 
-	product, err := h.DB.GetProduct(ctx, productName)
+	product, err := h.Cache.Get(ctx, name, func(ctx context.Context) (*model.Product, error) {
+		return h.getProduct(ctx, name)
+	})
+	if err != nil {
+		if !errors.Is(err, model.ErrNotFound) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		return nil, fmt.Errorf("failed to get product; %w", err)
+	}
+
+	return product, nil
+}
+
+// FindProducts returns the products with the names in one query, missing names are not in the map.
+func (h *Handler) FindProducts(ctx context.Context, names []string) (map[string]*model.Product, error) {
+	ctx, span := otel.Tracer("").Start(context.WithoutCancel(ctx),
+		"get_products",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.name", "postgres|products"),
+			attribute.Int("product.batch_size", len(names)),
+		),
+	)
+	defer span.End()
+
+	products, err := h.DB.GetProducts(ctx, names)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, fmt.Errorf("failed to get products; %w", err)
+	}
+
+	return products, nil
+}
+
+// @Summary     List products
+// @Description List products ordered by id, set after to the id of the last product to get the next page.
+// @Description Category matches its subcategories too, all tags should match and the price range needs the currency.
+// @Tags        products
+// @Produce     application/json
+// @Param       category  query int      false "Category id"
+// @Param       tag       query []string false "Tags" collectionFormat(multi)
+// @Param       currency  query string   false "Currency of the price like EUR"
+// @Param       min_price query int      false "Min price in minor units" minimum(0)
+// @Param       max_price query int      false "Max price in minor units" minimum(0)
+// @Param       after     query int      false "Id of the last product of the previous page" minimum(0)
+// @Param       limit     query int      false "Max products" minimum(1) maximum(100) default(20)
+// @Router      /products [GET]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} model.Message{data=[]model.Product}
+// @Failure     400 {object} model.Problem
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     422 {object} model.Problem
+func (h *Handler) GetProducts(c echo.Context) error {
+	var query model.ProductsQuery
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &query); err != nil {
+		return err //nolint:wrapcheck // echo.HTTPError
+	}
+
+	if err := c.Validate(&query); err != nil {
+		return err //nolint:wrapcheck // model.ValidationError
+	}
+
+	if query.Limit == 0 {
+		query.Limit = defaultProductsLimit
+	}
+
+	products, err := h.ListProducts(c.Request().Context(), query.ProductFilter, query.After, uint(query.Limit))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, model.Message{
-			Message: err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, model.Message{
-		Data: product,
+		Data: products,
+	})
+}
+
+// ListProducts returns at most limit products matching the filter after the id ordered by id.
+func (h *Handler) ListProducts(ctx context.Context, filter model.ProductFilter, afterID int64, limit uint) ([]model.Product, error) {
+	ctx, span := otel.Tracer("").Start(context.WithoutCancel(ctx),
+		"list_products",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.name", "postgres|products")),
+	)
+	defer span.End()
+
+	products, err := h.DB.ListProducts(ctx, filter, afterID, limit)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, fmt.Errorf("failed to list products; %w", err)
+	}
+
+	return products, nil
+}
+
+// @Summary     Update product
+// @Description Replace the description, category, tags and price of the product.
+// @Tags        products
+// @Accept      application/json
+// @Produce     application/json
+// @Param       name    path string        true "Product name"
+// @Param       product body model.Product true "Product fields, name is taken from the path"
+// @Router      /products/{name} [PUT]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} model.Message{data=model.Product}
+// @Failure     400 {object} model.Problem
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     404 {object} model.Problem
+// @Failure     422 {object} model.Problem
+func (h *Handler) PutProduct(c echo.Context) error {
+	var product model.Product
+	if err := c.Bind(&product); err != nil {
+		return err //nolint:wrapcheck // echo.HTTPError
+	}
+
+	product.Name = c.Param("name")
+
+	if err := c.Validate(&product); err != nil {
+		return err //nolint:wrapcheck // model.ValidationError
+	}
+
+	updated, err := h.UpdateProduct(c.Request().Context(), product)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, model.Message{
+		Message: "product updated",
+		Data:    updated,
+	})
+}
+
+// UpdateProduct replaces the description, category, tags and price of the validated product.
+func (h *Handler) UpdateProduct(ctx context.Context, product model.Product) (*model.Product, error) {
+	ctx, span := otel.Tracer("").Start(context.WithoutCancel(ctx),
+		"update_product",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.name", "postgres|products")),
+	)
+	defer span.End()
+
+	lastUser := auth.Subject(ctx, config.ServiceName)
+	span.SetAttributes(attribute.String("product.last_user", lastUser))
+
+	updated, err := h.DB.UpdateProduct(ctx, product, lastUser)
+	if err != nil {
+		if !errors.Is(err, model.ErrNotFound) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		return nil, fmt.Errorf("failed to update product; %w", err)
+	}
+
+	h.notify(ctx, stream.TypeProductUpdated, updated)
+
+	return updated, nil
+}
+
+// @Summary     Delete product
+// @Description Delete product with name
+// @Tags        products
+// @Produce     application/json
+// @Param       name path string true "Product name"
+// @Router      /products/{name} [DELETE]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} model.Message{data=model.Product}
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     404 {object} model.Problem
+func (h *Handler) DeleteProduct(c echo.Context) error {
+	deleted, err := h.RemoveProduct(c.Request().Context(), c.Param("name"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, model.Message{
+		Message: "product deleted",
+		Data:    deleted,
 	})
 }
 
+// RemoveProduct deletes the product with name and returns it.
+func (h *Handler) RemoveProduct(ctx context.Context, name string) (*model.Product, error) {
+	ctx, span := otel.Tracer("").Start(context.WithoutCancel(ctx),
+		"delete_product",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.name", "postgres|products")),
+	)
+	defer span.End()
+
+	deleted, err := h.DB.DeleteProduct(ctx, name)
+	if err != nil {
+		if !errors.Is(err, model.ErrNotFound) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		return nil, fmt.Errorf("failed to delete product; %w", err)
+	}
+
+	h.notify(ctx, stream.TypeProductDeleted, deleted)
+
+	return deleted, nil
+}
+
 // @Summary     Product to record kafka
 // @Tags        products
 // @Description Send product to kafka
 // @Accept      application/json
 // @Produce     application/json
 // @Param       name path string true "Product name"
+// @Param       Idempotency-Key header string false "Replays the first successful response of the key"
 // @Router      /products-send/{name} [POST]
+// @Security    ApiKeyAuth || BearerAuth
 // @Success     200 {object} model.Message{}
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     404 {object} model.Problem
+// @Failure     409 {object} model.Problem
+// @Failure     500 {object} model.Problem
 func (h *Handler) SendProduct(c echo.Context) error {
-	ctx := context.WithoutCancel(c.Request().Context())
-
 	name := c.Param("name")
 	if name == "" {
-		return c.JSON(http.StatusBadRequest, model.Message{
-			Message: "name is required",
-		})
+		return echo.NewHTTPError(http.StatusBadRequest, "name is required")
+	}
+
+	product, err := h.PublishProduct(c.Request().Context(), name)
+	if err != nil {
+		return err
 	}
 
+	return c.JSON(http.StatusOK, model.Message{
+		Message: "product sent",
+		Data:    product,
+	})
+}
+
+// PublishProduct gets the product with name and produces it to kafka.
+func (h *Handler) PublishProduct(ctx context.Context, name string) (*model.Product, error) {
+	ctx = context.WithoutCancel(ctx)
+
 	ctxDB, spanDB := otel.Tracer("").Start(ctx,
 		"get_product",
 		trace.WithSpanKind(trace.SpanKindClient),
@@ -116,9 +376,7 @@ func (h *Handler) SendProduct(c echo.Context) error {
 	if err != nil {
 		spanDB.SetStatus(codes.Error, err.Error())
 
-		return c.JSON(http.StatusBadRequest, model.Message{
-			Message: err.Error(),
-		})
+		return nil, fmt.Errorf("failed to get product; %w", err)
 	}
 
 	spanDB.End()
@@ -126,18 +384,19 @@ func (h *Handler) SendProduct(c echo.Context) error {
 	ctx, spanKafka := otel.Tracer("").Start(ctx, "produce_message", trace.WithSpanKind(trace.SpanKindProducer))
 	defer spanKafka.End()
 
-	time.Sleep(5 * time.Millisecond)
+	if err := chaos.Fail(ctx, chaos.KindKafka); err != nil {
+		spanKafka.SetStatus(codes.Error, err.Error())
+
+		return nil, fmt.Errorf("failed to produce product; %w", err)
+	}
 
 	if err := h.KafkaProducer.Produce(ctx, product); err != nil {
 		spanKafka.SetStatus(codes.Error, err.Error())
 
-		return c.JSON(http.StatusBadRequest, model.Message{
-			Message: err.Error(),
-		})
+		return nil, fmt.Errorf("failed to produce product; %w", err)
 	}
 
-	return c.JSON(http.StatusOK, model.Message{
-		Message: "product sent",
-		Data:    product,
-	})
+	h.notify(ctx, stream.TypeProductSent, product)
+
+	return product, nil
 }
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/resilience"
+	"github.com/worldline-go/telemetry_example/internal/telemetry"
+	"github.com/worldline-go/telemetry_example/internal/tenant"
+)
+
+// Proxy
+//
+// @Summary     Proxy to API
+// @Description Forward the request to the api with name, path is the absolute path in the api
+// @Tags        proxy
+// @Param       service path string true "service name"
+// @Param       path path string true "path in the service"
+// @Router      /proxy/{service}/{path} [GET]
+// @Router      /proxy/{service}/{path} [POST]
+// @Router      /proxy/{service}/{path} [PUT]
+// @Router      /proxy/{service}/{path} [PATCH]
+// @Router      /proxy/{service}/{path} [DELETE]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     404 {object} model.Problem
+// @Failure     502 {object} model.Problem
+// @Failure     503 {object} model.Problem
+func (h *Handler) Proxy(c echo.Context) error {
+	service := c.Param("service")
+
+	client, ok := h.Clients.Get(service)
+	if !ok {
+		return &model.Error{
+			Kind:   model.ErrNotFound,
+			Detail: "service [" + service + "] not found",
+		}
+	}
+
+	ctx, span := otel.Tracer("").Start(c.Request().Context(), "proxy "+service, trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	span.SetAttributes(attribute.String("request.proxy.service", service))
+
+	done, err := h.Caller.Breaker(service).Allow(ctx)
+	if err != nil {
+		return &model.Error{
+			Kind:   model.ErrUnavailable,
+			Detail: "service [" + service + "] circuit is open",
+			Cause:  err,
+		}
+	}
+
+	var (
+		status   int
+		proxyErr error
+		recorded bool
+	)
+
+	// the proxy panics with http.ErrAbortHandler when copying the body fails, the probe slot of the breaker is given back
+	defer func() {
+		if !recorded {
+			done(resilience.ResultIgnored)
+		}
+	}()
+
+	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+
+	proxy := &httputil.ReverseProxy{
+		// klient transport adds the base url and the configured headers
+		Transport: client.HTTP.Transport,
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL = &url.URL{
+				Path:     "/" + c.Param("*"),
+				RawQuery: r.In.URL.RawQuery,
+			}
+			r.Out.Host = ""
+			r.SetXForwarded()
+
+			// credentials of the caller are for this service, klient transport adds the ones of the api
+			r.Out.Header.Del(echo.HeaderAuthorization)
+			r.Out.Header.Del("Proxy-Authorization")
+			r.Out.Header.Del(echo.HeaderCookie)
+			if h.APIKeyHeader != "" {
+				r.Out.Header.Del(h.APIKeyHeader)
+			}
+
+			if requestID != "" {
+				r.Out.Header.Set(echo.HeaderXRequestID, requestID)
+			}
+
+			otel.GetTextMapPropagator().Inject(r.Out.Context(), propagation.HeaderCarrier(r.Out.Header))
+		},
+		ModifyResponse: func(r *http.Response) error {
+			status = r.StatusCode
+
+			// headers set by the middlewares like request id are not duplicated
+			for key := range c.Response().Header() {
+				r.Header.Del(key)
+			}
+
+			return nil
+		},
+		ErrorHandler: func(_ http.ResponseWriter, _ *http.Request, err error) {
+			proxyErr = err
+		},
+	}
+
+	start := time.Now()
+	proxy.ServeHTTP(c.Response(), c.Request().WithContext(ctx))
+	duration := time.Since(start)
+
+	recorded = true
+
+	// canceled requests are neither success nor failure of the service
+	switch {
+	case ctx.Err() != nil:
+		done(resilience.ResultIgnored)
+	case proxyErr != nil || status >= http.StatusInternalServerError:
+		done(resilience.ResultFailure)
+	default:
+		done(resilience.ResultSuccess)
+	}
+
+	statusAttr := "error"
+	if status != 0 {
+		statusAttr = strconv.Itoa(status)
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+	}
+
+	telemetry.GlobalMeter.ProxyDuration.Record(ctx, duration.Seconds(),
+		metric.WithAttributes(tenant.Attributes(ctx)...),
+		metric.WithAttributes(
+			attribute.String("service", service),
+			attribute.String("method", c.Request().Method),
+			attribute.String("status", statusAttr),
+		),
+	)
+
+	if proxyErr != nil {
+		span.SetStatus(codes.Error, proxyErr.Error())
+
+		return &model.UpstreamError{
+			Service: service,
+			Cause:   proxyErr,
+		}
+	}
+
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+// defaultSearchLimit of the results when the limit is not set.
+const defaultSearchLimit = 20
+
+// @Summary     Search products
+// @Description Search products with the words of the name and description, names also match with typos.
+// @Description Results are ordered by the rank, matched words are wrapped with <mark> tags in the highlights.
+// @Tags        products
+// @Produce     application/json
+// @Param       q     query string true  "Search text, quoted phrases and -word are supported with postgres" maxlength(255)
+// @Param       limit query int    false "Max results" minimum(1) maximum(100) default(20)
+// @Router      /products:search [GET]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} model.Message{data=[]model.SearchResult}
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     422 {object} model.Problem
+func (h *Handler) SearchProducts(c echo.Context) error {
+	var query model.SearchQuery
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &query); err != nil {
+		return err //nolint:wrapcheck // echo.HTTPError
+	}
+
+	if err := c.Validate(&query); err != nil {
+		return err //nolint:wrapcheck // model.ValidationError
+	}
+
+	if query.Limit == 0 {
+		query.Limit = defaultSearchLimit
+	}
+
+	ctx, span := otel.Tracer("").Start(c.Request().Context(),
+		"search_products",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("search.backend", h.Search.Backend()),
+			attribute.Int("search.limit", query.Limit),
+		),
+	)
+	defer span.End()
+
+	results, err := h.Search.Search(ctx, query.Q, query.Limit)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return fmt.Errorf("failed to search products; %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("search.results", len(results)))
+
+	if results == nil {
+		results = []model.SearchResult{}
+	}
+
+	return c.JSON(http.StatusOK, model.Message{
+		Data: results,
+	})
+}
@@ -0,0 +1,232 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/websocket"
+
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/ratelimit"
+	"github.com/worldline-go/telemetry_example/internal/stream"
+)
+
+// writeTimeout of a stream event, a client not reading is disconnected.
+var writeTimeout = 10 * time.Second
+
+// Stream
+//
+// @Summary     Stream of changes
+// @Description Server-sent events of the counter and product changes, the first event is the current count.
+// @Description Events are "count", "product.created", "product.updated", "product.deleted", "product.sent" and "product.received" from kafka.
+// @Tags        stream
+// @Produce     text/event-stream
+// @Param       type query []string false "Event types, product matches all product events" collectionFormat(multi)
+// @Param       name query []string false "Product names" collectionFormat(multi)
+// @Router      /stream [GET]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} model.Event
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     422 {object} model.Problem
+// @Failure     429 {object} model.Problem
+func (h *Handler) Stream(c echo.Context) error {
+	subscriber, err := h.subscribe(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	defer h.Streams.Unsubscribe(ctx, subscriber)
+
+	// streams are limited with the subscribers, not with the concurrency of the requests
+	ratelimit.Release(ctx)
+
+	w := c.Response()
+	controller := http.NewResponseController(w)
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.Header().Set(echo.HeaderConnection, "keep-alive")
+	// nginx buffers the responses without it
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// a client not reading is disconnected, writers without deadline support are used without it
+	deadline := func() error {
+		if err := controller.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err //nolint:wrapcheck // client is gone
+		}
+
+		return nil
+	}
+
+	send := func(event model.Event) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event; %w", err)
+		}
+
+		if err := deadline(); err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+			return err //nolint:wrapcheck // client is gone
+		}
+
+		w.Flush()
+
+		return nil
+	}
+
+	heartbeat := func() error {
+		if err := deadline(); err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+			return err //nolint:wrapcheck // client is gone
+		}
+
+		w.Flush()
+
+		return nil
+	}
+
+	h.pushEvents(ctx, subscriber, send, heartbeat)
+
+	return nil
+}
+
+// StreamWS
+//
+// @Summary     Stream of changes with websocket
+// @Description Same events of the /stream endpoint as JSON messages, heartbeat is a message with "heartbeat" type.
+// @Tags        stream
+// @Param       type query []string false "Event types, product matches all product events" collectionFormat(multi)
+// @Param       name query []string false "Product names" collectionFormat(multi)
+// @Router      /stream/ws [GET]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     101 {object} model.Event
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     422 {object} model.Problem
+// @Failure     429 {object} model.Problem
+func (h *Handler) StreamWS(c echo.Context) error {
+	subscriber, err := h.subscribe(c)
+	if err != nil {
+		return err
+	}
+
+	defer h.Streams.Unsubscribe(c.Request().Context(), subscriber)
+
+	ratelimit.Release(c.Request().Context())
+
+	// Server doesn't check the origin like websocket.Handler, authentication is done with the headers.
+	websocket.Server{Handler: func(conn *websocket.Conn) {
+		ctx, cancel := context.WithCancel(c.Request().Context())
+		defer cancel()
+
+		// messages of the client are not used, reading detects the closed connection
+		go func() {
+			defer cancel()
+
+			var msg string
+			for websocket.Message.Receive(conn, &msg) == nil {
+			}
+		}()
+
+		send := func(event model.Event) error {
+			if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+				return err //nolint:wrapcheck // client is gone
+			}
+
+			return websocket.JSON.Send(conn, event) //nolint:wrapcheck // client is gone
+		}
+
+		heartbeat := func() error {
+			return send(model.Event{Type: "heartbeat", Time: time.Now()})
+		}
+
+		h.pushEvents(ctx, subscriber, send, heartbeat)
+	}}.ServeHTTP(c.Response(), c.Request())
+
+	return nil
+}
+
+// subscribe validates the filter of the query, type and name values can be repeated or comma separated.
+func (h *Handler) subscribe(c echo.Context) (*stream.Subscriber, error) {
+	var filter model.StreamFilter
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, &filter); err != nil {
+		return nil, err //nolint:wrapcheck // echo.HTTPError
+	}
+
+	filter.Types = splitValues(filter.Types)
+	filter.Names = splitValues(filter.Names)
+
+	if err := c.Validate(&filter); err != nil {
+		return nil, err //nolint:wrapcheck // model.ValidationError
+	}
+
+	return h.Streams.Subscribe(c.Request().Context(), filter) //nolint:wrapcheck // model.Error
+}
+
+// pushEvents writes the current count and the events until the client or the subscriber is done.
+func (h *Handler) pushEvents(ctx context.Context, subscriber *stream.Subscriber, send func(model.Event) error, heartbeat func() error) {
+	logger := log.Ctx(ctx)
+
+	if subscriber.Match(stream.TypeCount, "") {
+		if err := send(model.Event{
+			Type:   stream.TypeCount,
+			Source: stream.SourceLocal,
+			Data:   h.Counter.Get(),
+			Time:   time.Now(),
+		}); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(h.Streams.Heartbeat())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-subscriber.Done():
+			if err := subscriber.Err(); errors.Is(err, stream.ErrSlowSubscriber) {
+				logger.Warn().Err(err).Msg("stream closed")
+			}
+
+			return
+		case event := <-subscriber.Events():
+			if err := send(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := heartbeat(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func splitValues(values []string) []string {
+	var result []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+
+	return result
+}
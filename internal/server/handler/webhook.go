@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/auth"
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/stream"
+	"github.com/worldline-go/telemetry_example/internal/webhook"
+)
+
+// maxDeliveries returned by the deliveries endpoint.
+const maxDeliveries = 100
+
+// @Summary     Add webhook
+// @Description Register the url for the product events, it must resolve to public addresses. Secret is generated when empty and only returned here.
+// @Tags        webhooks
+// @Accept      application/json
+// @Produce     application/json
+// @Param       webhook body model.Webhook true "Webhook to record"
+// @Router      /webhooks [POST]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} model.Message{data=model.Webhook}
+// @Failure     400 {object} model.Problem
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     422 {object} model.Problem
+func (h *Handler) AddWebhook(c echo.Context) error {
+	var w model.Webhook
+	if err := c.Bind(&w); err != nil {
+		return err //nolint:wrapcheck // echo.HTTPError
+	}
+
+	if err := c.Validate(&w); err != nil {
+		return err //nolint:wrapcheck // model.ValidationError
+	}
+
+	if err := h.Webhooks.CheckURL(c.Request().Context(), w.URL); err != nil {
+		return err //nolint:wrapcheck // model.ValidationError
+	}
+
+	if w.Secret == "" {
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			return err //nolint:wrapcheck // internal error
+		}
+
+		w.Secret = secret
+	}
+
+	ctx, span := otel.Tracer("").Start(context.WithoutCancel(c.Request().Context()),
+		"add_webhook",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.name", "postgres|webhooks")),
+	)
+	defer span.End()
+
+	w.LastUser = auth.Subject(ctx, config.ServiceName)
+
+	// the secret is returned once, only the encrypted one is stored
+	stored := w
+	sealed, err := h.Webhooks.Seal(w.Secret)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return err //nolint:wrapcheck // internal error
+	}
+
+	stored.Secret = sealed
+
+	id, err := h.DB.AddWebhook(ctx, stored)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return fmt.Errorf("failed to add webhook; %w", err)
+	}
+
+	w.ID = id
+
+	return c.JSON(http.StatusOK, model.Message{
+		Message: "webhook added",
+		Data:    w,
+	})
+}
+
+// @Summary     List webhooks
+// @Description List webhooks without their secrets
+// @Tags        webhooks
+// @Produce     application/json
+// @Router      /webhooks [GET]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} model.Message{data=[]model.Webhook}
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+func (h *Handler) GetWebhooks(c echo.Context) error {
+	ctx, span := otel.Tracer("").Start(c.Request().Context(),
+		"get_webhooks",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.name", "postgres|webhooks")),
+	)
+	defer span.End()
+
+	webhooks, err := h.DB.GetWebhooks(ctx)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return fmt.Errorf("failed to get webhooks; %w", err)
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	return c.JSON(http.StatusOK, model.Message{
+		Data: webhooks,
+	})
+}
+
+// @Summary     Delete webhook
+// @Description Delete webhook with its deliveries
+// @Tags        webhooks
+// @Produce     application/json
+// @Param       id path int true "Webhook id"
+// @Router      /webhooks/{id} [DELETE]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} model.Message{}
+// @Failure     400 {object} model.Problem
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+// @Failure     404 {object} model.Problem
+func (h *Handler) DeleteWebhook(c echo.Context) error {
+	id, err := pathID(c)
+	if err != nil {
+		return err
+	}
+
+	ctx, span := otel.Tracer("").Start(context.WithoutCancel(c.Request().Context()),
+		"delete_webhook",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.name", "postgres|webhooks")),
+	)
+	defer span.End()
+
+	if err := h.DB.DeleteWebhook(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete webhook; %w", err)
+	}
+
+	return c.JSON(http.StatusOK, model.Message{
+		Message: "webhook deleted",
+	})
+}
+
+// @Summary     Webhook deliveries
+// @Description Latest delivery attempts of the webhook with status and latency
+// @Tags        webhooks
+// @Produce     application/json
+// @Param       id path int true "Webhook id"
+// @Router      /webhooks/{id}/deliveries [GET]
+// @Security    ApiKeyAuth || BearerAuth
+// @Success     200 {object} model.Message{data=[]model.WebhookDelivery}
+// @Failure     400 {object} model.Problem
+// @Failure     401 {object} model.Problem
+// @Failure     403 {object} model.Problem
+func (h *Handler) GetWebhookDeliveries(c echo.Context) error {
+	id, err := pathID(c)
+	if err != nil {
+		return err
+	}
+
+	ctx, span := otel.Tracer("").Start(c.Request().Context(),
+		"get_webhook_deliveries",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.name", "postgres|webhook_deliveries")),
+	)
+	defer span.End()
+
+	deliveries, err := h.DB.GetWebhookDeliveries(ctx, id, maxDeliveries)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return fmt.Errorf("failed to get webhook deliveries; %w", err)
+	}
+
+	return c.JSON(http.StatusOK, model.Message{
+		Data: deliveries,
+	})
+}
+
+// pathID returns the numeric id path parameter.
+func pathID(c echo.Context) (int64, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "id must be a number")
+	}
+
+	return id, nil
+}
+
+// notify pushes the product change to the stream subscribers, the webhooks, the search index and the cache.
+func (h *Handler) notify(ctx context.Context, typ string, product *model.Product) {
+	if typ != stream.TypeProductSent {
+		h.Cache.Invalidate(ctx, product.Name)
+	}
+
+	if h.Search != nil {
+		if typ == stream.TypeProductDeleted {
+			h.Search.Remove(ctx, product.Name)
+		} else {
+			h.Search.Index(ctx, product)
+		}
+	}
+
+	h.Streams.Publish(ctx, typ, stream.SourceLocal, product.Name, product)
+	h.Webhooks.Notify(ctx, typ, product)
+}
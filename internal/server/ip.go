@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net"
+
+	"github.com/labstack/echo/v4"
+)
+
+// IPExtractor returns the client IP of the requests for the rate limits and logs.
+// X-Forwarded-For is only read from the trusted proxies, clients could send any value in it.
+func IPExtractor(trustedProxies []string) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+
+	for _, proxy := range trustedProxies {
+		// ranges are validated with the config
+		if _, ipNet, err := net.ParseCIDR(proxy); err == nil {
+			options = append(options, echo.TrustIPRange(ipNet))
+		}
+	}
+
+	return echo.ExtractIPFromXFFHeader(options...)
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/ratelimit"
+	"github.com/worldline-go/telemetry_example/internal/telemetry"
+)
+
+func TestIPExtractor(t *testing.T) {
+	if err := telemetry.SetGlobalMeter(); err != nil {
+		t.Fatal(err)
+	}
+
+	limiter, err := ratelimit.New(config.RateLimit{Rules: []config.RateLimitRule{
+		{KeyBy: ratelimit.KeyByIP, Rate: 0.001, Burst: 1},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		// forwarded headers of the requests, the second one is throttled when they are the same client
+		forwarded [2]string
+		want      [2]int
+	}{
+		{
+			name:       "spoofed forwarded header",
+			remoteAddr: "192.0.2.10:4000",
+			forwarded:  [2]string{"198.51.100.1", "198.51.100.2"},
+			want:       [2]int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:           "trusted proxy",
+			trustedProxies: []string{"192.0.2.0/24"},
+			remoteAddr:     "192.0.2.20:4000",
+			forwarded:      [2]string{"198.51.100.3", "198.51.100.4"},
+			want:           [2]int{http.StatusOK, http.StatusOK},
+		},
+		{
+			name:           "spoofed forwarded header with a trusted proxy",
+			trustedProxies: []string{"192.0.2.0/24"},
+			remoteAddr:     "203.0.113.30:4000",
+			forwarded:      [2]string{"198.51.100.5", "198.51.100.6"},
+			want:           [2]int{http.StatusOK, http.StatusTooManyRequests},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.HTTPErrorHandler = HTTPErrorHandler
+			e.IPExtractor = IPExtractor(tt.trustedProxies)
+			e.GET("/ip", func(c echo.Context) error {
+				return c.String(http.StatusOK, c.RealIP())
+			}, limiter.Middleware())
+
+			for i, forwarded := range tt.forwarded {
+				req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+				req.RemoteAddr = tt.remoteAddr
+				req.Header.Set(echo.HeaderXForwardedFor, forwarded)
+
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+
+				if rec.Code != tt.want[i] {
+					t.Fatalf("request %d: want status %d, got %d", i, tt.want[i], rec.Code)
+				}
+			}
+		})
+	}
+}
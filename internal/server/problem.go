@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+const (
+	MIMEApplicationProblemJSON = "application/problem+json"
+
+	problemTypePrefix = "urn:telemetry:problem:"
+
+	ProblemTypeValidation = problemTypePrefix + "validation"
+	ProblemTypeNotFound   = problemTypePrefix + "not-found"
+	ProblemTypeDuplicate  = problemTypePrefix + "duplicate"
+	ProblemTypeConflict   = problemTypePrefix + "conflict"
+	ProblemTypeUpstream   = problemTypePrefix + "upstream"
+	ProblemTypeAuth       = problemTypePrefix + "unauthorized"
+	ProblemTypeForbidden  = problemTypePrefix + "forbidden"
+	ProblemTypeInternal   = problemTypePrefix + "internal"
+
+	ProblemTypeTooManyRequests = problemTypePrefix + "too-many-requests"
+	ProblemTypeUnavailable     = problemTypePrefix + "unavailable"
+)
+
+// problemKinds maps domain error kinds to the problem responses.
+var problemKinds = []struct {
+	err    error
+	typ    string
+	title  string
+	status int
+}{
+	{model.ErrNotFound, ProblemTypeNotFound, "Not found", http.StatusNotFound},
+	{model.ErrDuplicate, ProblemTypeDuplicate, "Duplicate record", http.StatusConflict},
+	{model.ErrConflict, ProblemTypeConflict, "Conflict", http.StatusConflict},
+	{model.ErrUnauthorized, ProblemTypeAuth, "Unauthorized", http.StatusUnauthorized},
+	{model.ErrForbidden, ProblemTypeForbidden, "Forbidden", http.StatusForbidden},
+	{model.ErrTooManyRequests, ProblemTypeTooManyRequests, "Too many requests", http.StatusTooManyRequests},
+	{model.ErrUnavailable, ProblemTypeUnavailable, "Service unavailable", http.StatusServiceUnavailable},
+}
+
+// HTTPErrorHandler renders errors as application/problem+json.
+//
+// Domain errors are mapped to their status codes, unknown errors are hidden
+// behind a generic 500 and only visible in logs and traces.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	problem := NewProblem(err)
+
+	spanCtx := trace.SpanContextFromContext(c.Request().Context())
+	if spanCtx.HasTraceID() {
+		problem.TraceID = spanCtx.TraceID().String()
+	}
+
+	if spanCtx.HasSpanID() {
+		problem.SpanID = spanCtx.SpanID().String()
+	}
+
+	problem.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+	if problem.RequestID == "" {
+		problem.RequestID = c.Request().Header.Get(echo.HeaderXRequestID)
+	}
+
+	problem.Instance = c.Request().URL.Path
+
+	if problem.Status >= http.StatusInternalServerError {
+		log.Ctx(c.Request().Context()).Error().Err(err).
+			Str("trace_id", problem.TraceID).
+			Str("request_id", problem.RequestID).
+			Msg("request failed")
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(problem.Status)
+	} else {
+		err = writeProblem(c, problem)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("failed to write error response")
+	}
+}
+
+// NewProblem maps the error to the problem without request specific fields.
+func NewProblem(err error) *model.Problem {
+	var (
+		vErr        *model.ValidationError
+		domainErr   *model.Error
+		upstreamErr *model.UpstreamError
+		httpErr     *echo.HTTPError
+	)
+
+	switch {
+	case errors.As(err, &vErr):
+		return &model.Problem{
+			Type:   ProblemTypeValidation,
+			Title:  "Validation failed",
+			Status: http.StatusUnprocessableEntity,
+			Detail: "request has invalid fields",
+			Errors: vErr.Fields,
+		}
+	case errors.As(err, &upstreamErr):
+		return &model.Problem{
+			Type:   ProblemTypeUpstream,
+			Title:  "Upstream failure",
+			Status: http.StatusBadGateway,
+			Detail: upstreamErr.Detail(),
+		}
+	case errors.As(err, &domainErr):
+		for _, kind := range problemKinds {
+			if errors.Is(domainErr.Kind, kind.err) {
+				return &model.Problem{
+					Type:   kind.typ,
+					Title:  kind.title,
+					Status: kind.status,
+					Detail: domainErr.Detail,
+				}
+			}
+		}
+	case errors.As(err, &httpErr):
+		problem := &model.Problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(httpErr.Code),
+			Status: httpErr.Code,
+		}
+
+		if httpErr.Code < http.StatusInternalServerError {
+			problem.Detail = fmt.Sprint(httpErr.Message)
+		}
+
+		return problem
+	}
+
+	return &model.Problem{
+		Type:   ProblemTypeInternal,
+		Title:  http.StatusText(http.StatusInternalServerError),
+		Status: http.StatusInternalServerError,
+		Detail: "internal server error",
+	}
+}
+
+func writeProblem(c echo.Context, problem *model.Problem) error {
+	c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+	c.Response().WriteHeader(problem.Status)
+
+	return json.NewEncoder(c.Response()).Encode(problem) //nolint:wrapcheck // no need
+}
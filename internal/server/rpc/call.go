@@ -0,0 +1,98 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/types/known/structpb"
+
+	telemetryv1 "github.com/worldline-go/telemetry_example/api/telemetry/v1"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/server/handler"
+	"github.com/worldline-go/telemetry_example/internal/util"
+)
+
+type callServer struct {
+	telemetryv1.UnimplementedCallServiceServer
+
+	h         *handler.Handler
+	validator *util.Validator
+}
+
+func (s *callServer) Call(ctx context.Context, req *telemetryv1.CallRequest) (*telemetryv1.CallResponse, error) {
+	body := model.Service{
+		Message: req.GetMessage(),
+		Error:   req.GetError(),
+		Chain:   toSteps(req.GetChain()),
+	}
+
+	if err := s.validator.Validate(&body); err != nil {
+		return nil, err //nolint:wrapcheck // model.ValidationError
+	}
+
+	steps := body.Chain
+	if req.GetService() != "" {
+		steps = append([]model.Step{{Service: req.GetService()}}, steps...)
+	}
+
+	if len(steps) == 0 {
+		return nil, &model.ValidationError{Fields: []model.FieldError{{
+			Field:   "chain",
+			Rule:    "required",
+			Message: "service or chain is required",
+		}}}
+	}
+
+	response, err := s.h.Chain(ctx, body, steps)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // converted to status
+	}
+
+	data, err := toValue(response.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &telemetryv1.CallResponse{
+		Message: response.Message,
+		Data:    data,
+	}, nil
+}
+
+func toSteps(steps []*telemetryv1.Step) []model.Step {
+	if len(steps) == 0 {
+		return nil
+	}
+
+	result := make([]model.Step, 0, len(steps))
+	for _, step := range steps {
+		s := model.Step{Service: step.GetService()}
+		for _, chain := range step.GetParallel() {
+			s.Parallel = append(s.Parallel, toSteps(chain.GetSteps()))
+		}
+
+		result = append(result, s)
+	}
+
+	return result
+}
+
+// toValue converts the data with its JSON form.
+func toValue(v any) (*structpb.Value, error) {
+	if v == nil {
+		return nil, nil //nolint:nilnil // no data
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data; %w", err)
+	}
+
+	value := &structpb.Value{}
+	if err := value.UnmarshalJSON(raw); err != nil {
+		return nil, fmt.Errorf("failed to convert data; %w", err)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,30 @@
+package rpc
+
+import (
+	"context"
+
+	telemetryv1 "github.com/worldline-go/telemetry_example/api/telemetry/v1"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/server/handler"
+	"github.com/worldline-go/telemetry_example/internal/util"
+)
+
+type counterServer struct {
+	telemetryv1.UnimplementedCounterServiceServer
+
+	h         *handler.Handler
+	validator *util.Validator
+}
+
+func (s *counterServer) GetCount(ctx context.Context, _ *telemetryv1.GetCountRequest) (*telemetryv1.GetCountResponse, error) {
+	return &telemetryv1.GetCountResponse{Count: s.h.Count(ctx, telemetryv1.CounterService_GetCount_FullMethodName)}, nil
+}
+
+func (s *counterServer) AddCount(ctx context.Context, req *telemetryv1.AddCountRequest) (*telemetryv1.AddCountResponse, error) {
+	query := model.Count{Count: req.GetCount()}
+	if err := s.validator.Validate(&query); err != nil {
+		return nil, err //nolint:wrapcheck // model.ValidationError
+	}
+
+	return &telemetryv1.AddCountResponse{Count: s.h.AddCount(ctx, telemetryv1.CounterService_AddCount_FullMethodName, query.Count)}, nil
+}
@@ -0,0 +1,219 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	telemetryv1 "github.com/worldline-go/telemetry_example/api/telemetry/v1"
+	"github.com/worldline-go/telemetry_example/internal/auth"
+	"github.com/worldline-go/telemetry_example/internal/chaos"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/ratelimit"
+	"github.com/worldline-go/telemetry_example/internal/tenant"
+)
+
+// RouteMethod is the method of the gRPC calls in auth route scopes like "GRPC /telemetry.v1.CounterService/AddCount".
+const RouteMethod = "GRPC"
+
+// routes are the http routes of the calls, rate limit and chaos rules of the routes are used.
+var routes = map[string]string{
+	telemetryv1.CounterService_GetCount_FullMethodName:    "/api/v1/count",
+	telemetryv1.CounterService_AddCount_FullMethodName:    "/api/v1/count",
+	telemetryv1.ProductService_AddProduct_FullMethodName:  "/api/v1/products",
+	telemetryv1.ProductService_GetProduct_FullMethodName:  "/api/v1/products/:name",
+	telemetryv1.ProductService_SendProduct_FullMethodName: "/api/v1/products-send/:name",
+	telemetryv1.CallService_Call_FullMethodName:           "/api/v1/call/:service",
+}
+
+// route returns the http route of the call, full method for the others.
+func route(fullMethod string) string {
+	if r, ok := routes[fullMethod]; ok {
+		return r
+	}
+
+	return fullMethod
+}
+
+// recoverer returns internal error when the handler panics.
+func recoverer() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Ctx(ctx).Error().Str("grpc_method", info.FullMethod).
+					Interface("panic", r).Bytes("stack", debug.Stack()).
+					Msg("grpc panic recovered")
+
+				err = status.Error(codes.Internal, "internal server error")
+			}
+		}()
+
+		return next(ctx, req)
+	}
+}
+
+// logger adds the request logger to the context and converts the errors to status.
+func logger() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+		logCtx := log.Logger.With().Str("grpc_method", info.FullMethod)
+		if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+			logCtx = logCtx.Str("trace_id", spanCtx.TraceID().String())
+		}
+
+		l := logCtx.Logger()
+		ctx = l.WithContext(ctx)
+
+		resp, err := next(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+
+		st := Status(err)
+
+		switch st.Code() {
+		case codes.Internal, codes.Unknown, codes.Unavailable, codes.DataLoss:
+			l.Error().Err(err).Str("code", st.Code().String()).Msg("request failed")
+		}
+
+		return nil, st.Err()
+	}
+}
+
+// peerIP returns the address of the connection, metadata is not used like X-Forwarded-For.
+func peerIP(ctx context.Context) string {
+	var ip string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ip, _, _ = net.SplitHostPort(p.Addr.String())
+	}
+
+	return ip
+}
+
+// limitAuthFailures rejects the clients with too many failed authentications like the http middleware.
+func limitAuthFailures(limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+		if public(info.FullMethod) {
+			return next(ctx, req)
+		}
+
+		ip := peerIP(ctx)
+
+		if wait, err := limiter.CheckFailures(ctx, ip); err != nil {
+			_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(ratelimit.RetryAfter(wait))))
+
+			return nil, err //nolint:wrapcheck // converted to status
+		}
+
+		resp, err := next(ctx, req)
+		if errors.Is(err, model.ErrUnauthorized) {
+			limiter.Failed(ip)
+		}
+
+		return resp, err
+	}
+}
+
+// authenticate checks the credentials in the metadata like http headers.
+func authenticate(a *auth.Auth) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+		if public(info.FullMethod) {
+			return next(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		principal, err := a.Check(ctx, RouteMethod+" "+info.FullMethod, func(key string) string {
+			if values := md.Get(key); len(values) > 0 {
+				return values[0]
+			}
+
+			return ""
+		})
+		if err != nil {
+			return nil, err //nolint:wrapcheck // converted to status
+		}
+
+		return next(auth.WithPrincipal(ctx, principal), req)
+	}
+}
+
+// resolveTenant adds the tenant of the metadata to the context like the http header.
+func resolveTenant(tenants *tenant.Registry) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+		if public(info.FullMethod) {
+			return next(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		ctx, err := tenants.Resolve(ctx, func(key string) string {
+			if values := md.Get(key); len(values) > 0 {
+				return values[0]
+			}
+
+			return ""
+		})
+		if err != nil {
+			return nil, err //nolint:wrapcheck // converted to status
+		}
+
+		return next(ctx, req)
+	}
+}
+
+// rateLimit applies the rate limit rules of the http route, retry-after header is set to the throttled calls.
+func rateLimit(limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+		if public(info.FullMethod) {
+			return next(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		release, wait, err := limiter.Acquire(ctx, ratelimit.Request{
+			Route: route(info.FullMethod),
+			IP:    peerIP(ctx),
+			Header: func(key string) string {
+				if values := md.Get(key); len(values) > 0 {
+					return values[0]
+				}
+
+				return ""
+			},
+		})
+		if err != nil {
+			_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(ratelimit.RetryAfter(wait))))
+
+			return nil, err //nolint:wrapcheck // converted to status
+		}
+		defer release()
+
+		return next(ctx, req)
+	}
+}
+
+// injectFault applies the chaos rules of the http route, faults of the requests are not read from the metadata.
+func injectFault(c *chaos.Chaos) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+		if public(info.FullMethod) {
+			return next(ctx, req)
+		}
+
+		ctx, err := c.InjectRoute(ctx, route(info.FullMethod))
+		if err != nil {
+			return nil, err //nolint:wrapcheck // converted to status
+		}
+
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,80 @@
+package rpc
+
+import (
+	"context"
+
+	telemetryv1 "github.com/worldline-go/telemetry_example/api/telemetry/v1"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/server/handler"
+	"github.com/worldline-go/telemetry_example/internal/util"
+)
+
+type productServer struct {
+	telemetryv1.UnimplementedProductServiceServer
+
+	h         *handler.Handler
+	validator *util.Validator
+}
+
+func (s *productServer) AddProduct(ctx context.Context, req *telemetryv1.AddProductRequest) (*telemetryv1.AddProductResponse, error) {
+	product := model.Product{
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+	}
+
+	if err := s.validator.Validate(&product); err != nil {
+		return nil, err //nolint:wrapcheck // model.ValidationError
+	}
+
+	id, err := s.h.CreateProduct(ctx, product)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // converted to status
+	}
+
+	return &telemetryv1.AddProductResponse{Id: id}, nil
+}
+
+func (s *productServer) GetProduct(ctx context.Context, req *telemetryv1.GetProductRequest) (*telemetryv1.GetProductResponse, error) {
+	if req.GetName() == "" {
+		return nil, requiredName()
+	}
+
+	product, err := s.h.FindProduct(ctx, req.GetName())
+	if err != nil {
+		return nil, err //nolint:wrapcheck // converted to status
+	}
+
+	return &telemetryv1.GetProductResponse{Product: toProduct(product)}, nil
+}
+
+func (s *productServer) SendProduct(ctx context.Context, req *telemetryv1.SendProductRequest) (*telemetryv1.SendProductResponse, error) {
+	if req.GetName() == "" {
+		return nil, requiredName()
+	}
+
+	product, err := s.h.PublishProduct(ctx, req.GetName())
+	if err != nil {
+		return nil, err //nolint:wrapcheck // converted to status
+	}
+
+	return &telemetryv1.SendProductResponse{Product: toProduct(product)}, nil
+}
+
+func requiredName() error {
+	return &model.ValidationError{Fields: []model.FieldError{{
+		Field:   "name",
+		Rule:    "required",
+		Message: "name is required",
+	}}}
+}
+
+func toProduct(p *model.Product) *telemetryv1.Product {
+	return &telemetryv1.Product{
+		Id:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		LastUser:    p.LastUser,
+		UpdatedAt:   p.UpdatedAt,
+		CreatedAt:   p.CreatedAt,
+	}
+}
@@ -0,0 +1,141 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	telemetryv1 "github.com/worldline-go/telemetry_example/api/telemetry/v1"
+	"github.com/worldline-go/telemetry_example/internal/auth"
+	"github.com/worldline-go/telemetry_example/internal/chaos"
+	"github.com/worldline-go/telemetry_example/internal/ratelimit"
+	"github.com/worldline-go/telemetry_example/internal/server/handler"
+	"github.com/worldline-go/telemetry_example/internal/tenant"
+	"github.com/worldline-go/telemetry_example/internal/util"
+)
+
+var shutdownTimeout = 5 * time.Second
+
+type Settings struct {
+	// Addr like 0.0.0.0:9090
+	Addr string
+	// Auth checks the calls except health and reflection when set.
+	Auth *auth.Auth
+	// Tenants resolves the tenant of the calls except health and reflection when set.
+	Tenants *tenant.Registry
+	// Limiter applies the rate limits of the http routes when set.
+	Limiter *ratelimit.Limiter
+	// Chaos injects the faults of the http routes when set.
+	Chaos *chaos.Chaos
+}
+
+// Server is the gRPC api with the same handler of the http api.
+type Server struct {
+	server *grpc.Server
+	health *health.Server
+	addr   string
+}
+
+func NewServer(settings Settings, h *handler.Handler) *Server {
+	interceptors := []grpc.UnaryServerInterceptor{
+		recoverer(),
+		logger(),
+	}
+
+	if settings.Auth != nil {
+		if settings.Limiter != nil {
+			interceptors = append(interceptors, limitAuthFailures(settings.Limiter))
+		}
+
+		interceptors = append(interceptors, authenticate(settings.Auth))
+	}
+
+	if settings.Limiter != nil {
+		interceptors = append(interceptors, rateLimit(settings.Limiter))
+	}
+
+	if settings.Tenants != nil {
+		interceptors = append(interceptors, resolveTenant(settings.Tenants))
+	}
+
+	if settings.Chaos != nil {
+		interceptors = append(interceptors, injectFault(settings.Chaos))
+	}
+
+	s := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(interceptors...),
+	)
+
+	validator := util.NewValidator()
+
+	telemetryv1.RegisterCounterServiceServer(s, &counterServer{h: h, validator: validator})
+	telemetryv1.RegisterProductServiceServer(s, &productServer{h: h, validator: validator})
+	telemetryv1.RegisterCallServiceServer(s, &callServer{h: h, validator: validator})
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
+
+	reflection.Register(s)
+
+	return &Server{
+		server: s,
+		health: healthServer,
+		addr:   settings.Addr,
+	}
+}
+
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err //nolint:wrapcheck // no need
+	}
+
+	log.Info().Str("address", listener.Addr().String()).Msg("grpc server started")
+
+	return s.server.Serve(listener) //nolint:wrapcheck // no need
+}
+
+func (s *Server) StopWithContext(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		<-ctx.Done()
+
+		s.Stop()
+	}()
+}
+
+// Stop waits the running calls until the shutdown timeout.
+func (s *Server) Stop() {
+	log.Info().Msg("stopping grpc service...")
+
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout):
+		s.server.Stop()
+	}
+}
+
+// public services don't need authentication.
+func public(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/grpc.health.v1.") || strings.HasPrefix(fullMethod, "/grpc.reflection.")
+}
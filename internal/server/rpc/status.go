@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+// statusKinds maps domain error kinds to the status codes like the problem responses.
+var statusKinds = []struct {
+	err  error
+	code codes.Code
+}{
+	{model.ErrNotFound, codes.NotFound},
+	{model.ErrDuplicate, codes.AlreadyExists},
+	{model.ErrConflict, codes.Aborted},
+	{model.ErrUnauthorized, codes.Unauthenticated},
+	{model.ErrForbidden, codes.PermissionDenied},
+	{model.ErrTooManyRequests, codes.ResourceExhausted},
+	{model.ErrUnavailable, codes.Unavailable},
+}
+
+// Status maps the error to the gRPC status, unknown errors are hidden behind internal.
+func Status(err error) *status.Status {
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+
+	var (
+		vErr        *model.ValidationError
+		domainErr   *model.Error
+		upstreamErr *model.UpstreamError
+	)
+
+	switch {
+	case errors.As(err, &vErr):
+		st := status.New(codes.InvalidArgument, "request has invalid fields")
+
+		badRequest := &errdetails.BadRequest{}
+		for _, f := range vErr.Fields {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       f.Field,
+				Description: f.Message,
+			})
+		}
+
+		if withDetails, err := st.WithDetails(badRequest); err == nil {
+			return withDetails
+		}
+
+		return st
+	case errors.As(err, &upstreamErr):
+		return status.New(codes.Unavailable, upstreamErr.Detail())
+	case errors.As(err, &domainErr):
+		for _, kind := range statusKinds {
+			if errors.Is(domainErr.Kind, kind.err) {
+				return status.New(kind.code, domainErr.Detail)
+			}
+		}
+	case errors.Is(err, context.Canceled):
+		return status.New(codes.Canceled, "request canceled")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.New(codes.DeadlineExceeded, "deadline exceeded")
+	}
+
+	return status.New(codes.Internal, "internal server error")
+}
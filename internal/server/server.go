@@ -19,7 +19,9 @@ import (
 
 	"github.com/worldline-go/telemetry_example/docs"
 	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/logging"
 	"github.com/worldline-go/telemetry_example/internal/server/handler"
+	"github.com/worldline-go/telemetry_example/internal/util"
 )
 
 var shutdownTimeout = 5 * time.Second
@@ -31,6 +33,10 @@ type RouterSettings struct {
 	BasePath string
 	// ShutdownTimeout using in shutdown server default is 5 second.
 	ShutdownTimeout time.Duration
+	// Middlewares for the api group like authentication.
+	Middlewares []echo.MiddlewareFunc
+	// TrustedProxies ranges to read the client IP from X-Forwarded-For, empty uses the connection address.
+	TrustedProxies []string
 }
 
 func (rs RouterSettings) SetDefaults() RouterSettings {
@@ -55,7 +61,9 @@ func NewRouter(rs RouterSettings, handler *handler.Handler) *Router {
 	e.HideBanner = true
 
 	e.Logger = lecho.From(log.Logger)
-	// e.Validator = util.NewValidator()
+	e.Validator = util.NewValidator()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.IPExtractor = IPExtractor(rs.TrustedProxies)
 
 	e.Use(
 		middleware.Recover(),
@@ -63,6 +71,7 @@ func NewRouter(rs RouterSettings, handler *handler.Handler) *Router {
 		middleware.RequestID(),
 		middleware.RequestLoggerWithConfig(logecho.RequestLoggerConfig()),
 		logecho.ZerologLogger(),
+		logging.Middleware(),
 	)
 
 	// add echo metrics
@@ -80,7 +89,7 @@ func NewRouter(rs RouterSettings, handler *handler.Handler) *Router {
 		rs:   rs.SetDefaults(),
 	}
 
-	router.Register(rs.BasePath, nil, handler)
+	router.Register(rs.BasePath, rs.Middlewares, handler)
 
 	return router
 }
@@ -96,6 +105,15 @@ func NewRouter(rs RouterSettings, handler *handler.Handler) *Router {
 //
 // @host
 // @BasePath /api/v1
+//
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+//
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT as "Bearer <token>"
 func (r *Router) Register(basePath string, middlewares []echo.MiddlewareFunc, h *handler.Handler) {
 	var z interface {
 		GET(path string, h echo.HandlerFunc, m ...echo.MiddlewareFunc) *echo.Route
@@ -0,0 +1,260 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/telemetry"
+	"github.com/worldline-go/telemetry_example/internal/tenant"
+)
+
+const (
+	TypeCount           = "count"
+	TypeProduct         = "product"
+	TypeProductCreated  = "product.created"
+	TypeProductUpdated  = "product.updated"
+	TypeProductDeleted  = "product.deleted"
+	TypeProductSent     = "product.sent"
+	TypeProductReceived = "product.received"
+)
+
+const (
+	SourceLocal = "local"
+	SourceKafka = "kafka"
+)
+
+var (
+	// ErrSlowSubscriber is the reason of the disconnect when the subscriber's buffer is full.
+	ErrSlowSubscriber = errors.New("subscriber is too slow")
+	// ErrClosed is the reason of the disconnect when the service is stopping.
+	ErrClosed = errors.New("stream is closed")
+)
+
+// Broker fans out the events to the subscribers with matching filters.
+type Broker struct {
+	mutex       sync.RWMutex
+	subscribers map[*Subscriber]struct{}
+	closed      bool
+
+	seq atomic.Uint64
+
+	buffer         int
+	maxSubscribers int
+	heartbeat      time.Duration
+}
+
+func New(cfg config.Stream) *Broker {
+	return &Broker{
+		subscribers:    make(map[*Subscriber]struct{}),
+		buffer:         cfg.Buffer,
+		maxSubscribers: cfg.MaxSubscribers,
+		heartbeat:      cfg.Heartbeat,
+	}
+}
+
+// Heartbeat is the interval to write to the idle streams.
+func (b *Broker) Heartbeat() time.Duration {
+	return b.heartbeat
+}
+
+// Subscribe registers the subscriber, call Unsubscribe when the stream ends.
+func (b *Broker) Subscribe(ctx context.Context, filter model.StreamFilter) (*Subscriber, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.closed {
+		return nil, &model.Error{Kind: model.ErrUnavailable, Detail: "stream is closed", Cause: ErrClosed}
+	}
+
+	if b.maxSubscribers > 0 && len(b.subscribers) >= b.maxSubscribers {
+		return nil, &model.Error{Kind: model.ErrTooManyRequests, Detail: "too many stream subscribers"}
+	}
+
+	s := &Subscriber{
+		events: make(chan model.Event, b.buffer),
+		done:   make(chan struct{}),
+		filter: newFilter(filter),
+		tenant: tenant.FromContext(ctx),
+	}
+
+	b.subscribers[s] = struct{}{}
+
+	telemetry.GlobalMeter.StreamSubscribers.Add(ctx, 1, metric.WithAttributes(tenant.Attributes(ctx)...))
+
+	return s, nil
+}
+
+// Unsubscribe removes the subscriber, it is safe to call more than once.
+func (b *Broker) Unsubscribe(ctx context.Context, s *Subscriber) {
+	b.mutex.Lock()
+	_, ok := b.subscribers[s]
+	delete(b.subscribers, s)
+	b.mutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	s.close(nil)
+
+	telemetry.GlobalMeter.StreamSubscribers.Add(ctx, -1, metric.WithAttributes(tenant.Attributes(ctx)...))
+}
+
+// Publish sends the event to the matching subscribers without waiting them.
+// Product events are only sent to the subscribers of the same tenant, the count is shared.
+// A subscriber with a full buffer is disconnected, it should reconnect and read the current state again.
+func (b *Broker) Publish(ctx context.Context, typ, source, key string, data interface{}) {
+	if b == nil {
+		return
+	}
+
+	event := model.Event{
+		ID:     b.seq.Add(1),
+		Type:   typ,
+		Source: source,
+		Key:    key,
+		Data:   data,
+		Tenant: tenant.FromContext(ctx),
+		Time:   time.Now(),
+	}
+
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+		event.TraceID = spanCtx.TraceID().String()
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	delivered := 0
+	for s := range b.subscribers {
+		if !s.filter.match(event.Type, event.Key) || (event.Type != TypeCount && s.tenant != event.Tenant) {
+			continue
+		}
+
+		select {
+		case s.events <- event:
+			delivered++
+		default:
+			if s.close(ErrSlowSubscriber) {
+				telemetry.GlobalMeter.StreamSlowCounter.Add(ctx, 1, metric.WithAttributes(tenant.Attributes(ctx)...))
+			}
+		}
+	}
+
+	trace.SpanFromContext(ctx).AddEvent("stream.publish", trace.WithAttributes(
+		attribute.String("stream.event.type", typ),
+		attribute.Int("stream.subscribers", delivered),
+	))
+}
+
+// Close disconnects all subscribers, new subscribers are rejected.
+func (b *Broker) Close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.closed = true
+
+	for s := range b.subscribers {
+		s.close(ErrClosed)
+	}
+}
+
+// Subscriber receives the events until Done is closed.
+type Subscriber struct {
+	events chan model.Event
+	filter filter
+	tenant string
+
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+// Events are not closed, select with Done to stop reading.
+func (s *Subscriber) Events() <-chan model.Event {
+	return s.events
+}
+
+func (s *Subscriber) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the reason of the disconnect after Done is closed, nil when unsubscribed.
+func (s *Subscriber) Err() error {
+	<-s.done
+
+	return s.err
+}
+
+// Match reports whether the filter of the subscriber accepts the event type and key.
+func (s *Subscriber) Match(typ, key string) bool {
+	return s.filter.match(typ, key)
+}
+
+// close returns true for the first call.
+func (s *Subscriber) close(err error) bool {
+	closed := false
+	s.closeOnce.Do(func() {
+		s.err = err
+		close(s.done)
+		closed = true
+	})
+
+	return closed
+}
+
+type filter struct {
+	types map[string]struct{}
+	names map[string]struct{}
+}
+
+func newFilter(f model.StreamFilter) filter {
+	return filter{
+		types: toSet(f.Types),
+		names: toSet(f.Names),
+	}
+}
+
+func (f filter) match(typ, key string) bool {
+	if len(f.types) > 0 {
+		_, ok := f.types[typ]
+		if !ok && strings.HasPrefix(typ, TypeProduct+".") {
+			_, ok = f.types[TypeProduct]
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	if len(f.names) > 0 && typ != TypeCount {
+		if _, ok := f.names[key]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func toSet(values []string) map[string]struct{} {
+	if len(values) == 0 {
+		return nil
+	}
+
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+
+	return set
+}
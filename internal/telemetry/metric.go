@@ -22,6 +22,28 @@ type Meter struct {
 	HistogramCounter metric.Float64Histogram
 	UpDownCounter    metric.Int64UpDownCounter
 	SendGaugeCounter metric.Int64ObservableGauge
+
+	ThrottledCounter    metric.Int64Counter
+	ConfigReloadCounter metric.Int64Counter
+
+	CallRetryCounter  metric.Int64Counter
+	BreakerStateGauge metric.Int64Gauge
+
+	ProxyDuration metric.Float64Histogram
+
+	ChaosFaultCounter metric.Int64Counter
+
+	StreamSubscribers metric.Int64UpDownCounter
+	StreamSlowCounter metric.Int64Counter
+
+	WebhookDeliveryCounter metric.Int64Counter
+	WebhookDuration        metric.Float64Histogram
+
+	IdempotencyCounter metric.Int64Counter
+
+	CacheCounter metric.Int64Counter
+
+	CoalescedCounter metric.Int64Counter
 }
 
 func SetGlobalMeter() error {
@@ -52,6 +74,77 @@ func SetGlobalMeter() error {
 		return fmt.Errorf("failed to initialize sendGauge; %w", err)
 	}
 
+	m.ThrottledCounter, err = meter.Int64Counter("http_throttled_requests", metric.WithDescription("number of throttled requests"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize http_throttled_requests; %w", err)
+	}
+
+	m.ConfigReloadCounter, err = meter.Int64Counter("config_reloads", metric.WithDescription("number of configuration reloads"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize config_reloads; %w", err)
+	}
+
+	m.CallRetryCounter, err = meter.Int64Counter("call_retries", metric.WithDescription("number of retried outbound calls"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize call_retries; %w", err)
+	}
+
+	m.BreakerStateGauge, err = meter.Int64Gauge("circuit_breaker_state", metric.WithDescription("circuit breaker state, 0 closed, 1 half-open, 2 open"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize circuit_breaker_state; %w", err)
+	}
+
+	m.ProxyDuration, err = meter.Float64Histogram("proxy_upstream_duration",
+		metric.WithDescription("duration of the proxied requests until the response is streamed"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to initialize proxy_upstream_duration; %w", err)
+	}
+
+	m.ChaosFaultCounter, err = meter.Int64Counter("chaos_faults", metric.WithDescription("number of injected faults"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize chaos_faults; %w", err)
+	}
+
+	m.StreamSubscribers, err = meter.Int64UpDownCounter("stream_subscribers", metric.WithDescription("number of connected stream subscribers"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize stream_subscribers; %w", err)
+	}
+
+	m.StreamSlowCounter, err = meter.Int64Counter("stream_slow_subscribers", metric.WithDescription("number of subscribers disconnected for a full buffer"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize stream_slow_subscribers; %w", err)
+	}
+
+	m.WebhookDeliveryCounter, err = meter.Int64Counter("webhook_deliveries", metric.WithDescription("number of webhook delivery attempts"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize webhook_deliveries; %w", err)
+	}
+
+	m.WebhookDuration, err = meter.Float64Histogram("webhook_delivery_duration",
+		metric.WithDescription("duration of the webhook delivery attempts"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to initialize webhook_delivery_duration; %w", err)
+	}
+
+	m.IdempotencyCounter, err = meter.Int64Counter("idempotency_requests", metric.WithDescription("number of requests with an idempotency key"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize idempotency_requests; %w", err)
+	}
+
+	m.CacheCounter, err = meter.Int64Counter("cache_requests", metric.WithDescription("number of cache reads with the hit, miss or not_found result"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize cache_requests; %w", err)
+	}
+
+	m.CoalescedCounter, err = meter.Int64Counter("coalesced_requests", metric.WithDescription("number of lookups served by the query of a concurrent identical lookup"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize coalesced_requests; %w", err)
+	}
+
 	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
 		o.ObserveInt64(m.SendGaugeCounter, WatchValue, metric.WithAttributes(GlobalAttr...))
 		return nil
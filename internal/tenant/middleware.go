@@ -0,0 +1,112 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/auth"
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+// Middleware adds the tenant of the request to the context, span and logger.
+// It runs after the authentication to check the tenant of the credentials.
+func (r *Registry) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, err := r.Resolve(c.Request().Context(), c.Request().Header.Get)
+			if err != nil {
+				return err
+			}
+
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			return next(c)
+		}
+	}
+}
+
+// AdminMiddleware allows the tenant endpoints only to the credentials without a tenant having the admin scope.
+// It runs after the authentication.
+func (r *Registry) AdminMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			p := auth.PrincipalFromContext(c.Request().Context())
+			if p == nil {
+				return &model.Error{
+					Kind:   model.ErrUnauthorized,
+					Detail: "authentication required",
+				}
+			}
+
+			if p.Tenant != "" || !p.HasScopes(r.cfg.AdminScope) {
+				return &model.Error{
+					Kind:   model.ErrForbidden,
+					Detail: "tenants need the " + r.cfg.AdminScope + " scope",
+				}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// Resolve returns the context with the tenant read with the header function.
+// The tenant of the credentials is used without the header, a different header is forbidden.
+// Credentials without a tenant can only use the header with the admin scope.
+func (r *Registry) Resolve(ctx context.Context, header func(key string) string) (context.Context, error) {
+	id := header(r.cfg.Header)
+
+	p := auth.PrincipalFromContext(ctx)
+
+	switch {
+	case p != nil && p.Tenant != "":
+		if id != "" && id != p.Tenant {
+			return nil, &model.Error{
+				Kind:   model.ErrForbidden,
+				Detail: fmt.Sprintf("tenant [%s] is not allowed", id),
+			}
+		}
+
+		id = p.Tenant
+	case id != "" && (p == nil || !p.HasScopes(r.cfg.AdminScope)):
+		return nil, &model.Error{
+			Kind:   model.ErrForbidden,
+			Detail: fmt.Sprintf("tenant [%s] needs credentials of the tenant or the %s scope", id, r.cfg.AdminScope),
+		}
+	}
+
+	if id == "" {
+		if r.cfg.Required {
+			return nil, &model.ValidationError{Fields: []model.FieldError{{
+				Field:   r.cfg.Header,
+				Rule:    "required",
+				Message: r.cfg.Header + " is required",
+			}}}
+		}
+
+		return ctx, nil
+	}
+
+	if !Valid(id) {
+		return nil, &model.ValidationError{Fields: []model.FieldError{{
+			Field:   r.cfg.Header,
+			Rule:    "tenant",
+			Message: fmt.Sprintf("tenant [%s] is invalid", id),
+		}}}
+	}
+
+	if _, err := r.DB(ctx, id); err != nil {
+		return nil, err
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String(AttributeKey, id))
+
+	l := log.Ctx(ctx).With().Str("tenant", id).Logger()
+
+	return WithID(l.WithContext(ctx), id), nil
+}
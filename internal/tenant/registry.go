@@ -0,0 +1,442 @@
+package tenant
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/database"
+	"github.com/worldline-go/telemetry_example/internal/database/dbutil"
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+// Registry keeps a connection pool per tenant schema.
+//
+// It is the database of the db handler, queries run on the pool of the context's tenant
+// and on the main pool without a tenant.
+type Registry struct {
+	cfg      config.Tenancy
+	database config.Database
+	main     *sqlx.DB
+
+	mutex sync.RWMutex
+	pools map[string]*database.Pool
+	// missing tenants with the time they are looked up again.
+	missing map[string]time.Time
+	// opening serializes the new pools to not open one twice.
+	opening sync.Mutex
+	// lookups of the same tenant share one query.
+	lookups singleflight.Group
+}
+
+// maxMissing tenants are cached, unknown ids come from the clients.
+const maxMissing = 10_000
+
+var _ goqu.SQLDatabase = (*Registry)(nil)
+
+func NewRegistry(cfg config.Tenancy, databaseCfg config.Database, main *sqlx.DB) *Registry {
+	return &Registry{
+		cfg:      cfg,
+		database: databaseCfg,
+		main:     main,
+		pools:    make(map[string]*database.Pool),
+		missing:  make(map[string]time.Time),
+	}
+}
+
+// Schema returns the database schema of the tenant.
+func (r *Registry) Schema(id string) string {
+	return r.cfg.SchemaPrefix + id
+}
+
+// Load migrates and opens the existing tenant schemas.
+func (r *Registry) Load(ctx context.Context) error {
+	schemas, err := database.Schemas(ctx, r.main, r.cfg.SchemaPrefix)
+	if err != nil {
+		return err //nolint:wrapcheck // no need
+	}
+
+	for _, schema := range schemas {
+		id := strings.TrimPrefix(schema, r.cfg.SchemaPrefix)
+		if !Valid(id) {
+			continue
+		}
+
+		if _, err := r.Provision(ctx, id); err != nil {
+			// schemas over the limit stay closed, the others are served
+			if errors.Is(err, model.ErrUnavailable) {
+				log.Warn().Err(err).Str("tenant", id).Msg("tenant not loaded")
+
+				continue
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Provision creates and migrates the schema of the tenant, it returns false when the tenant is already open.
+func (r *Registry) Provision(ctx context.Context, id string) (bool, error) {
+	if !Valid(id) {
+		return false, invalidID()
+	}
+
+	r.opening.Lock()
+	defer r.opening.Unlock()
+
+	if _, ok := r.pool(id); ok {
+		return false, nil
+	}
+
+	// checked before the migration to not create schemas which can not be opened
+	if err := r.checkLimit(id); err != nil {
+		return false, err
+	}
+
+	if err := database.MigrateSchema(ctx, r.database.Migrate, r.Schema(id)); err != nil {
+		return false, fmt.Errorf("failed to migrate tenant [%s]; %w", id, err)
+	}
+
+	if err := r.open(ctx, id); err != nil {
+		return false, err
+	}
+
+	r.mutex.Lock()
+	delete(r.missing, id)
+	r.mutex.Unlock()
+
+	log.Info().Str("tenant", id).Msg("tenant provisioned")
+
+	return true, nil
+}
+
+// DB returns the pool of the tenant, schemas provisioned by other replicas are opened on the first use.
+// Missing tenants are cached for the not found ttl.
+func (r *Registry) DB(ctx context.Context, id string) (*sqlx.DB, error) {
+	if pool, ok := r.pool(id); ok {
+		return pool.DB, nil
+	}
+
+	if r.isMissing(id) {
+		return nil, notFound(id)
+	}
+
+	// the lookup is shared by the requests of the tenant, canceling one of them doesn't fail the others
+	_, err, _ := r.lookups.Do(id, func() (interface{}, error) {
+		return nil, r.lookup(context.WithoutCancel(ctx), id)
+	})
+	if err != nil {
+		return nil, err //nolint:wrapcheck // wrapped in lookup
+	}
+
+	pool, ok := r.pool(id)
+	if !ok {
+		return nil, notFound(id)
+	}
+
+	return pool.DB, nil
+}
+
+// lookup opens the pool of the existing tenant schema, only opening a pool holds the opening lock.
+func (r *Registry) lookup(ctx context.Context, id string) error {
+	if _, ok := r.pool(id); ok {
+		return nil
+	}
+
+	schemas, err := database.Schemas(ctx, r.main, r.Schema(id))
+	if err != nil {
+		return err //nolint:wrapcheck // no need
+	}
+
+	if !slices.Contains(schemas, r.Schema(id)) {
+		r.setMissing(id)
+
+		return notFound(id)
+	}
+
+	r.opening.Lock()
+	defer r.opening.Unlock()
+
+	if _, ok := r.pool(id); ok {
+		return nil
+	}
+
+	return r.open(ctx, id)
+}
+
+func (r *Registry) isMissing(id string) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	until, ok := r.missing[id]
+
+	return ok && time.Now().Before(until)
+}
+
+func (r *Registry) setMissing(id string) {
+	if r.cfg.NotFoundTTL <= 0 {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	if len(r.missing) >= maxMissing {
+		for missingID, until := range r.missing {
+			if now.After(until) {
+				delete(r.missing, missingID)
+			}
+		}
+
+		if len(r.missing) >= maxMissing {
+			clear(r.missing)
+		}
+	}
+
+	r.missing[id] = now.Add(r.cfg.NotFoundTTL)
+}
+
+func notFound(id string) error {
+	return &model.Error{
+		Kind:   model.ErrNotFound,
+		Detail: fmt.Sprintf("tenant [%s] not found", id),
+	}
+}
+
+// IDs returns the open tenants ordered by id.
+func (r *Registry) IDs() []string {
+	if r == nil {
+		return nil
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	ids := make([]string, 0, len(r.pools))
+	for id := range r.pools {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
+
+// Each calls fn with the context of the main schema and of every open tenant.
+func (r *Registry) Each(ctx context.Context, fn func(ctx context.Context)) {
+	fn(ctx)
+
+	for _, id := range r.IDs() {
+		fn(WithID(ctx, id))
+	}
+}
+
+// SetDatasource changes the datasource of the tenant pools with the main datasource.
+func (r *Registry) SetDatasource(dbDatasource string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.database.DBDatasource = dbDatasource
+
+	changed := false
+	for id, pool := range r.pools {
+		tenantDatasource, err := r.datasource(id)
+		if err != nil {
+			log.Warn().Err(err).Str("tenant", id).Msg("failed to set tenant datasource")
+
+			continue
+		}
+
+		changed = pool.SetDatasource(tenantDatasource) || changed
+	}
+
+	return changed
+}
+
+// Close closes the tenant pools.
+func (r *Registry) Close() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, pool := range r.pools {
+		if err := pool.Close(); err != nil {
+			log.Warn().Err(err).Str("tenant", id).Msg("failed to close tenant pool")
+		}
+	}
+
+	clear(r.pools)
+}
+
+func (r *Registry) pool(id string) (*database.Pool, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	pool, ok := r.pools[id]
+
+	return pool, ok
+}
+
+// open connects to the tenant schema, the caller holds the opening lock.
+func (r *Registry) open(ctx context.Context, id string) error {
+	if err := r.checkLimit(id); err != nil {
+		return err
+	}
+
+	r.mutex.RLock()
+	tenantDatasource, err := r.datasource(id)
+	r.mutex.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	pool, err := database.Connect(ctx, tenantDatasource, r.database.DBType)
+	if err != nil {
+		return fmt.Errorf("failed to connect to tenant [%s]; %w", id, err)
+	}
+
+	// every tenant has a pool, they share the connections of the database
+	pool.DB.SetMaxOpenConns(r.cfg.MaxOpenConns)
+	pool.DB.SetMaxIdleConns(min(database.MaxIdleConns, r.cfg.MaxOpenConns))
+
+	r.mutex.Lock()
+	r.pools[id] = pool
+	r.mutex.Unlock()
+
+	return nil
+}
+
+// checkLimit returns unavailable when the max tenants are open, the caller holds the opening lock.
+func (r *Registry) checkLimit(id string) error {
+	r.mutex.RLock()
+	open := len(r.pools)
+	r.mutex.RUnlock()
+
+	if open >= r.cfg.MaxTenants {
+		return &model.Error{
+			Kind:   model.ErrUnavailable,
+			Detail: fmt.Sprintf("tenant [%s] can not be opened, tenancy.max_tenants [%d] is reached", id, r.cfg.MaxTenants),
+		}
+	}
+
+	return nil
+}
+
+// datasource keeps the main schema in the search path to use its extensions, the caller holds the mutex.
+func (r *Registry) datasource(id string) (string, error) {
+	tenantDatasource, err := dbutil.ReplaceDBSchema(r.database.DBDatasource, r.Schema(id)+","+dbutil.GetDBSchema(r.database.DBDatasource))
+	if err != nil {
+		return "", fmt.Errorf("failed to set tenant [%s] schema; %w", id, err)
+	}
+
+	return tenantDatasource, nil
+}
+
+// conn returns the pool of the context's tenant.
+func (r *Registry) conn(ctx context.Context) (*sqlx.DB, error) {
+	id := FromContext(ctx)
+	if id == "" {
+		return r.main, nil
+	}
+
+	return r.DB(ctx, id)
+}
+
+// Begin is not routed without a context, use BeginTx.
+func (r *Registry) Begin() (*sql.Tx, error) {
+	return nil, errors.New("tenant database needs the context, use BeginTx")
+}
+
+func (r *Registry) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	db, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.BeginTx(ctx, opts) //nolint:wrapcheck // no need
+}
+
+func (r *Registry) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	db, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.ExecContext(ctx, query, args...) //nolint:wrapcheck // no need
+}
+
+func (r *Registry) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	db, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.PrepareContext(ctx, query) //nolint:wrapcheck // no need
+}
+
+func (r *Registry) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	db, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.QueryContext(ctx, query, args...) //nolint:wrapcheck // no need
+}
+
+func (r *Registry) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	db, err := r.conn(ctx)
+	if err != nil {
+		return errRow(ctx, err)
+	}
+
+	return db.QueryRowContext(ctx, query, args...)
+}
+
+// errRow returns the row with the error of the tenant like not found, sql.Row is only created by a query.
+// The query runs on a database which fails to connect with the error.
+func errRow(ctx context.Context, err error) *sql.Row {
+	db := sql.OpenDB(errConnector{err: err})
+	defer db.Close()
+
+	return db.QueryRowContext(ctx, "")
+}
+
+type errConnector struct {
+	err error
+}
+
+func (c errConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, c.err
+}
+
+func (c errConnector) Driver() driver.Driver {
+	return c
+}
+
+func (c errConnector) Open(string) (driver.Conn, error) {
+	return nil, c.err
+}
+
+func invalidID() error {
+	return &model.ValidationError{Fields: []model.FieldError{{
+		Field:   "id",
+		Rule:    "tenant",
+		Message: "id must start with a lower case letter or digit and have up to 48 lower case letters, digits and underscores",
+	}}}
+}
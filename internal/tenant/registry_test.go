@@ -0,0 +1,43 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/database"
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+func TestProvisionLimit(t *testing.T) {
+	// migrations of the empty database config fail, the limit is checked before them
+	r := NewRegistry(config.Tenancy{SchemaPrefix: "tenant_", MaxTenants: 1}, config.Database{}, nil)
+	r.pools["acme"] = &database.Pool{}
+
+	created, err := r.Provision(context.Background(), "other")
+	if !errors.Is(err, model.ErrUnavailable) {
+		t.Fatalf("want unavailable, got %v", err)
+	}
+
+	if created {
+		t.Fatal("tenant created over the limit")
+	}
+
+	if created, err := r.Provision(context.Background(), "acme"); err != nil || created {
+		t.Fatalf("open tenant: want not created, got %t, %v", created, err)
+	}
+}
+
+func TestQueryRowContext(t *testing.T) {
+	r := NewRegistry(config.Tenancy{SchemaPrefix: "tenant_", NotFoundTTL: time.Minute}, config.Database{}, nil)
+	r.setMissing("missing")
+
+	var v int
+
+	err := r.QueryRowContext(WithID(context.Background(), "missing"), "SELECT 1").Scan(&v)
+	if !errors.Is(err, model.ErrNotFound) {
+		t.Fatalf("want not found, got %v", err)
+	}
+}
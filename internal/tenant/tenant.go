@@ -0,0 +1,82 @@
+package tenant
+
+import (
+	"context"
+	"regexp"
+	"slices"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/worldline-go/telemetry_example/internal/telemetry"
+)
+
+const (
+	// AttributeKey of the tenant in the spans and metrics.
+	AttributeKey = "tenant.id"
+	// KafkaHeader of the tenant in the produced and consumed records.
+	KafkaHeader = "tenant"
+)
+
+// idPattern keeps the tenant schemas as plain identifiers within the 63 characters of postgres.
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_]{0,47}$`)
+
+// Valid reports whether the id can be used as a tenant.
+func Valid(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+type ctxTenantKey struct{}
+
+// WithID adds the tenant to the context, empty id is the main schema.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxTenantKey{}, id)
+}
+
+// FromContext returns the tenant, empty if the request has no tenant.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxTenantKey{}).(string)
+
+	return id
+}
+
+// Attributes returns the global metric attributes with the tenant of the context.
+func Attributes(ctx context.Context, attrs ...attribute.KeyValue) []attribute.KeyValue {
+	result := slices.Clone(telemetry.GlobalAttr)
+	if id := FromContext(ctx); id != "" {
+		result = append(result, attribute.String(AttributeKey, id))
+	}
+
+	return append(result, attrs...)
+}
+
+// KafkaHook adds the tenant of the produce context to the record headers.
+type KafkaHook struct{}
+
+var _ kgo.HookProduceRecordBuffered = KafkaHook{}
+
+func (KafkaHook) OnProduceRecordBuffered(r *kgo.Record) {
+	if r.Context == nil {
+		return
+	}
+
+	if id := FromContext(r.Context); id != "" {
+		// headers may be shared between the records of the producer
+		r.Headers = append(slices.Clip(r.Headers), kgo.RecordHeader{Key: KafkaHeader, Value: []byte(id)})
+	}
+}
+
+// FromRecord returns the tenant header of the record.
+func FromRecord(r *kgo.Record) string {
+	if r == nil {
+		return ""
+	}
+
+	for _, h := range r.Headers {
+		if h.Key == KafkaHeader {
+			return string(h.Value)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,116 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+// Validator is an echo.Validator implementation using go-playground/validator.
+type Validator struct {
+	validate *validator.Validate
+}
+
+func NewValidator() *Validator {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// use json names in the field errors
+	v.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		for _, tag := range []string{"json", "query", "param"} {
+			name, _, _ := strings.Cut(fld.Tag.Get(tag), ",")
+			if name == "-" {
+				return ""
+			}
+
+			if name != "" {
+				return name
+			}
+		}
+
+		return fld.Name
+	})
+
+	// budget of the calls, nested parallel steps multiply them
+	_ = v.RegisterValidation("chain", func(fl validator.FieldLevel) bool {
+		steps, ok := fl.Field().Interface().([]model.Step)
+		if !ok {
+			return false
+		}
+
+		count, depth := model.ChainSize(steps)
+
+		return count <= model.MaxChainSteps && depth <= model.MaxChainDepth
+	})
+
+	return &Validator{validate: v}
+}
+
+// Validate checks the struct and returns *model.ValidationError with all invalid fields.
+func (v *Validator) Validate(i interface{}) error {
+	err := v.validate.Struct(i)
+	if err == nil {
+		return nil
+	}
+
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err //nolint:wrapcheck // no need
+	}
+
+	vErr := &model.ValidationError{Fields: make([]model.FieldError, 0, len(errs))}
+	for _, fErr := range errs {
+		vErr.Fields = append(vErr.Fields, model.FieldError{
+			Field:   fErr.Field(),
+			Rule:    fErr.Tag(),
+			Param:   fErr.Param(),
+			Message: fieldMessage(fErr),
+		})
+	}
+
+	return vErr
+}
+
+func fieldMessage(fErr validator.FieldError) string {
+	field := fErr.Field()
+
+	switch fErr.Tag() {
+	case "required":
+		return field + " is required"
+	case "min":
+		if fErr.Kind() == reflect.String {
+			return fmt.Sprintf("%s must be at least %s characters", field, fErr.Param())
+		}
+
+		return fmt.Sprintf("%s must be at least %s", field, fErr.Param())
+	case "max":
+		if fErr.Kind() == reflect.String {
+			return fmt.Sprintf("%s must be at most %s characters", field, fErr.Param())
+		}
+
+		return fmt.Sprintf("%s must be at most %s", field, fErr.Param())
+	case "gte":
+		return fmt.Sprintf("%s must be greater than or equal to %s", field, fErr.Param())
+	case "lte":
+		return fmt.Sprintf("%s must be less than or equal to %s", field, fErr.Param())
+	case "required_without":
+		return fmt.Sprintf("%s is required without %s", field, fErr.Param())
+	case "excluded_with":
+		return fmt.Sprintf("%s must not be set with %s", field, fErr.Param())
+	case "required_with":
+		return fmt.Sprintf("%s is required with %s", field, fErr.Param())
+	case "chain":
+		return fmt.Sprintf("%s must have at most %d service steps and %d nested parallel steps",
+			field, model.MaxChainSteps, model.MaxChainDepth)
+	case "iso4217":
+		return field + " must be an ISO 4217 currency code"
+	case "oneof":
+		return fmt.Sprintf("%s must be one of [%s]", field, fErr.Param())
+	}
+
+	return fmt.Sprintf("%s failed on the %s rule", field, fErr.Tag())
+}
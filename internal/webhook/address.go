@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/worldline-go/telemetry_example/internal/model"
+)
+
+// ErrPrivateAddress is returned for the webhook addresses inside of the network of the service.
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// sharedAddress is the carrier-grade NAT range, it is not public like the private ranges.
+var sharedAddress = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddress reports whether the webhooks can reach the ip.
+func publicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+
+	return ip.IsGlobalUnicast() &&
+		!ip.IsPrivate() &&
+		!ip.IsLoopback() &&
+		!ip.IsLinkLocalUnicast() &&
+		!sharedAddress.Contains(ip)
+}
+
+// checkAddress is the control of the dialer, it runs after the name is resolved so every connection is checked.
+func checkAddress(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, address)
+	}
+
+	if !publicAddress(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, addrPort.Addr())
+	}
+
+	return nil
+}
+
+// newClient returns the client of the deliveries, redirects are not followed and the response is recorded as is.
+func newClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = checkAddress
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert // default transport
+	// a proxy would dial the address instead of the dialer
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// CheckURL resolves the host of the webhook url and rejects it when one of the addresses is not public.
+// Deliveries check the address again when connecting, the name can resolve differently later.
+func (d *Dispatcher) CheckURL(ctx context.Context, rawURL string) error {
+	if d.cfg.AllowPrivate {
+		return nil
+	}
+
+	invalid := &model.ValidationError{Fields: []model.FieldError{{
+		Field:   "url",
+		Rule:    "public",
+		Message: "url must resolve to public addresses",
+	}}}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return invalid
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		invalid.Fields[0].Message = "url host cannot be resolved"
+
+		return invalid
+	}
+
+	for _, addr := range addrs {
+		if !publicAddress(addr) {
+			return invalid
+		}
+	}
+
+	return nil
+}
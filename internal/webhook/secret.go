@@ -0,0 +1,72 @@
+package webhook
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix marks the encrypted secrets, secrets stored before the encryption are read as is.
+const sealedPrefix = "enc:v1:"
+
+// NewSecret returns a random secret for the webhooks registered without one.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := cryptorand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// newAEAD returns AES-256-GCM with the hash of the configured key.
+func newAEAD(key string) (cipher.AEAD, error) {
+	sum := sha256.Sum256([]byte(key))
+
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block) //nolint:wrapcheck // only fails with a wrong block size
+}
+
+// Seal encrypts the secret of the webhook to store it in the database.
+func (d *Dispatcher) Seal(secret string) (string, error) {
+	nonce := make([]byte, d.aead.NonceSize())
+	if _, err := cryptorand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := d.aead.Seal(nonce, nonce, []byte(secret), nil)
+
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts the secret read from the database.
+func (d *Dispatcher) open(stored string) (string, error) {
+	encoded, ok := strings.CutPrefix(stored, sealedPrefix)
+	if !ok {
+		return stored, nil
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < d.aead.NonceSize() {
+		return "", errors.New("invalid encrypted secret")
+	}
+
+	nonce, ciphertext := sealed[:d.aead.NonceSize()], sealed[d.aead.NonceSize():]
+
+	secret, err := d.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret, webhook.secret_key changed: %w", err)
+	}
+
+	return string(secret), nil
+}
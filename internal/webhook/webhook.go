@@ -0,0 +1,353 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/worldline-go/telemetry_example/internal/config"
+	"github.com/worldline-go/telemetry_example/internal/database/dbhandler"
+	"github.com/worldline-go/telemetry_example/internal/logging"
+	"github.com/worldline-go/telemetry_example/internal/model"
+	"github.com/worldline-go/telemetry_example/internal/telemetry"
+	"github.com/worldline-go/telemetry_example/internal/tenant"
+)
+
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// pruneInterval to remove the deliveries older than the retention.
+var pruneInterval = time.Hour
+
+type event struct {
+	id   string
+	typ  string
+	data interface{}
+	time time.Time
+	// origin is the span of the request which made the change.
+	origin trace.SpanContext
+	// tenant of the request, its webhooks get the event.
+	tenant string
+}
+
+// Dispatcher delivers the product events to the registered webhooks in the background.
+type Dispatcher struct {
+	db      *dbhandler.Handler
+	client  *http.Client
+	cfg     config.Webhook
+	aead    cipher.AEAD
+	tenants *tenant.Registry
+
+	queue chan event
+}
+
+// New creates the dispatcher, old deliveries of the tenant schemas are also removed when tenants is set.
+func New(cfg config.Webhook, db *dbhandler.Handler, tenants *tenant.Registry) (*Dispatcher, error) {
+	aead, err := newAEAD(cfg.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Dispatcher{
+		db:      db,
+		client:  newClient(cfg.Timeout, cfg.AllowPrivate),
+		cfg:     cfg,
+		aead:    aead,
+		tenants: tenants,
+		queue:   make(chan event, cfg.Queue),
+	}, nil
+}
+
+// Notify queues the event without waiting the deliveries, it is dropped when the queue is full.
+func (d *Dispatcher) Notify(ctx context.Context, typ string, data interface{}) {
+	if d == nil {
+		return
+	}
+
+	e := event{
+		id:     uuid.NewString(),
+		typ:    typ,
+		data:   data,
+		time:   time.Now(),
+		origin: trace.SpanContextFromContext(ctx),
+		tenant: tenant.FromContext(ctx),
+	}
+
+	select {
+	case d.queue <- e:
+	default:
+		log.Ctx(ctx).Warn().Str("event", typ).Msg("webhook queue is full, event dropped")
+
+		telemetry.GlobalMeter.WebhookDeliveryCounter.Add(ctx, 1, metric.WithAttributes(
+			tenant.Attributes(ctx, attribute.String("event", typ), attribute.String("result", "dropped"))...,
+		))
+	}
+}
+
+// Run delivers the queued events until the context is done, running deliveries are canceled.
+// Deliveries older than the retention are removed in the background.
+func (d *Dispatcher) Run(ctx context.Context) {
+	go d.prune(ctx)
+
+	slots := make(chan struct{}, d.cfg.Concurrency)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-d.queue:
+			ctxTenant := tenant.WithID(ctx, e.tenant)
+
+			webhooks, err := d.db.GetWebhooks(ctxTenant)
+			if err != nil {
+				logging.Package("webhook").Error().Err(err).Str("tenant", e.tenant).Str("event", e.typ).Msg("failed to get webhooks")
+
+				continue
+			}
+
+			for _, w := range webhooks {
+				if !w.Events.Has(e.typ) {
+					continue
+				}
+
+				w.Secret, err = d.open(w.Secret)
+				if err != nil {
+					logging.Package("webhook").Error().Err(err).Str("tenant", e.tenant).Int64("webhook_id", w.ID).Msg("failed to read webhook secret")
+
+					continue
+				}
+
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+
+				go func() {
+					defer func() { <-slots }()
+
+					d.deliver(ctxTenant, w, e)
+				}()
+			}
+		}
+	}
+}
+
+// prune removes the old deliveries until the context is done.
+func (d *Dispatcher) prune(ctx context.Context) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.tenants.Each(ctx, d.deleteDeliveries)
+		}
+	}
+}
+
+func (d *Dispatcher) deleteDeliveries(ctx context.Context) {
+	id := tenant.FromContext(ctx)
+
+	count, err := d.db.DeleteWebhookDeliveries(ctx, time.Now().Add(-d.cfg.Retention))
+	if err != nil {
+		logging.Package("webhook").Warn().Err(err).Str("tenant", id).Msg("failed to delete old webhook deliveries")
+
+		return
+	}
+
+	if count > 0 {
+		logging.Package("webhook").Debug().Int64("count", count).Str("tenant", id).Msg("deleted old webhook deliveries")
+	}
+}
+
+// deliver is a new trace linked to the request trace, every attempt is a client span.
+func (d *Dispatcher) deliver(ctx context.Context, w model.Webhook, e event) {
+	ctx, span := otel.Tracer("").Start(ctx, "webhook "+e.typ,
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.Link{SpanContext: e.origin}),
+		trace.WithAttributes(
+			attribute.Int64("webhook.id", w.ID),
+			attribute.String("webhook.event", e.typ),
+			attribute.String("webhook.delivery_id", e.id),
+		),
+	)
+	defer span.End()
+
+	if e.tenant != "" {
+		span.SetAttributes(attribute.String(tenant.AttributeKey, e.tenant))
+	}
+
+	body, err := json.Marshal(model.WebhookPayload{
+		ID:   e.id,
+		Type: e.typ,
+		Time: e.time,
+		Data: e.data,
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		retry := d.attempt(ctx, w, e, body, attempt)
+		if !retry {
+			return
+		}
+
+		if attempt >= d.cfg.MaxAttempts {
+			span.SetStatus(codes.Error, "max attempts reached")
+
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.backoff(attempt)):
+		}
+	}
+}
+
+// attempt returns true when the delivery should be retried.
+func (d *Dispatcher) attempt(ctx context.Context, w model.Webhook, e event, body []byte, attempt int) bool {
+	ctx, span := otel.Tracer("").Start(ctx, "POST webhook",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", http.MethodPost),
+			attribute.String("url.full", w.URL),
+			attribute.Int("webhook.attempt", attempt),
+		),
+	)
+	defer span.End()
+
+	delivery := model.WebhookDelivery{
+		WebhookID:  w.ID,
+		DeliveryID: e.id,
+		Event:      e.typ,
+		Attempt:    attempt,
+		TraceID:    span.SpanContext().TraceID().String(),
+	}
+
+	start := time.Now()
+	statusCode, err := d.send(ctx, w, e, body)
+	latency := time.Since(start)
+
+	delivery.StatusCode = statusCode
+	delivery.LatencyMS = latency.Milliseconds()
+
+	result := "success"
+	retry := false
+
+	switch {
+	case err != nil:
+		delivery.Error = err.Error()
+		result, retry = "failed", true
+	case statusCode >= http.StatusMultipleChoices:
+		delivery.Error = http.StatusText(statusCode)
+		result = "failed"
+		retry = statusCode >= http.StatusInternalServerError ||
+			statusCode == http.StatusTooManyRequests ||
+			statusCode == http.StatusRequestTimeout
+	}
+
+	if statusCode != 0 {
+		span.SetAttributes(attribute.Int("http.response.status_code", statusCode))
+	}
+
+	if delivery.Error != "" {
+		span.SetStatus(codes.Error, delivery.Error)
+	}
+
+	attrs := metric.WithAttributes(tenant.Attributes(ctx,
+		attribute.String("event", e.typ),
+		attribute.String("result", result),
+	)...)
+	telemetry.GlobalMeter.WebhookDeliveryCounter.Add(ctx, 1, attrs)
+	telemetry.GlobalMeter.WebhookDuration.Record(ctx, latency.Seconds(), attrs)
+
+	// recorded even the service is stopping
+	if err := d.db.AddWebhookDelivery(context.WithoutCancel(ctx), delivery); err != nil {
+		logging.Package("webhook").Error().Err(err).Int64("webhook_id", w.ID).Msg("failed to record webhook delivery")
+	}
+
+	return retry
+}
+
+func (d *Dispatcher) send(ctx context.Context, w model.Webhook, e event, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, e.typ)
+	req.Header.Set(HeaderDelivery, e.id)
+	req.Header.Set(HeaderSignature, Sign(w.Secret, time.Now(), body))
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err //nolint:wrapcheck // recorded as is
+	}
+
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	return resp.StatusCode, nil
+}
+
+// backoff doubles the wait for every attempt with jitter.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	if d.cfg.Backoff == 0 {
+		return 0
+	}
+
+	wait := d.cfg.Backoff << (attempt - 1)
+	if wait <= 0 || wait > d.cfg.MaxBackoff {
+		wait = d.cfg.MaxBackoff
+	}
+
+	// up to 20% jitter to spread the retries of the same endpoint
+	return wait - time.Duration(rand.Int64N(int64(wait)/5+1))
+}
+
+// Sign returns the signature header value like "t=1700000000,v1=<hex>".
+// v1 is HMAC-SHA256 of "<t>.<body>" with the webhook secret, receivers should check t against replays.
+func Sign(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
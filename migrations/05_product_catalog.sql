@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    parent_id INTEGER REFERENCES categories (id) ON DELETE RESTRICT,
+    last_user VARCHAR(255) NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- names are unique under the same parent, root categories have parent 0
+CREATE UNIQUE INDEX IF NOT EXISTS categories_parent_name ON categories (COALESCE(parent_id, 0), name);
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES categories (id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS tags JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN IF NOT EXISTS price_amount BIGINT CHECK (price_amount >= 0),
+    ADD COLUMN IF NOT EXISTS price_currency CHAR(3);
+
+-- constraints have no IF NOT EXISTS, the migration can run again on a schema already having it
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint WHERE conname = 'products_price' AND conrelid = 'products'::regclass
+    ) THEN
+        ALTER TABLE products ADD CONSTRAINT products_price CHECK ((price_amount IS NULL) = (price_currency IS NULL));
+    END IF;
+END
+$$;
+
+CREATE INDEX IF NOT EXISTS products_category_id ON products (category_id);
+CREATE INDEX IF NOT EXISTS products_tags ON products USING GIN (tags jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS products_price ON products (price_currency, price_amount);
@@ -0,0 +1,4 @@
+-- encrypted secrets are longer than the given ones
+ALTER TABLE webhooks ALTER COLUMN secret TYPE TEXT;
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_created_at ON webhook_deliveries (created_at);